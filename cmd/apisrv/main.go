@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -32,6 +33,8 @@ var (
 	flJSONLogs         = fs.Bool("json", false, "enable json output")
 	flDev              = fs.Bool("dev", false, "enable dev mode")
 	flGenerateTSClient = fs.Bool("ts_client", false, "generate TypeScript vt rpc client and exit")
+	flVFSCheck         = fs.Bool("vfs_check", false, "check vfs storage consistency, print report and exit")
+	flDryRun           = fs.Bool("dry_run", false, "report vfs_check results without changes")
 	cfg                app.Config
 )
 
@@ -77,7 +80,8 @@ func main() {
 	}
 
 	// create & run app
-	a := app.New(appName, sl, cfg, dbc, pgdb)
+	a, err := app.New(appName, sl, cfg, dbc, pgdb)
+	exitOnError(err)
 
 	// enable vfs
 	if cfg.Server.EnableVFS {
@@ -93,6 +97,16 @@ func main() {
 		os.Exit(0)
 	}
 
+	// check vfs from cmd flags
+	if *flVFSCheck {
+		r, er := a.CheckVFS(ctx, *flDryRun)
+		exitOnError(er)
+		b, er := json.MarshalIndent(r, "", "  ")
+		exitOnError(er)
+		_, _ = fmt.Fprintln(os.Stdout, string(b))
+		os.Exit(0)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
@@ -8,6 +8,7 @@ CREATE TABLE "statuses" (
 	"statusId" SERIAL NOT NULL,
 	"title" varchar(255) NOT NULL,
 	"alias" varchar(64) NOT NULL,
+	"titles" jsonb NOT NULL DEFAULT '{}',
 	CONSTRAINT "statuses_pkey" PRIMARY KEY("statusId"),
 	CONSTRAINT "statuses_alias_key" UNIQUE("alias")
 );
@@ -37,7 +38,9 @@ CREATE TABLE "vfsFiles" (
 	"isFavorite" bool DEFAULT false,
 	"mimeType" varchar(255) NOT NULL,
 	"fileSize" int4 DEFAULT 0,
+	"hash" varchar(40),
 	"fileExists" bool NOT NULL DEFAULT true,
+	"scanStatus" varchar(16),
 	"createdAt" timestamp NOT NULL DEFAULT now(),
 	"statusId" int4 NOT NULL,
 	CONSTRAINT "vfsFiles_pkey" PRIMARY KEY("fileId")
@@ -53,11 +56,23 @@ CREATE INDEX "IX_FK_vfsFiles_statusId_vfsFiles" ON "vfsFiles" USING BTREE (
 );
 
 
+CREATE INDEX "IX_vfsFiles_hash" ON "vfsFiles" USING BTREE (
+	"hash"
+);
+
+
+CREATE INDEX "IX_vfsFiles_scanStatus" ON "vfsFiles" USING BTREE (
+	"scanStatus"
+);
+
+
 CREATE TABLE "vfsFolders" (
 	"folderId" SERIAL NOT NULL,
 	"parentFolderId" int4,
 	"title" varchar(255) NOT NULL,
 	"isFavorite" bool DEFAULT false,
+	"isPrivate" bool NOT NULL DEFAULT false,
+	"quota" int8,
 	"createdAt" timestamp NOT NULL DEFAULT now(),
 	"statusId" int4 NOT NULL,
 	CONSTRAINT "vfsFolders_pkey" PRIMARY KEY("folderId")
@@ -82,6 +97,7 @@ CREATE TABLE "vfsHashes" (
 	"height" int4 NOT NULL DEFAULT 0,
 	"blurhash" text,
 	"error" text,
+	"scanStatus" varchar(16),
 	"createdAt" timestamp with time zone NOT NULL DEFAULT now(),
 	"indexedAt" timestamp with time zone,
 	CONSTRAINT "vfsHashes_pkey" PRIMARY KEY("hash","namespace")
@@ -92,6 +108,11 @@ CREATE INDEX "IX_vfsHashes_indexedAt" ON "vfsHashes" USING BTREE (
 );
 
 
+CREATE INDEX "IX_vfsHashes_scanStatus" ON "vfsHashes" USING BTREE (
+	"scanStatus"
+);
+
+
 
 ALTER TABLE "users" ADD CONSTRAINT "FK_users_statusId" FOREIGN KEY ("statusId")
 	REFERENCES "statuses"("statusId")
@@ -129,3 +150,72 @@ ALTER TABLE "vfsFolders" ADD CONSTRAINT "vfsFolders_statusId_fkey" FOREIGN KEY (
 	NOT DEFERRABLE;
 
 
+
+CREATE TABLE "outboxEvents" (
+	"eventId" BIGSERIAL NOT NULL,
+	"aggregate" varchar(64) NOT NULL,
+	"aggregateId" int4 NOT NULL,
+	"type" varchar(128) NOT NULL,
+	"payload" jsonb NOT NULL,
+	"actorId" int4,
+	"createdAt" timestamp with time zone NOT NULL DEFAULT now(),
+	"attempts" int4 NOT NULL DEFAULT 0,
+	"nextAttemptAt" timestamp with time zone NOT NULL DEFAULT now(),
+	"sentAt" timestamp with time zone,
+	"deadAt" timestamp with time zone,
+	"error" text,
+	CONSTRAINT "outboxEvents_pkey" PRIMARY KEY("eventId")
+);
+
+CREATE INDEX "IX_outboxEvents_pending" ON "outboxEvents" USING BTREE (
+	"aggregate", "aggregateId", "eventId"
+) WHERE "sentAt" IS NULL AND "deadAt" IS NULL;
+
+CREATE TABLE "jobs" (
+	"jobId" BIGSERIAL NOT NULL,
+	"kind" varchar(128) NOT NULL,
+	"args" jsonb NOT NULL DEFAULT '{}',
+	"state" varchar(16) NOT NULL DEFAULT 'pending',
+	"uniqueKey" varchar(255),
+	"attempts" int4 NOT NULL DEFAULT 0,
+	"maxAttempts" int4 NOT NULL DEFAULT 10,
+	"runAt" timestamp with time zone NOT NULL DEFAULT now(),
+	"lockedAt" timestamp with time zone,
+	"finishedAt" timestamp with time zone,
+	"error" text,
+	"createdAt" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "jobs_pkey" PRIMARY KEY("jobId")
+);
+
+CREATE INDEX "IX_jobs_pending" ON "jobs" USING BTREE (
+	"runAt", "jobId"
+) WHERE "state" = 'pending';
+
+CREATE UNIQUE INDEX "UX_jobs_uniqueKey" ON "jobs" USING BTREE (
+	"uniqueKey"
+) WHERE "state" IN ('pending', 'running');
+
+CREATE TABLE "cronRuns" (
+	"runId" BIGSERIAL NOT NULL,
+	"task" varchar(128) NOT NULL,
+	"scheduledAt" timestamp with time zone NOT NULL,
+	"startedAt" timestamp with time zone NOT NULL DEFAULT now(),
+	"finishedAt" timestamp with time zone,
+	"duration" int4,
+	"host" varchar(255) NOT NULL,
+	"error" text,
+	CONSTRAINT "cronRuns_pkey" PRIMARY KEY("runId"),
+	CONSTRAINT "cronRuns_task_scheduledAt_key" UNIQUE("task", "scheduledAt")
+);
+
+CREATE TABLE "revisions" (
+	"revisionId" BIGSERIAL NOT NULL,
+	"entity" varchar(64) NOT NULL,
+	"entityId" int4 NOT NULL,
+	"revision" int4 NOT NULL,
+	"data" jsonb NOT NULL,
+	"actorId" int4,
+	"createdAt" timestamp with time zone NOT NULL DEFAULT now(),
+	CONSTRAINT "revisions_pkey" PRIMARY KEY("revisionId"),
+	CONSTRAINT "revisions_entity_entityId_revision_key" UNIQUE("entity", "entityId", "revision")
+);
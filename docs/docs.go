@@ -0,0 +1,16 @@
+// Package docs embeds database schema for tests and tools.
+package docs
+
+import (
+	_ "embed"
+)
+
+// Schema is a database schema, see apisrv.sql.
+//
+//go:embed apisrv.sql
+var Schema string
+
+// InitData is an initial data for empty database, see init.sql.
+//
+//go:embed init.sql
+var InitData string
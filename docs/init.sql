@@ -1,6 +1,6 @@
-INSERT INTO "statuses" ( "statusId", "title", "alias" ) VALUES ( 1, 'Опубликован', 'enabled' );
-INSERT INTO "statuses" ( "statusId", "title", "alias" ) VALUES ( 2, 'Не опубликован', 'disabled' );
-INSERT INTO "statuses" ( "statusId", "title", "alias" ) VALUES ( 3, 'Удален', 'deleted' );
+INSERT INTO "statuses" ( "statusId", "title", "alias", "titles" ) VALUES ( 1, 'Опубликован', 'enabled', '{"en": "Published"}' );
+INSERT INTO "statuses" ( "statusId", "title", "alias", "titles" ) VALUES ( 2, 'Не опубликован', 'disabled', '{"en": "Unpublished"}' );
+INSERT INTO "statuses" ( "statusId", "title", "alias", "titles" ) VALUES ( 3, 'Удален', 'deleted', '{"en": "Deleted"}' );
 
 -- password is 12345
 INSERT INTO "users" ( "login", "password", "statusId" ) VALUES ( 'admin', '$2y$14$4IpqlaJ2Rvfgs.wb8f6lPODVLb/Ygl6zw1ZCUKz5CuT6WB6CV44AG', 1 );
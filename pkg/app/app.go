@@ -4,7 +4,12 @@ import (
 	"context"
 	"time"
 
+	"apisrv/pkg/cron"
 	"apisrv/pkg/db"
+	"apisrv/pkg/jobs"
+	"apisrv/pkg/media"
+	"apisrv/pkg/outbox"
+	"apisrv/pkg/storage"
 	"apisrv/pkg/vt"
 
 	"github.com/go-pg/pg/v10"
@@ -18,6 +23,8 @@ import (
 	"github.com/vmkteam/zenrpc/v2"
 )
 
+const cronRunsRetention = time.Hour * 24 * 30
+
 type Config struct {
 	Database *pg.Options
 	Server   struct {
@@ -30,7 +37,19 @@ type Config struct {
 		Environment string
 		DSN         string
 	}
-	VFS vfs.Config
+	VFS         vfs.Config
+	Storage     storage.Config
+	Images      media.ImageConfig
+	Access      media.AccessConfig
+	VFSPolicies map[string]media.Policy
+	VFSIndexer  VFSIndexerConfig
+	VFSCheck    media.CheckConfig
+	VFSScan     media.ScanConfig
+	Tus         media.TusConfig
+	Push        vt.PushConfig
+	Outbox      outbox.Config
+	Jobs        jobs.Config
+	Cron        cron.Config
 }
 
 type App struct {
@@ -39,25 +58,64 @@ type App struct {
 	cfg     Config
 	db      db.DB
 	dbc     *pg.DB
+	dbOpts  []db.RepoOption
 	mon     *monitor.Monitor
 	echo    *echo.Echo
 	vtsrv   *zenrpc.Server
+	push    *vt.PushHub
+	events  *db.EventListener
+	relay   *outbox.Relay
+	queue   *jobs.Queue
+	cron    *cron.Scheduler
+	media   *media.Media
+	indexer *vfsIndexer
 }
 
-func New(appName string, sl embedlog.Logger, cfg Config, db db.DB, dbc *pg.DB) *App {
+func New(appName string, sl embedlog.Logger, cfg Config, dbo db.DB, dbc *pg.DB) (*App, error) {
 	a := &App{
 		appName: appName,
 		cfg:     cfg,
-		db:      db,
+		db:      dbo,
 		dbc:     dbc,
+		dbOpts:  []db.RepoOption{db.EnableOutbox(cfg.Outbox.Enabled)}, // outbox events are written only if relay delivers them
 		echo:    appkit.NewEcho(),
 		Logger:  sl,
 	}
 
+	// add change events listener
+	a.events = db.NewEventListener(dbc, sl)
+	if cfg.Server.IsDevel {
+		a.events.Subscribe(db.AllEntities, func(ctx context.Context, e db.ChangeEvent) {
+			a.Print(ctx, "entity changed", "entity", e.Entity, "id", e.ID, "op", e.Operation, "actorId", e.ActorID)
+		})
+	}
+
+	// add push hub for vt clients
+	a.push = vt.NewPushHub(db.NewCommonRepo(dbo, a.dbOpts...), cfg.Push, sl)
+	a.push.Listen(a.events)
+
+	// add outbox relay
+	if cfg.Outbox.Enabled {
+		sink, err := outbox.NewSink(cfg.Outbox, sl)
+		if err != nil {
+			return nil, err
+		}
+		a.relay = outbox.NewRelay(dbo, sl, sink, cfg.Outbox)
+	}
+
+	// add job queue, handlers are registered via Jobs()
+	a.queue = jobs.NewQueue(dbo, sl, cfg.Jobs)
+
+	// add scheduler, tasks are registered via Cron()
+	a.cron = cron.NewScheduler(dbo, sl, cfg.Cron)
+	if err := a.registerCronTasks(); err != nil {
+		return nil, err
+	}
+
 	// add services
-	a.vtsrv = vt.New(a.db, a.Logger, a.cfg.Server.IsDevel)
+	a.vtsrv = vt.New(a.db, a.Logger, a.cfg.Server.IsDevel, a.dbOpts...)
 
-	return a
+	return a, nil
 }
 
 // Run is a function that runs application.
@@ -69,9 +127,34 @@ func (a *App) Run(ctx context.Context) error {
 	a.registerVTApiHandlers()
 	a.registerMetadata()
 
+	go a.events.Run(ctx)
+	if a.relay != nil {
+		a.relay.Run(ctx)
+	}
+	a.queue.Run(ctx)
+	a.cron.Run(ctx)
+	if a.indexer != nil {
+		a.indexer.Run(ctx)
+	}
+
 	return a.runHTTPServer(ctx, a.cfg.Server.Host, a.cfg.Server.Port)
 }
 
+// Events returns change events listener for in-process subscribers.
+func (a *App) Events() *db.EventListener {
+	return a.events
+}
+
+// Jobs returns background job queue for handlers registration and enqueueing.
+func (a *App) Jobs() *jobs.Queue {
+	return a.queue
+}
+
+// Cron returns scheduler for periodic tasks registration.
+func (a *App) Cron() *cron.Scheduler {
+	return a.cron
+}
+
 // VTTypeScriptClient returns TypeScript client for VT.
 func (a *App) VTTypeScriptClient() ([]byte, error) {
 	gen := rpcgen.FromSMD(a.vtsrv.SMD())
@@ -79,13 +162,45 @@ func (a *App) VTTypeScriptClient() ([]byte, error) {
 	return gen.TSCustomClient(tsSettings).Generate()
 }
 
-// Shutdown is a function that gracefully stops HTTP server.
+// Shutdown is a function that gracefully stops HTTP server and waits for running jobs and outbox relay until timeout.
 func (a *App) Shutdown(timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	a.mon.Close()
+	a.push.Close()
+	a.events.Stop()
+
+	err := a.echo.Shutdown(ctx)
+	a.cron.Stop()
+	if er := a.queue.Stop(ctx); er != nil {
+		a.Error(ctx, "running jobs are not finished", "err", er)
+	}
+	if a.relay != nil {
+		if er := a.relay.Stop(ctx); er != nil {
+			a.Error(ctx, "outbox relay is not stopped", "err", er)
+		}
+	}
+	if a.indexer != nil {
+		a.indexer.Stop()
+	}
 
-	return a.echo.Shutdown(ctx)
+	return err
+}
+
+// registerCronTasks registers built-in periodic tasks.
+func (a *App) registerCronTasks() error {
+	err := a.cron.Register("cronRunsCleanup", "@daily", func(ctx context.Context) error {
+		_, err := db.NewCronRepo(a.db).DeleteCronRuns(ctx, time.Now().Add(-cronRunsRetention))
+		return err
+	})
+	if err != nil || a.relay == nil {
+		return err
+	}
+
+	return a.cron.Register("outboxCleanup", "@daily", func(ctx context.Context) error {
+		_, err := a.relay.Cleanup(ctx)
+		return err
+	})
 }
 
 // registerMetadata is a function that registers meta info from service. Must be updated.
@@ -97,9 +212,18 @@ func (a *App) registerMetadata() {
 			appkit.NewDBMetadata(a.cfg.Database.Database, a.cfg.Database.PoolSize, false),
 		},
 		Services: []appkit.ServiceMetadata{
-			// NewServiceMetadata("srv", MetadataServiceTypeAsync),
+			appkit.NewServiceMetadata("jobs", appkit.MetadataServiceTypeAsync),
 		},
 	}
+	if a.relay != nil {
+		opts.Services = append(opts.Services, appkit.NewServiceMetadata("outbox", appkit.MetadataServiceTypeAsync))
+	}
+	if a.indexer != nil {
+		opts.Services = append(opts.Services, appkit.NewServiceMetadata("vfsIndexer", appkit.MetadataServiceTypeAsync))
+	}
+	for _, name := range a.cron.Tasks() {
+		opts.Services = append(opts.Services, appkit.NewServiceMetadata("cron:"+name, appkit.MetadataServiceTypeAsync))
+	}
 
 	md := appkit.NewMetadataManager(opts)
 	md.RegisterMetrics()
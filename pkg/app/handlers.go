@@ -6,7 +6,9 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 
+	"apisrv/pkg/db"
 	"apisrv/pkg/rpc"
+	"apisrv/pkg/vt"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -77,4 +79,7 @@ func (a *App) registerVTApiHandlers() {
 	a.echo.Any("/v1/vt/", appkit.EchoHandler(appkit.XRequestID(a.vtsrv)))
 	a.echo.Any("/v1/vt/doc/", appkit.EchoHandlerFunc(zenrpc.SMDBoxHandler))
 	a.echo.Any("/v1/vt/api.ts", appkit.EchoHandlerFunc(rpcgen.Handler(gen.TSCustomClient(tsSettings))))
+	a.echo.GET("/v1/vt/export/:entity", echo.WrapHandler(vt.HTTPAuthMiddleware(db.NewCommonRepo(a.db), vt.NewExportHandler(a.db, a.Logger))))
+	a.echo.POST("/v1/vt/import/:entity", echo.WrapHandler(vt.HTTPAuthMiddleware(db.NewCommonRepo(a.db), vt.NewImportHandler(a.db, a.Logger, a.dbOpts...))))
+	a.echo.GET("/v1/vt/ws", echo.WrapHandler(a.push))
 }
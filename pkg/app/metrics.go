@@ -23,6 +23,19 @@ func (a *App) registerMetrics() {
 	)
 	a.mon.Open()
 
+	if a.relay != nil {
+		a.relay.RegisterMetrics()
+	}
+	a.queue.RegisterMetrics()
+	a.push.RegisterMetrics()
+	a.cron.RegisterMetrics()
+	if a.media != nil {
+		a.media.RegisterMetrics()
+	}
+	if a.indexer != nil {
+		a.indexer.RegisterMetrics()
+	}
+
 	a.echo.Use(appkit.HTTPMetrics(appkit.DefaultServerName))
 	a.echo.Any("/metrics", echo.WrapHandler(promhttp.Handler()))
 }
@@ -1,21 +1,65 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
+	"path"
+	"sync"
+	"time"
 
 	"apisrv/pkg/db"
+	"apisrv/pkg/media"
+	"apisrv/pkg/storage"
 	"apisrv/pkg/vt"
 
+	"github.com/go-pg/pg/v10"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/vmkteam/appkit"
+	"github.com/vmkteam/embedlog"
 	"github.com/vmkteam/vfs"
 	vfsdb "github.com/vmkteam/vfs/db"
+	"github.com/vmkteam/zenrpc/v2"
+	"github.com/vmkteam/zenrpc/v2/smd"
 )
 
 const NSVFS = "vfs"
 
+var errVFSDisabled = errors.New("vfs is disabled")
+
+const (
+	defaultIndexerWorkers   = 2
+	defaultIndexerBatchSize = 100
+	defaultIndexerInterval  = time.Second * 5
+)
+
+type VFSIndexerConfig struct {
+	// Enabled starts hash indexer, it fills width, height and blurhash of vfsHashes.
+	Enabled bool
+	// Workers is a count of concurrent queue processors.
+	Workers int
+	// BatchSize is a count of hashes indexed by worker in one transaction.
+	BatchSize uint64
+	// BlurHash enables blurhash calculation, it is required for previews.
+	BlurHash bool
+	// Interval is a delay between queue processing.
+	Interval time.Duration
+}
+
 // RegisterVFS register VFS handler and RPC service
 func (a *App) RegisterVFS(cfg vfs.Config) error {
+	st, err := storage.New(a.cfg.Storage, cfg.Path)
+	if err != nil {
+		return err
+	}
+
+	// local path is not used by s3 storage
+	_, isLocal := st.(*storage.Local)
+	cfg.SkipFolderVerify = cfg.SkipFolderVerify || !isLocal
 	vf, err := vfs.New(cfg, a.Logger)
 	if err != nil {
 		return err
@@ -23,12 +67,264 @@ func (a *App) RegisterVFS(cfg vfs.Config) error {
 
 	cr := db.NewCommonRepo(a.db)
 	vfsRepo := vfsdb.NewVfsRepo(a.db)
-	a.echo.Any("/v1/vfs/upload/file", appkit.EchoHandler(vt.HTTPAuthMiddleware(cr, vf.UploadHandler(vfsRepo))))
-	a.echo.Any("/v1/vfs/upload/hash", echo.WrapHandler(vt.HTTPAuthMiddleware(cr, vf.HashUploadHandler(&vfsRepo))))
-	a.echo.GET(a.cfg.VFS.WebPath, echo.WrapHandler(http.StripPrefix(a.cfg.VFS.WebPath, http.FileServer(http.Dir(a.cfg.VFS.Path)))))
-	vt.WebPath = a.cfg.VFS.WebPath
+	a.media, err = media.New(vf, st, media.Config{VFS: cfg, Storage: a.cfg.Storage, Images: a.cfg.Images, Access: a.cfg.Access, Policies: a.cfg.VFSPolicies, Check: a.cfg.VFSCheck, Tus: a.cfg.Tus, Scan: a.cfg.VFSScan}, a.dbc, a.Logger, a.dbOpts...)
+	if err != nil {
+		return err
+	}
+	a.echo.Any("/v1/vfs/upload/file", appkit.EchoHandler(vt.HTTPAuthMiddleware(cr, a.media.UploadHandler())))
+	a.echo.Any("/v1/vfs/upload/hash", echo.WrapHandler(vt.HTTPAuthMiddleware(cr, a.media.HashUploadHandler())))
+	tus := echo.WrapHandler(vt.HTTPAuthMiddleware(cr, vt.HTTPLocalizeMiddleware(a.media.TusHandler("/v1/vfs/upload/tus"))))
+	a.echo.Any("/v1/vfs/upload/tus", tus)
+	a.echo.Any("/v1/vfs/upload/tus/:id", tus)
+	a.echo.Match([]string{http.MethodGet, http.MethodHead}, path.Join(cfg.WebPath, "*"), echo.WrapHandler(a.media.ServeHandler()))
+	vt.WebPath = cfg.WebPath
+
+	a.vtsrv.Register(NSVFS, vfsService{Invoker: vfs.NewService(vfsRepo, vf, a.dbc), ext: vt.NewVfsService(a.media, db.NewVfsRepo(a.db, a.dbOpts...))})
+	a.vtsrv.Register(vt.NSMedia, vt.NewMediaService(a.media, a.media))
+	a.vtsrv.Use(a.vfsStorage())
+
+	// add consistency check of storage and db
+	err = a.cron.Register("vfsCheck", "@daily", func(ctx context.Context) error {
+		_, err := a.media.Check(ctx, false)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove expired resumable uploads
+	err = a.cron.Register("tusCleanup", "@hourly", func(ctx context.Context) error {
+		_, err := a.media.CleanupUploads(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// rescan pending uploads when scanner was not available
+	if a.cfg.VFSScan.ClamAV != "" {
+		err = a.cron.Register("vfsScan", "@every 5m", func(ctx context.Context) error {
+			_, err := a.media.ScanPending(ctx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// add hash indexer with preview and scan handlers, scan works with local disk only
+	if a.cfg.VFSIndexer.Enabled {
+		a.indexer = newVFSIndexer(a.dbc, a.Logger, &vfsRepo, vf, a.media, a.cfg.VFSIndexer)
+		auth := echo.WrapMiddleware(func(next http.Handler) http.Handler { return vt.HTTPAuthMiddleware(cr, next) })
+		a.echo.GET("/v1/vfs/preview/:file", a.indexer.Preview, auth)
+		a.echo.GET("/v1/vfs/preview/:ns/:file", a.indexer.Preview, auth)
+		if isLocal {
+			a.echo.POST("/v1/vfs/scan", a.indexer.ScanFilesHandler, auth)
+		}
+	}
+
+	return nil
+}
 
-	a.vtsrv.Register(NSVFS, vfs.NewService(vfsRepo, vf, a.dbc))
+// CheckVFS runs consistency check of vfs storage, see media.Media.Check.
+func (a *App) CheckVFS(ctx context.Context, dryRun bool) (*media.CheckReport, error) {
+	if a.media == nil {
+		return nil, errVFSDisabled
+	}
+	return a.media.Check(ctx, dryRun)
+}
+
+// vfsIndexer runs vfs.HashIndexer queue processing with app lifecycle.
+type vfsIndexer struct {
+	*vfs.HashIndexer
+	dbc   *pg.DB
+	media *media.Media
+	cfg   VFSIndexerConfig
+
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	statQueue prometheus.Gauge
+}
+
+func newVFSIndexer(dbc *pg.DB, logger embedlog.Logger, repo *vfsdb.VfsRepo, vf vfs.VFS, m *media.Media, cfg VFSIndexerConfig) *vfsIndexer {
+	if cfg.Workers == 0 {
+		cfg.Workers = defaultIndexerWorkers
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultIndexerBatchSize
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultIndexerInterval
+	}
 
+	return &vfsIndexer{
+		HashIndexer: vfs.NewHashIndexer(logger, vfsdb.New(dbc), repo, vf, cfg.Workers, cfg.BatchSize, cfg.BlurHash),
+		dbc:         dbc,
+		media:       m,
+		cfg:         cfg,
+		done:        make(chan struct{}),
+
+		statQueue: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "app",
+			Subsystem: "vfs_indexer",
+			Name:      "queue_depth",
+			Help:      "Count of vfs hashes waiting for indexing.",
+		}),
+	}
+}
+
+// RegisterMetrics registers indexer metrics in prometheus.
+func (vi *vfsIndexer) RegisterMetrics() {
+	prometheus.MustRegister(vi.statQueue)
+}
+
+// Run starts processing of index queue by workers in background until ctx is done or Stop is called.
+// vfs.HashIndexer.Start is not used, because it can't be stopped and reads files from local disk only.
+func (vi *vfsIndexer) Run(ctx context.Context) {
+	vi.wg.Add(1)
+	go vi.loop(ctx)
+}
+
+// loop processes index queue every interval.
+func (vi *vfsIndexer) loop(ctx context.Context) {
+	defer vi.wg.Done()
+
+	t := time.NewTicker(vi.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		vi.processQueue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-vi.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Stop stops queue processing and waits for running workers.
+func (vi *vfsIndexer) Stop() {
+	close(vi.done)
+	vi.wg.Wait()
+}
+
+// processQueue runs one batch per worker and updates queue depth.
+func (vi *vfsIndexer) processQueue(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range vi.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rows, err := vi.media.ProcessHashQueue(ctx, vi.cfg.BatchSize, vi.cfg.BlurHash)
+			if err != nil {
+				vi.Error(ctx, "process vfs index queue failed", "err", err)
+			} else if rows > 0 {
+				vi.Print(ctx, "vfs hashes indexed", "rows", rows)
+			}
+		}()
+	}
+	wg.Wait()
+
+	n, err := vi.dbc.ModelContext(ctx, (*vfsdb.VfsHash)(nil)).
+		Where(`? IS NULL`, pg.Ident(vfsdb.Columns.VfsHash.IndexedAt)).
+		Count()
+	if err != nil {
+		vi.Error(ctx, "count vfs index queue failed", "err", err)
+		return
+	}
+	vi.statQueue.Set(float64(n))
+}
+
+// vfsService is vfs.Service with methods of vt.VfsService in one namespace, folder methods of vt.VfsService replace vfs ones.
+type vfsService struct {
+	zenrpc.Invoker
+	ext zenrpc.Invoker
+}
+
+// Invoke calls vt.VfsService for its methods and vfs.Service for others.
+func (s vfsService) Invoke(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+	switch method {
+	case vt.RPC.VfsService.Duplicates, vt.RPC.VfsService.FileDuplicates,
+		vt.RPC.VfsService.CreateFolder, vt.RPC.VfsService.DeleteFolder, vt.RPC.VfsService.MoveFolder,
+		vt.RPC.VfsService.RenameFolder, vt.RPC.VfsService.ManageFavorites:
+		return s.ext.Invoke(ctx, method, params)
+	}
+	return s.Invoker.Invoke(ctx, method, params)
+}
+
+// SMD returns methods of both services.
+func (s vfsService) SMD() smd.ServiceInfo {
+	r := s.Invoker.SMD()
+	r.Methods = maps.Clone(r.Methods)
+	maps.Copy(r.Methods, s.ext.SMD().Methods)
+	return r
+}
+
+// vfsStorage handles vfs rpc methods that change files via media storage, because vfs service works with local disk only.
+func (a *App) vfsStorage() zenrpc.MiddlewareFunc {
+	return func(h zenrpc.InvokeFunc) zenrpc.InvokeFunc {
+		return func(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+			if zenrpc.NamespaceFromContext(ctx) != NSVFS {
+				return h(ctx, method, params)
+			}
+
+			var resp zenrpc.Response
+			switch method {
+			case vfs.RPC.Service.SetFilePhysicalName:
+				var (
+					fileID int
+					name   string
+				)
+				if err := bindParams(params, []string{"fileId", "name"}, &fileID, &name); err != nil {
+					return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+				}
+				resp.Set(a.media.RenameFile(ctx, fileID, name))
+			case vfs.RPC.Service.DeleteFiles:
+				var fileIDs []int64
+				if err := bindParams(params, []string{"fileIds"}, &fileIDs); err != nil {
+					return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+				}
+				resp.Set(a.media.DeleteFiles(ctx, fileIDs))
+			case vfs.RPC.Service.DeleteHash:
+				var ns, hash string
+				if err := bindParams(params, []string{"namespace", "hash"}, &ns, &hash); err != nil {
+					return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+				}
+				resp.Set(a.media.DeleteHash(ctx, ns, hash))
+			default:
+				return h(ctx, method, params)
+			}
+
+			return resp
+		}
+	}
+}
+
+// bindParams decodes named or positional rpc params to dst by names order.
+func bindParams(params json.RawMessage, names []string, dst ...any) error {
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(params, &named); err == nil {
+		for i, name := range names {
+			if err = json.Unmarshal(named[name], dst[i]); err != nil {
+				return fmt.Errorf("param %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	var positional []json.RawMessage
+	if err := json.Unmarshal(params, &positional); err != nil || len(positional) < len(names) {
+		return fmt.Errorf("params %v not found", names)
+	}
+
+	for i, name := range names {
+		if err := json.Unmarshal(positional[i], dst[i]); err != nil {
+			return fmt.Errorf("param %s: %w", name, err)
+		}
+	}
 	return nil
 }
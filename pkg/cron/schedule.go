@@ -0,0 +1,191 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSpec = errors.New("invalid cron spec")
+
+// Schedule returns next activation time after given time.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Every is a fixed interval schedule aligned to interval start, e.g. @every 15m runs at :00, :15, :30, :45.
+type Every time.Duration
+
+func (e Every) Next(t time.Time) time.Time {
+	d := time.Duration(e)
+	return t.Truncate(d).Add(d)
+}
+
+// SpecSchedule is a schedule set by standard 5-field cron expression: minute hour day-of-month month day-of-week.
+type SpecSchedule struct {
+	minute, hour, dom, month, dow uint64
+	loc                           *time.Location
+}
+
+type bounds struct {
+	min, max int
+}
+
+var (
+	minutes = bounds{0, 59}
+	hours   = bounds{0, 23}
+	doms    = bounds{1, 31}
+	months  = bounds{1, 12}
+	dows    = bounds{0, 7} // 0 and 7 are Sunday
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses cron expression, descriptor (@daily, @hourly, ...) or @every <duration>.
+// Spec schedules use time.Local.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		dur, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || dur < time.Second {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSpec, spec)
+		}
+		return Every(dur), nil
+	}
+
+	if v, ok := descriptors[spec]; ok {
+		spec = v
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidSpec, len(fields))
+	}
+
+	s := &SpecSchedule{loc: time.Local}
+	var err error
+	for i, b := range []bounds{minutes, hours, doms, months, dows} {
+		ptr := []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}[i]
+		if *ptr, err = parseField(fields[i], b); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidSpec, fields[i], err)
+		}
+	}
+
+	// Sunday could be set as 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(spec string) Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parseField parses comma separated list of *, n, n-m with optional /step into bit set.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, errors.New("invalid step")
+			}
+		}
+
+		from, to := b.min, b.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			l, r, _ := strings.Cut(rng, "-")
+			var err1, err2 error
+			from, err1 = strconv.Atoi(l)
+			to, err2 = strconv.Atoi(r)
+			if err1 != nil || err2 != nil {
+				return 0, errors.New("invalid range")
+			}
+		default:
+			n, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, errors.New("invalid value")
+			}
+			from = n
+			if !hasStep {
+				to = n
+			}
+		}
+
+		if from < b.min || to > b.max || from > to {
+			return 0, errors.New("value out of range")
+		}
+
+		for i := from; i <= to; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+// Next returns next activation time after t with minute precision.
+func (s *SpecSchedule) Next(t time.Time) time.Time {
+	orig := t.Location()
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t.In(orig)
+		}
+	}
+
+	return time.Time{}
+}
+
+// dayMatches checks day of month and day of week. If both are restricted, any of them should match like in cron.
+func (s *SpecSchedule) dayMatches(t time.Time) bool {
+	domAll := s.dom == bitsAll(doms)
+	dowAll := s.dow|1<<7 == bitsAll(dows)
+	domOK := s.dom&(1<<t.Day()) != 0
+	dowOK := s.dow&(1<<int(t.Weekday())) != 0
+
+	if !domAll && !dowAll {
+		return domOK || dowOK
+	}
+	return domOK && dowOK
+}
+
+func bitsAll(b bounds) uint64 {
+	var bits uint64
+	for i := b.min; i <= b.max; i++ {
+		bits |= 1 << i
+	}
+	return bits
+}
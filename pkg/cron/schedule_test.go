@@ -0,0 +1,53 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParse(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	next := func(spec, from string) time.Time {
+		s, err := Parse(spec)
+		So(err, ShouldBeNil)
+		return s.Next(at(from))
+	}
+
+	Convey("Test cron spec parsing", t, func() {
+		Convey("invalid specs", func() {
+			for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 1ms"} {
+				_, err := Parse(spec)
+				So(err, ShouldWrap, ErrInvalidSpec)
+			}
+		})
+
+		Convey("next activation", func() {
+			So(next("* * * * *", "2024-01-01 10:00"), ShouldEqual, at("2024-01-01 10:01"))
+			So(next("*/15 * * * *", "2024-01-01 10:07"), ShouldEqual, at("2024-01-01 10:15"))
+			So(next("30 3 * * *", "2024-01-01 10:00"), ShouldEqual, at("2024-01-02 03:30"))
+			So(next("0 0 1 * *", "2024-01-15 00:00"), ShouldEqual, at("2024-02-01 00:00"))
+			So(next("0 9 * * 1-5", "2024-01-05 10:00"), ShouldEqual, at("2024-01-08 09:00")) // friday -> monday
+			So(next("0 0 * * 7", "2024-01-01 00:00"), ShouldEqual, at("2024-01-07 00:00"))   // sunday as 7
+			So(next("0 0 13 * 5", "2024-01-01 00:00"), ShouldEqual, at("2024-01-05 00:00"))  // dom or dow
+			So(next("0 0 29 2 *", "2024-03-01 00:00"), ShouldEqual, at("2028-02-29 00:00"))
+			So(next("@hourly", "2024-01-01 10:59"), ShouldEqual, at("2024-01-01 11:00"))
+			So(next("@daily", "2024-01-01 10:00"), ShouldEqual, at("2024-01-02 00:00"))
+			So(next("5,10 * * * *", "2024-01-01 10:05"), ShouldEqual, at("2024-01-01 10:10"))
+		})
+
+		Convey("every", func() {
+			s, err := Parse("@every 10m")
+			So(err, ShouldBeNil)
+			from := time.Date(2024, 1, 1, 10, 7, 0, 0, time.UTC)
+			So(s.Next(from), ShouldEqual, time.Date(2024, 1, 1, 10, 10, 0, 0, time.UTC))
+		})
+	})
+}
@@ -0,0 +1,240 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmkteam/embedlog"
+)
+
+// SpecDisabled disables task in Config.Tasks.
+const SpecDisabled = "off"
+
+var ErrTaskExists = errors.New("task already registered")
+
+type Config struct {
+	// Tasks overrides schedules of registered tasks by name, use "off" to disable task.
+	Tasks map[string]string
+}
+
+// TaskFunc is a scheduled task. Task is executed once per tick across all replicas.
+type TaskFunc func(ctx context.Context) error
+
+type task struct {
+	name     string
+	spec     string
+	schedule Schedule
+	fn       TaskFunc
+}
+
+// Scheduler runs registered tasks by schedule. Every run is guarded by session advisory lock,
+// so long task never overlaps itself, and is recorded in cronRuns table with unique tick time.
+type Scheduler struct {
+	embedlog.Logger
+	dbo  db.DB
+	repo db.CronRepo
+	cfg  Config
+	host string
+
+	mu    sync.Mutex
+	tasks []task
+
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	statRuns     *prometheus.CounterVec
+	statDuration *prometheus.HistogramVec
+	statLastRun  *prometheus.GaugeVec
+}
+
+// NewScheduler returns new scheduler.
+func NewScheduler(dbo db.DB, logger embedlog.Logger, cfg Config) *Scheduler {
+	host, _ := os.Hostname()
+
+	return &Scheduler{
+		Logger: logger,
+		dbo:    dbo,
+		repo:   db.NewCronRepo(dbo),
+		cfg:    cfg,
+		host:   host,
+		done:   make(chan struct{}),
+
+		statRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "app",
+			Subsystem: "cron",
+			Name:      "runs_total",
+			Help:      "Scheduled task runs by result.",
+		}, []string{"task", "result"}),
+		statDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "app",
+			Subsystem: "cron",
+			Name:      "duration_seconds",
+			Help:      "Scheduled task duration.",
+		}, []string{"task"}),
+		statLastRun: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "app",
+			Subsystem: "cron",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Time of last successful run of task.",
+		}, []string{"task"}),
+	}
+}
+
+// RegisterMetrics registers scheduler metrics in prometheus.
+func (s *Scheduler) RegisterMetrics() {
+	prometheus.MustRegister(s.statRuns, s.statDuration, s.statLastRun)
+}
+
+// Register adds task with cron spec, see Parse. Spec could be overridden or disabled by Config.Tasks.
+// Tasks must be registered before Run.
+func (s *Scheduler) Register(name, spec string, fn TaskFunc) error {
+	if v, ok := s.cfg.Tasks[name]; ok {
+		spec = v
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		if t.name == name {
+			return fmt.Errorf("%w: %s", ErrTaskExists, name)
+		}
+	}
+
+	if spec == SpecDisabled {
+		return nil
+	}
+
+	sch, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", name, err)
+	}
+
+	s.tasks = append(s.tasks, task{name: name, spec: spec, schedule: sch, fn: fn})
+	return nil
+}
+
+// Tasks returns names of enabled tasks.
+func (s *Scheduler) Tasks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+// Run runs all tasks until ctx is done or Stop is called.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	tasks := s.tasks
+	s.mu.Unlock()
+
+	for _, t := range tasks {
+		s.wg.Add(1)
+		go s.loop(ctx, t)
+	}
+}
+
+// Stop stops scheduler and waits for running tasks.
+func (s *Scheduler) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// loop waits for next tick of task and runs it.
+func (s *Scheduler) loop(ctx context.Context, t task) {
+	defer s.wg.Done()
+	s.Print(ctx, "cron task scheduled", "task", t.name, "spec", t.spec)
+
+	// tasks are not canceled with ctx, so running task is finished on Stop
+	runCtx := context.WithoutCancel(ctx)
+	for {
+		next := t.schedule.Next(time.Now())
+		if next.IsZero() {
+			s.Error(ctx, "cron task has no next run", "task", t.name, "spec", t.spec)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.run(runCtx, t, next)
+	}
+}
+
+// run executes task for tick if it is not executed by another replica.
+func (s *Scheduler) run(ctx context.Context, t task, tick time.Time) {
+	unlock, ok, err := s.dbo.TryLock(ctx, "cron:"+t.name)
+	if err != nil {
+		s.statRuns.WithLabelValues(t.name, "error").Inc()
+		s.Error(ctx, "cron lock failed", "err", err, "task", t.name)
+		return
+	} else if !ok {
+		s.statRuns.WithLabelValues(t.name, "skipped").Inc()
+		return
+	}
+	defer func() {
+		if err = unlock(); err != nil {
+			s.Error(ctx, "cron unlock failed", "err", err, "task", t.name)
+		}
+	}()
+
+	run := &db.CronRun{Task: t.name, ScheduledAt: tick, StartedAt: time.Now(), Host: s.host}
+	if ok, err = s.repo.StartCronRun(ctx, run); err != nil {
+		s.statRuns.WithLabelValues(t.name, "error").Inc()
+		s.Error(ctx, "cron start run failed", "err", err, "task", t.name)
+		return
+	} else if !ok {
+		s.statRuns.WithLabelValues(t.name, "skipped").Inc()
+		return
+	}
+
+	taskErr := call(ctx, t.fn)
+	finished := time.Now()
+	duration := finished.Sub(run.StartedAt)
+	ms := int(duration.Milliseconds())
+	run.FinishedAt, run.Duration = &finished, &ms
+	s.statDuration.WithLabelValues(t.name).Observe(duration.Seconds())
+
+	if taskErr != nil {
+		msg := taskErr.Error()
+		run.Error = &msg
+		s.statRuns.WithLabelValues(t.name, "failed").Inc()
+		s.Error(ctx, "cron task failed", "err", taskErr, "task", t.name)
+	} else {
+		s.statRuns.WithLabelValues(t.name, "done").Inc()
+		s.statLastRun.WithLabelValues(t.name).Set(float64(finished.Unix()))
+	}
+
+	if err = s.repo.FinishCronRun(ctx, run); err != nil {
+		s.Error(ctx, "cron finish run failed", "err", err, "task", t.name)
+	}
+}
+
+// call runs task and converts panics to errors.
+func call(ctx context.Context, fn TaskFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panic: %v", r)
+		}
+	}()
+
+	return fn(ctx)
+}
@@ -2,64 +2,59 @@ package db
 
 import (
 	"context"
-	"errors"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/go-pg/pg/v10/orm"
 )
 
 type CommonRepo struct {
-	db      orm.DB
-	filters map[string][]Filter
-	sort    map[string][]SortField
-	join    map[string][]string
+	users Repo[User, *UserSearch]
 }
 
 // NewCommonRepo returns new repository
-func NewCommonRepo(db orm.DB) CommonRepo {
+func NewCommonRepo(db orm.DB, opts ...RepoOption) CommonRepo {
 	return CommonRepo{
-		db: db,
-		filters: map[string][]Filter{
-			Tables.User.Name: {StatusFilter},
-		},
-		sort: map[string][]SortField{
-			Tables.User.Name: {{Column: Columns.User.CreatedAt, Direction: SortDesc}},
-		},
-		join: map[string][]string{
-			Tables.User.Name: {TableColumns},
-		},
+		users: NewRepo[User, *UserSearch](db, RepoOpts{
+			Filters: []Filter{StatusFilter},
+			Sort:    []SortField{{Column: Columns.User.CreatedAt, Direction: SortDesc}},
+			Join:    []string{TableColumns},
+		}.with(opts)).WithChangeEvents().WithOutbox(userOutboxPayload).WithRevisions(userRevisionData),
 	}
 }
 
 // WithTransaction is a function that wraps CommonRepo with pg.Tx transaction.
 func (cr CommonRepo) WithTransaction(tx *pg.Tx) CommonRepo {
-	cr.db = tx
+	cr.users = cr.users.WithTransaction(tx)
 	return cr
 }
 
 // WithEnabledOnly is a function that adds "statusId"=1 as base filter.
 func (cr CommonRepo) WithEnabledOnly() CommonRepo {
-	f := make(map[string][]Filter, len(cr.filters))
-	for i := range cr.filters {
-		f[i] = make([]Filter, len(cr.filters[i]))
-		copy(f[i], cr.filters[i])
-		f[i] = append(f[i], StatusEnabledFilter)
-	}
-	cr.filters = f
-
+	cr.users = cr.users.WithEnabledOnly()
 	return cr
 }
 
 /*** User ***/
 
+// Users returns generic User repository.
+func (cr CommonRepo) Users() Repo[User, *UserSearch] {
+	return cr.users
+}
+
+// WithUserHook is a function that adds hook for User write event.
+func (cr CommonRepo) WithUserHook(event HookEvent, fn HookFunc[User]) CommonRepo {
+	cr.users = cr.users.WithHook(event, fn)
+	return cr
+}
+
 // FullUser returns full joins with all columns
 func (cr CommonRepo) FullUser() OpFunc {
-	return WithColumns(cr.join[Tables.User.Name]...)
+	return cr.users.Full()
 }
 
 // DefaultUserSort returns default sort.
 func (cr CommonRepo) DefaultUserSort() OpFunc {
-	return WithSort(cr.sort[Tables.User.Name]...)
+	return cr.users.DefaultSort()
 }
 
 // UserByID is a function that returns User by ID(s) or nil.
@@ -69,59 +64,50 @@ func (cr CommonRepo) UserByID(ctx context.Context, id int, ops ...OpFunc) (*User
 
 // OneUser is a function that returns one User by filters. It could return pg.ErrMultiRows.
 func (cr CommonRepo) OneUser(ctx context.Context, search *UserSearch, ops ...OpFunc) (*User, error) {
-	obj := &User{}
-	err := buildQuery(ctx, cr.db, obj, search, cr.filters[Tables.User.Name], PagerTwo, ops...).Select()
-
-	if errors.Is(err, pg.ErrMultiRows) {
-		return nil, err
-	} else if errors.Is(err, pg.ErrNoRows) {
-		return nil, nil
-	}
-
-	return obj, err
+	return cr.users.One(ctx, search, ops...)
 }
 
 // UsersByFilters returns User list.
-func (cr CommonRepo) UsersByFilters(ctx context.Context, search *UserSearch, pager Pager, ops ...OpFunc) (users []User, err error) {
-	err = buildQuery(ctx, cr.db, &users, search, cr.filters[Tables.User.Name], pager, ops...).Select()
-	return
+func (cr CommonRepo) UsersByFilters(ctx context.Context, search *UserSearch, pager Pager, ops ...OpFunc) ([]User, error) {
+	return cr.users.ByFilters(ctx, search, pager, ops...)
 }
 
 // CountUsers returns count
 func (cr CommonRepo) CountUsers(ctx context.Context, search *UserSearch, ops ...OpFunc) (int, error) {
-	return buildQuery(ctx, cr.db, &User{}, search, cr.filters[Tables.User.Name], PagerOne, ops...).Count()
+	return cr.users.Count(ctx, search, ops...)
+}
+
+// ForEachUser calls fn for every User without loading all rows into memory.
+func (cr CommonRepo) ForEachUser(ctx context.Context, search *UserSearch, fn func(*User) error, ops ...OpFunc) error {
+	return cr.users.ForEach(ctx, search, fn, ops...)
 }
 
 // AddUser adds User to DB.
 func (cr CommonRepo) AddUser(ctx context.Context, user *User, ops ...OpFunc) (*User, error) {
-	q := cr.db.ModelContext(ctx, user)
-	if len(ops) == 0 {
-		q = q.ExcludeColumn(Columns.User.CreatedAt)
-	}
-	applyOps(q, ops...)
-	_, err := q.Insert()
-
-	return user, err
+	return cr.users.Add(ctx, user, ops...)
 }
 
 // UpdateUser updates User in DB.
 func (cr CommonRepo) UpdateUser(ctx context.Context, user *User, ops ...OpFunc) (bool, error) {
-	q := cr.db.ModelContext(ctx, user).WherePK()
-	if len(ops) == 0 {
-		q = q.ExcludeColumn(Columns.User.CreatedAt)
-	}
-	applyOps(q, ops...)
-	res, err := q.Update()
-	if err != nil {
-		return false, err
-	}
+	return cr.users.Update(ctx, user, ops...)
+}
 
-	return res.RowsAffected() > 0, err
+// AddUsers adds User list to DB in batches.
+func (cr CommonRepo) AddUsers(ctx context.Context, users []User, ops ...OpFunc) error {
+	return cr.users.AddMany(ctx, users, DefaultBatchSize, ops...)
+}
+
+// UpsertUsers inserts User list or updates given columns on conflict by target columns.
+func (cr CommonRepo) UpsertUsers(ctx context.Context, users []User, target, update []string) error {
+	return cr.users.Upsert(ctx, users, target, update, DefaultBatchSize)
+}
+
+// UpdateUsersByFilters sets columns from user to all User matched by search. It returns IDs of updated rows.
+func (cr CommonRepo) UpdateUsersByFilters(ctx context.Context, search *UserSearch, user *User, columns ...string) ([]int, error) {
+	return cr.users.UpdateByFilters(ctx, search, user, columns...)
 }
 
 // DeleteUser set statusId to deleted in DB.
 func (cr CommonRepo) DeleteUser(ctx context.Context, id int) (deleted bool, err error) {
-	user := &User{ID: id, StatusID: StatusDeleted}
-
-	return cr.UpdateUser(ctx, user, WithColumns(Columns.User.StatusID))
+	return cr.users.Delete(ctx, id)
 }
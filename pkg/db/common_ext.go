@@ -13,10 +13,17 @@ func (cr CommonRepo) AuthenticateUser(ctx context.Context, dbu *User, authKey st
 	return cr.UpdateUser(ctx, dbu, WithColumns(Columns.User.AuthKey, Columns.User.LastActivityAt))
 }
 
+// UpdateUserActivity updates last activity of user. It is updated on every request, so it is written without hooks:
+// change events, outbox and revisions are not written.
 func (cr CommonRepo) UpdateUserActivity(ctx context.Context, dbu *User) (bool, error) {
 	now := time.Now()
 	dbu.LastActivityAt = &now
-	return cr.UpdateUser(ctx, dbu, WithColumns(Columns.User.LastActivityAt))
+	res, err := cr.users.DB().ModelContext(ctx, dbu).Column(Columns.User.LastActivityAt).WherePK().Update()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
 }
 
 func (cr CommonRepo) EnabledUserByAuthKey(ctx context.Context, authKey string) (*User, error) {
@@ -32,3 +39,17 @@ func (cr CommonRepo) EnabledUserByLogin(ctx context.Context, login string) (*Use
 func (cr CommonRepo) UpdateUserPassword(ctx context.Context, dbu *User) (bool, error) {
 	return cr.UpdateUser(ctx, dbu, WithColumns(Columns.User.Password, Columns.User.AuthKey))
 }
+
+// userOutboxPayload returns User without secrets for outbox.
+func userOutboxPayload(u *User) any {
+	c := *u
+	c.Password, c.AuthKey = "", ""
+	return c
+}
+
+// userRevisionData returns User without secrets and activity for revisions.
+func userRevisionData(u *User) any {
+	c := *u
+	c.Password, c.AuthKey, c.LastActivityAt = "", "", nil
+	return c
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// CronRun is a history record of scheduled task run.
+type CronRun struct {
+	tableName struct{} `pg:"cronRuns,alias:t,discard_unknown_columns"`
+
+	ID          int        `pg:"runId,pk"`
+	Task        string     `pg:"task,use_zero"`
+	ScheduledAt time.Time  `pg:"scheduledAt,use_zero"`
+	StartedAt   time.Time  `pg:"startedAt"`
+	FinishedAt  *time.Time `pg:"finishedAt"`
+	Duration    *int       `pg:"duration"` // in milliseconds
+	Host        string     `pg:"host,use_zero"`
+	Error       *string    `pg:"error"`
+}
+
+type CronRepo struct {
+	db orm.DB
+}
+
+// NewCronRepo returns new repository
+func NewCronRepo(db orm.DB) CronRepo {
+	return CronRepo{db: db}
+}
+
+// WithTransaction is a function that wraps CronRepo with pg.Tx transaction.
+func (cr CronRepo) WithTransaction(tx *pg.Tx) CronRepo {
+	cr.db = tx
+	return cr
+}
+
+// StartCronRun adds run of task for scheduled time. If run for the same tick already exists false is returned,
+// so every tick is executed only once across replicas.
+func (cr CronRepo) StartCronRun(ctx context.Context, run *CronRun) (bool, error) {
+	res, err := cr.db.ModelContext(ctx, run).
+		OnConflict(`("task", "scheduledAt") DO NOTHING`).
+		Returning("*").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// FinishCronRun saves result of run.
+func (cr CronRepo) FinishCronRun(ctx context.Context, run *CronRun) error {
+	_, err := cr.db.ModelContext(ctx, run).
+		Column("finishedAt", "duration", "error").
+		WherePK().
+		Update()
+	return err
+}
+
+// LastCronRuns returns last runs of task.
+func (cr CronRepo) LastCronRuns(ctx context.Context, task string, limit int) (list []CronRun, err error) {
+	err = cr.db.ModelContext(ctx, &list).
+		Where(`?TableAlias."task" = ?`, task).
+		Order("scheduledAt DESC").
+		Limit(limit).
+		Select()
+	return
+}
+
+// DeleteCronRuns deletes runs started before given time.
+func (cr CronRepo) DeleteCronRuns(ctx context.Context, before time.Time) (int, error) {
+	res, err := cr.db.ModelContext(ctx, (*CronRun)(nil)).
+		Where(`?TableAlias."startedAt" < ?`, before).
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
@@ -4,6 +4,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"hash/crc64"
 	"reflect"
 
@@ -65,9 +66,35 @@ func (db *DB) RunInLock(ctx context.Context, lockName string, fns ...func(*pg.Tx
 	})
 }
 
+// TryLock acquires session level advisory lock on dedicated connection without waiting.
+// If lock is held by another session ok is false. Function unlock releases lock and connection.
+func (db *DB) TryLock(ctx context.Context, lockName string) (unlock func() error, ok bool, err error) {
+	lock := int64(crc64.Checksum([]byte(lockName), db.crcTable))
+	conn := db.Conn()
+
+	if _, err = conn.QueryOneContext(ctx, pg.Scan(&ok), "select pg_try_advisory_lock(?) -- ?", lock, lockName); err != nil || !ok {
+		return nil, false, errors.Join(err, conn.Close())
+	}
+
+	unlock = func() error {
+		_, uerr := conn.Exec("select pg_advisory_unlock(?)", lock)
+		return errors.Join(uerr, conn.Close())
+	}
+
+	return unlock, true, nil
+}
+
 // buildQuery applies all functions to orm query.
 func buildQuery(ctx context.Context, db orm.DB, model interface{}, search Searcher, filters []Filter, pager Pager, ops ...OpFunc) *orm.Query {
-	q := db.ModelContext(ctx, model)
+	q := filterQuery(db.ModelContext(ctx, model), search, filters)
+	q = pager.Apply(q)
+	applyOps(q, ops...)
+
+	return q
+}
+
+// filterQuery applies base filters and search to orm query.
+func filterQuery(q *orm.Query, search Searcher, filters []Filter) *orm.Query {
 	for _, filter := range filters {
 		filter.Apply(q)
 	}
@@ -76,8 +103,5 @@ func buildQuery(ctx context.Context, db orm.DB, model interface{}, search Search
 		search.Apply(q)
 	}
 
-	q = pager.Apply(q)
-	applyOps(q, ops...)
-
 	return q
 }
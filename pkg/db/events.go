@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/vmkteam/embedlog"
+)
+
+// EventsChannel is a postgres channel for entity change events.
+const EventsChannel = "apisrv_changes"
+
+// PushChannel is a postgres channel for messages pushed to connected clients.
+const PushChannel = "apisrv_push"
+
+// AllEntities is used for subscription to changes of every entity.
+const AllEntities = "*"
+
+const (
+	listenerReceiveTimeout = time.Second * 5
+	listenerMinBackoff     = time.Second
+	listenerMaxBackoff     = time.Minute
+)
+
+type Operation string
+
+const (
+	OperationInsert Operation = "insert"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+)
+
+// ChangeEvent is an entity change event published with pg_notify.
+type ChangeEvent struct {
+	Entity    string    `json:"entity"`
+	ID        int       `json:"id"`
+	Operation Operation `json:"op"`
+	ActorID   *int      `json:"actorId,omitempty"`
+}
+
+// PushMessage is a message for clients subscribed to Topic, e.g. job progress.
+type PushMessage struct {
+	Topic string          `json:"topic"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+type actorCtx string
+
+const actorKey actorCtx = "db.actor"
+
+// WithActor returns context with user id that is used as actor in change events.
+func WithActor(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, actorKey, userID)
+}
+
+// ActorFromContext returns actor user id from context or nil.
+func ActorFromContext(ctx context.Context) *int {
+	if id, ok := ctx.Value(actorKey).(int); ok {
+		return &id
+	}
+	return nil
+}
+
+// PublishChange sends change event to EventsChannel. Event is delivered after transaction commit.
+func PublishChange(ctx context.Context, db orm.DB, e ChangeEvent) error {
+	if e.ActorID == nil {
+		e.ActorID = ActorFromContext(ctx)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "select pg_notify(?, ?)", EventsChannel, string(b))
+	return err
+}
+
+// Push sends message with data to PushChannel. Message is delivered after transaction commit.
+func Push(ctx context.Context, db orm.DB, topic, event string, data any) error {
+	m := PushMessage{Topic: topic, Event: event}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		m.Data = b
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "select pg_notify(?, ?)", PushChannel, string(b))
+	return err
+}
+
+// WithChangeEvents is a function that adds hooks which publish ChangeEvent on every write.
+func (r Repo[T, S]) WithChangeEvents() Repo[T, S] {
+	publish := func(op Operation) HookFunc[T] {
+		return func(ctx context.Context, db orm.DB, obj *T) error {
+			return PublishChange(ctx, db, ChangeEvent{Entity: r.Table(), ID: r.id(obj), Operation: op})
+		}
+	}
+
+	return r.
+		WithHook(HookAfterInsert, publish(OperationInsert)).
+		WithHook(HookAfterUpdate, publish(OperationUpdate)).
+		WithHook(HookAfterDelete, publish(OperationDelete))
+}
+
+// ChangeHandler is a function that handles change event.
+type ChangeHandler func(ctx context.Context, e ChangeEvent)
+
+// NotificationHandler is a function that handles raw payload of postgres notification.
+type NotificationHandler func(ctx context.Context, payload string)
+
+// EventListener listens EventsChannel and dispatches change events to subscribers.
+type EventListener struct {
+	embedlog.Logger
+	dbc *pg.DB
+
+	mu            sync.RWMutex
+	handlers      map[string][]ChangeHandler
+	notifications map[string][]NotificationHandler
+	done          chan struct{}
+	stopOnce      sync.Once
+}
+
+// NewEventListener returns new listener for EventsChannel.
+func NewEventListener(dbc *pg.DB, logger embedlog.Logger) *EventListener {
+	return &EventListener{
+		Logger:        logger,
+		dbc:           dbc,
+		handlers:      make(map[string][]ChangeHandler),
+		notifications: make(map[string][]NotificationHandler),
+		done:          make(chan struct{}),
+	}
+}
+
+// Subscribe registers handler for entity changes. Use AllEntities for every entity.
+func (l *EventListener) Subscribe(entity string, fn ChangeHandler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers[entity] = append(l.handlers[entity], fn)
+}
+
+// Listen registers handler for notifications of additional postgres channel. Channels must be registered before Run.
+func (l *EventListener) Listen(channel string, fn NotificationHandler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.notifications[channel] = append(l.notifications[channel], fn)
+}
+
+// Run listens EventsChannel until ctx is done or Stop is called. It reconnects with backoff on errors.
+func (l *EventListener) Run(ctx context.Context) {
+	backoff := listenerMinBackoff
+	for {
+		err := l.listen(ctx, func() { backoff = listenerMinBackoff })
+		if l.stopped(ctx) {
+			return
+		}
+
+		l.Error(ctx, "events listener failed, reconnecting", "err", err, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, listenerMaxBackoff)
+	}
+}
+
+// Stop stops listener.
+func (l *EventListener) Stop() {
+	l.stopOnce.Do(func() { close(l.done) })
+}
+
+// Dispatch calls handlers subscribed to event entity.
+func (l *EventListener) Dispatch(ctx context.Context, e ChangeEvent) {
+	l.mu.RLock()
+	handlers := append(append([]ChangeHandler{}, l.handlers[e.Entity]...), l.handlers[AllEntities]...)
+	l.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(ctx, e)
+	}
+}
+
+// listen receives notifications until error. Function alive is called when connection is healthy.
+func (l *EventListener) listen(ctx context.Context, alive func()) error {
+	l.mu.RLock()
+	channels := append([]string{EventsChannel}, slices.Collect(maps.Keys(l.notifications))...)
+	l.mu.RUnlock()
+
+	ln := l.dbc.Listen(ctx, channels...)
+	defer ln.Close()
+
+	for !l.stopped(ctx) {
+		channel, payload, err := ln.ReceiveTimeout(ctx, listenerReceiveTimeout)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			alive()
+			continue
+		} else if err != nil {
+			return err
+		}
+		alive()
+
+		if channel != EventsChannel {
+			l.notify(ctx, channel, payload)
+			continue
+		}
+
+		var e ChangeEvent
+		if err = json.Unmarshal([]byte(payload), &e); err != nil {
+			l.Error(ctx, "invalid change event", "err", err, "payload", payload)
+			continue
+		}
+
+		l.Dispatch(ctx, e)
+	}
+
+	return nil
+}
+
+// notify calls handlers of additional channel.
+func (l *EventListener) notify(ctx context.Context, channel, payload string) {
+	l.mu.RLock()
+	handlers := l.notifications[channel]
+	l.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(ctx, payload)
+	}
+}
+
+func (l *EventListener) stopped(ctx context.Context) bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return ctx.Err() != nil
+	}
+}
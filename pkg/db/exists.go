@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+var (
+	ErrUnknownTable  = errors.New("unknown table")
+	ErrUnknownColumn = errors.New("unknown column")
+)
+
+// existsTables are tables available for RowExists by name.
+var existsTables = tablesByName(User{}, VfsFolder{}, VfsFile{}, Status{}, Job{})
+
+func tablesByName(models ...any) map[string]*orm.Table {
+	r := make(map[string]*orm.Table, len(models))
+	for _, m := range models {
+		t := orm.GetTable(reflect.TypeOf(m))
+		r[strings.Trim(string(t.SQLName), `"`)] = t
+	}
+	return r
+}
+
+// RowExists checks that table has a row with column value, row with notID primary key is skipped.
+// Soft deleted rows of tables with statusId column are skipped too, except statuses themselves.
+func RowExists(ctx context.Context, db orm.DB, table, column string, value any, notID int) (bool, error) {
+	t, ok := existsTables[table]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownTable, table)
+	}
+	if _, ok = t.FieldsMap[column]; !ok {
+		return false, fmt.Errorf("%w: %s.%s", ErrUnknownColumn, table, column)
+	}
+
+	q := db.ModelContext(ctx, reflect.New(t.Type).Interface()).
+		Where(`?TableAlias.? = ?`, pg.Ident(column), value)
+	if notID != 0 {
+		q.Where(`?TableAlias.? != ?`, t.PKs[0].Column, notID)
+	}
+	if f, ok := t.FieldsMap[StatusFilter.Field]; ok && !slices.Contains(t.PKs, f) {
+		StatusFilter.Apply(q)
+	}
+
+	return q.Exists()
+}
+
+// ExistsRepo checks rows of tables by names for validation.
+type ExistsRepo struct {
+	db orm.DB
+}
+
+// NewExistsRepo returns new ExistsRepo.
+func NewExistsRepo(db orm.DB) ExistsRepo {
+	return ExistsRepo{db: db}
+}
+
+// RowExists checks that table has a row with column value, see RowExists.
+func (er ExistsRepo) RowExists(ctx context.Context, table, column string, value any, notID int) (bool, error) {
+	return RowExists(ctx, er.db, table, column, value, notID)
+}
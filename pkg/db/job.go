@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+const (
+	JobStatePending  = "pending"
+	JobStateRunning  = "running"
+	JobStateDone     = "done"
+	JobStateFailed   = "failed"
+	JobStateCanceled = "canceled"
+
+	jobLockTimeoutError = "lock timeout: job is lost by worker"
+)
+
+var Jobs = struct {
+	Table   string
+	Columns struct {
+		ID, Kind, Args, State, UniqueKey, Attempts, MaxAttempts, RunAt, LockedAt, FinishedAt, Error, CreatedAt string
+	}
+}{
+	Table: "jobs",
+	Columns: struct {
+		ID, Kind, Args, State, UniqueKey, Attempts, MaxAttempts, RunAt, LockedAt, FinishedAt, Error, CreatedAt string
+	}{
+		ID:          "jobId",
+		Kind:        "kind",
+		Args:        "args",
+		State:       "state",
+		UniqueKey:   "uniqueKey",
+		Attempts:    "attempts",
+		MaxAttempts: "maxAttempts",
+		RunAt:       "runAt",
+		LockedAt:    "lockedAt",
+		FinishedAt:  "finishedAt",
+		Error:       "error",
+		CreatedAt:   "createdAt",
+	},
+}
+
+// Job is a background job processed by jobs.Queue.
+type Job struct {
+	tableName struct{} `pg:"jobs,alias:t,discard_unknown_columns"`
+
+	ID          int             `pg:"jobId,pk"`
+	Kind        string          `pg:"kind,use_zero"`
+	Args        json.RawMessage `pg:"args,type:jsonb"`
+	State       string          `pg:"state,use_zero"`
+	UniqueKey   *string         `pg:"uniqueKey"`
+	Attempts    int             `pg:"attempts,use_zero"`
+	MaxAttempts int             `pg:"maxAttempts,use_zero"`
+	RunAt       time.Time       `pg:"runAt"`
+	LockedAt    *time.Time      `pg:"lockedAt"`
+	FinishedAt  *time.Time      `pg:"finishedAt"`
+	Error       *string         `pg:"error"`
+	CreatedAt   time.Time       `pg:"createdAt"`
+}
+
+type JobSearch struct {
+	search
+
+	ID        *int
+	Kind      *string
+	State     *string
+	UniqueKey *string
+	IDs       []int
+	States    []string
+	RunAtFrom *time.Time
+	RunAtTo   *time.Time
+}
+
+func (js *JobSearch) Apply(query *orm.Query) *orm.Query {
+	if js == nil {
+		return query
+	}
+	if js.ID != nil {
+		js.where(query, "t", Jobs.Columns.ID, js.ID)
+	}
+	if js.Kind != nil {
+		js.where(query, "t", Jobs.Columns.Kind, js.Kind)
+	}
+	if js.State != nil {
+		js.where(query, "t", Jobs.Columns.State, js.State)
+	}
+	if js.UniqueKey != nil {
+		js.where(query, "t", Jobs.Columns.UniqueKey, js.UniqueKey)
+	}
+	if len(js.IDs) > 0 {
+		Filter{Jobs.Columns.ID, js.IDs, SearchTypeArray, false}.Apply(query)
+	}
+	if len(js.States) > 0 {
+		Filter{Jobs.Columns.State, js.States, SearchTypeArray, false}.Apply(query)
+	}
+	if js.RunAtFrom != nil {
+		Filter{Jobs.Columns.RunAt, *js.RunAtFrom, SearchTypeGE, false}.Apply(query)
+	}
+	if js.RunAtTo != nil {
+		Filter{Jobs.Columns.RunAt, *js.RunAtTo, SearchTypeLE, false}.Apply(query)
+	}
+
+	js.apply(query)
+
+	return query
+}
+
+func (js *JobSearch) Q() applier {
+	return func(query *orm.Query) (*orm.Query, error) {
+		if js == nil {
+			return query, nil
+		}
+		return js.Apply(query), nil
+	}
+}
+
+type JobRepo struct {
+	jobs Repo[Job, *JobSearch]
+}
+
+// NewJobRepo returns new repository
+func NewJobRepo(db orm.DB) JobRepo {
+	return JobRepo{
+		jobs: NewRepo[Job, *JobSearch](db, RepoOpts{
+			Sort: []SortField{{Column: Jobs.Columns.ID, Direction: SortDesc}},
+		}),
+	}
+}
+
+// WithTransaction is a function that wraps JobRepo with pg.Tx transaction.
+func (jr JobRepo) WithTransaction(tx *pg.Tx) JobRepo {
+	jr.jobs = jr.jobs.WithTransaction(tx)
+	return jr
+}
+
+// DefaultJobSort returns default sort.
+func (jr JobRepo) DefaultJobSort() OpFunc {
+	return jr.jobs.DefaultSort()
+}
+
+// JobByID is a function that returns Job by ID(PK) or nil.
+func (jr JobRepo) JobByID(ctx context.Context, id int, ops ...OpFunc) (*Job, error) {
+	return jr.jobs.ByID(ctx, id, ops...)
+}
+
+// JobsByFilters returns Job list.
+func (jr JobRepo) JobsByFilters(ctx context.Context, search *JobSearch, pager Pager, ops ...OpFunc) ([]Job, error) {
+	return jr.jobs.ByFilters(ctx, search, pager, ops...)
+}
+
+// CountJobs returns count
+func (jr JobRepo) CountJobs(ctx context.Context, search *JobSearch, ops ...OpFunc) (int, error) {
+	return jr.jobs.Count(ctx, search, ops...)
+}
+
+// EnqueueJob adds pending Job to DB. If Job has unique key and there is active job with the same key,
+// nothing is added and false is returned.
+func (jr JobRepo) EnqueueJob(ctx context.Context, job *Job) (bool, error) {
+	job.State = JobStatePending
+	res, err := jr.jobs.DB().ModelContext(ctx, job).
+		OnConflict(`("uniqueKey") WHERE "state" IN (?, ?) DO NOTHING`, JobStatePending, JobStateRunning).
+		Returning("*").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// FetchJobs locks ready jobs of given kinds, marks them as running and returns them.
+// Attempts are counted when job is finished or requeued.
+func (jr JobRepo) FetchJobs(ctx context.Context, kinds []string, limit int) (list []Job, err error) {
+	_, err = jr.jobs.DB().QueryContext(ctx, &list, `
+UPDATE "jobs" SET "state" = ?, "lockedAt" = now()
+WHERE "jobId" IN (
+	SELECT "jobId" FROM "jobs"
+	WHERE "state" = ? AND "runAt" <= now() AND "kind" IN (?)
+	ORDER BY "runAt", "jobId"
+	LIMIT ?
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *`, JobStateRunning, JobStatePending, pg.In(kinds), limit)
+
+	return
+}
+
+// FinishJob saves result of running job. Jobs changed by someone else (e.g. canceled) are not updated.
+func (jr JobRepo) FinishJob(ctx context.Context, job *Job) (bool, error) {
+	res, err := jr.jobs.DB().ModelContext(ctx, job).
+		Column(Jobs.Columns.State, Jobs.Columns.Attempts, Jobs.Columns.RunAt, Jobs.Columns.LockedAt, Jobs.Columns.FinishedAt, Jobs.Columns.Error).
+		WherePK().
+		Where(`?TableAlias."state" = ?`, JobStateRunning).
+		Update()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// RetryJob moves failed or canceled job to pending state.
+func (jr JobRepo) RetryJob(ctx context.Context, id int) (bool, error) {
+	res, err := jr.jobs.DB().ExecContext(ctx, `
+UPDATE "jobs" SET "state" = ?, "runAt" = now(), "attempts" = 0, "lockedAt" = NULL, "finishedAt" = NULL, "error" = NULL
+WHERE "jobId" = ? AND "state" IN (?, ?)`, JobStatePending, id, JobStateFailed, JobStateCanceled)
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// CancelJob cancels pending job. Running job is canceled too, but its handler is not interrupted.
+func (jr JobRepo) CancelJob(ctx context.Context, id int) (bool, error) {
+	res, err := jr.jobs.DB().ExecContext(ctx, `
+UPDATE "jobs" SET "state" = ?, "lockedAt" = NULL, "finishedAt" = now()
+WHERE "jobId" = ? AND "state" IN (?, ?)`, JobStateCanceled, id, JobStatePending, JobStateRunning)
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// TouchJob refreshes lock of running job, so it is not requeued while handler is running.
+func (jr JobRepo) TouchJob(ctx context.Context, id int) (bool, error) {
+	res, err := jr.jobs.DB().ExecContext(ctx, `UPDATE "jobs" SET "lockedAt" = now() WHERE "jobId" = ? AND "state" = ?`, id, JobStateRunning)
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// RequeueStaleJobs moves jobs which lock is not refreshed longer than timeout back to pending state.
+// Such jobs are left by crashed workers, lost run is counted as attempt and job fails when attempts are exceeded.
+func (jr JobRepo) RequeueStaleJobs(ctx context.Context, timeout time.Duration) (int, error) {
+	res, err := jr.jobs.DB().ExecContext(ctx, `
+UPDATE "jobs" SET "attempts" = "attempts" + 1, "lockedAt" = NULL, "runAt" = now(), "error" = ?,
+	"state" = CASE WHEN "attempts" + 1 >= "maxAttempts" THEN ? ELSE ? END,
+	"finishedAt" = CASE WHEN "attempts" + 1 >= "maxAttempts" THEN now() END
+WHERE "state" = ? AND "lockedAt" < now() - ? * interval '1 second'`,
+		jobLockTimeoutError, JobStateFailed, JobStatePending, JobStateRunning, timeout.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
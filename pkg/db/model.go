@@ -13,12 +13,12 @@ var Columns = struct {
 		ID, CreatedAt, Login, Password, AuthKey, LastActivityAt, StatusID string
 	}
 	VfsFile struct {
-		ID, FolderID, Title, Path, Params, IsFavorite, MimeType, FileSize, FileExists, CreatedAt, StatusID string
+		ID, FolderID, Title, Path, Params, IsFavorite, MimeType, FileSize, Hash, FileExists, ScanStatus, CreatedAt, StatusID string
 
 		Folder string
 	}
 	VfsFolder struct {
-		ID, ParentFolderID, Title, IsFavorite, CreatedAt, StatusID string
+		ID, ParentFolderID, Title, IsFavorite, IsPrivate, Quota, CreatedAt, StatusID string
 
 		ParentFolder string
 	}
@@ -35,7 +35,7 @@ var Columns = struct {
 		StatusID:       "statusId",
 	},
 	VfsFile: struct {
-		ID, FolderID, Title, Path, Params, IsFavorite, MimeType, FileSize, FileExists, CreatedAt, StatusID string
+		ID, FolderID, Title, Path, Params, IsFavorite, MimeType, FileSize, Hash, FileExists, ScanStatus, CreatedAt, StatusID string
 
 		Folder string
 	}{
@@ -47,14 +47,16 @@ var Columns = struct {
 		IsFavorite: "isFavorite",
 		MimeType:   "mimeType",
 		FileSize:   "fileSize",
+		Hash:       "hash",
 		FileExists: "fileExists",
+		ScanStatus: "scanStatus",
 		CreatedAt:  "createdAt",
 		StatusID:   "statusId",
 
 		Folder: "Folder",
 	},
 	VfsFolder: struct {
-		ID, ParentFolderID, Title, IsFavorite, CreatedAt, StatusID string
+		ID, ParentFolderID, Title, IsFavorite, IsPrivate, Quota, CreatedAt, StatusID string
 
 		ParentFolder string
 	}{
@@ -62,6 +64,8 @@ var Columns = struct {
 		ParentFolderID: "parentFolderId",
 		Title:          "title",
 		IsFavorite:     "isFavorite",
+		IsPrivate:      "isPrivate",
+		Quota:          "quota",
 		CreatedAt:      "createdAt",
 		StatusID:       "statusId",
 
@@ -123,7 +127,9 @@ type VfsFile struct {
 	IsFavorite *bool     `pg:"isFavorite"`
 	MimeType   string    `pg:"mimeType,use_zero"`
 	FileSize   *int      `pg:"fileSize"`
+	Hash       *string   `pg:"hash"`
 	FileExists bool      `pg:"fileExists,use_zero"`
+	ScanStatus *string   `pg:"scanStatus"`
 	CreatedAt  time.Time `pg:"createdAt,use_zero"`
 	StatusID   int       `pg:"statusId,use_zero"`
 
@@ -137,6 +143,8 @@ type VfsFolder struct {
 	ParentFolderID *int      `pg:"parentFolderId"`
 	Title          string    `pg:"title,use_zero"`
 	IsFavorite     *bool     `pg:"isFavorite"`
+	IsPrivate      bool      `pg:"isPrivate,use_zero"`
+	Quota          *int64    `pg:"quota"`
 	CreatedAt      time.Time `pg:"createdAt,use_zero"`
 	StatusID       int       `pg:"statusId,use_zero"`
 
@@ -143,7 +143,9 @@ type VfsFileSearch struct {
 	IsFavorite    *bool
 	MimeType      *string
 	FileSize      *int
+	Hash          *string
 	FileExists    *bool
+	ScanStatus    *string
 	CreatedAt     *time.Time
 	StatusID      *int
 	IDs           []int
@@ -181,9 +183,15 @@ func (vfs *VfsFileSearch) Apply(query *orm.Query) *orm.Query {
 	if vfs.FileSize != nil {
 		vfs.where(query, Tables.VfsFile.Alias, Columns.VfsFile.FileSize, vfs.FileSize)
 	}
+	if vfs.Hash != nil {
+		vfs.where(query, Tables.VfsFile.Alias, Columns.VfsFile.Hash, vfs.Hash)
+	}
 	if vfs.FileExists != nil {
 		vfs.where(query, Tables.VfsFile.Alias, Columns.VfsFile.FileExists, vfs.FileExists)
 	}
+	if vfs.ScanStatus != nil {
+		vfs.where(query, Tables.VfsFile.Alias, Columns.VfsFile.ScanStatus, vfs.ScanStatus)
+	}
 	if vfs.CreatedAt != nil {
 		vfs.where(query, Tables.VfsFile.Alias, Columns.VfsFile.CreatedAt, vfs.CreatedAt)
 	}
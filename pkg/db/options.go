@@ -133,6 +133,37 @@ func OnConflict(s string, params ...interface{}) OpFunc {
 	}
 }
 
+// OnConflictDoNothing adds ON CONFLICT (target) DO NOTHING statement to insert query.
+func OnConflictDoNothing(target ...string) OpFunc {
+	return func(query *orm.Query) {
+		if len(target) == 0 {
+			query.OnConflict("DO NOTHING")
+			return
+		}
+		query.OnConflict("(?) DO NOTHING", identList(target))
+	}
+}
+
+// OnConflictDoUpdate adds ON CONFLICT (target) DO UPDATE statement to insert query.
+// Columns from update are set to EXCLUDED values, if update is empty all data columns are updated.
+func OnConflictDoUpdate(target, update []string) OpFunc {
+	return func(query *orm.Query) {
+		query.OnConflict("(?) DO UPDATE", identList(target))
+		for _, col := range update {
+			query.Set("? = EXCLUDED.?", pg.Ident(col), pg.Ident(col))
+		}
+	}
+}
+
+// identList returns comma separated list of quoted identifiers.
+func identList(cols []string) types.ValueAppender {
+	idents := make([]string, len(cols))
+	for i := range cols {
+		idents[i] = string(types.AppendIdent(nil, cols[i], 1))
+	}
+	return pg.Safe(strings.Join(idents, ", "))
+}
+
 // applyOps applies operations to current orm query.
 func applyOps(q *orm.Query, ops ...OpFunc) {
 	for _, op := range ops {
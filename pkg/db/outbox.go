@@ -0,0 +1,180 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+var Outbox = struct {
+	Table   string
+	Columns struct {
+		ID, Aggregate, AggregateID, Type, Payload, ActorID, CreatedAt, Attempts, NextAttemptAt, SentAt, DeadAt, Error string
+	}
+}{
+	Table: "outboxEvents",
+	Columns: struct {
+		ID, Aggregate, AggregateID, Type, Payload, ActorID, CreatedAt, Attempts, NextAttemptAt, SentAt, DeadAt, Error string
+	}{
+		ID:            "eventId",
+		Aggregate:     "aggregate",
+		AggregateID:   "aggregateId",
+		Type:          "type",
+		Payload:       "payload",
+		ActorID:       "actorId",
+		CreatedAt:     "createdAt",
+		Attempts:      "attempts",
+		NextAttemptAt: "nextAttemptAt",
+		SentAt:        "sentAt",
+		DeadAt:        "deadAt",
+		Error:         "error",
+	},
+}
+
+// EnableOutbox is a repository option that turns on outbox hooks. Events are written only if outbox relay delivers
+// and purges them, so App enables it with relay.
+func EnableOutbox(enabled bool) RepoOption {
+	return func(opts *RepoOpts) {
+		opts.Outbox = enabled
+	}
+}
+
+// OutboxEvent is an integration event stored in the same transaction as entity change.
+type OutboxEvent struct {
+	tableName struct{} `pg:"outboxEvents,alias:t,discard_unknown_columns"`
+
+	ID            int64           `pg:"eventId,pk" json:"id"`
+	Aggregate     string          `pg:"aggregate,use_zero" json:"aggregate"`
+	AggregateID   int             `pg:"aggregateId,use_zero" json:"aggregateId"`
+	Type          string          `pg:"type,use_zero" json:"type"`
+	Payload       json.RawMessage `pg:"payload,type:jsonb" json:"payload"`
+	ActorID       *int            `pg:"actorId" json:"actorId,omitempty"`
+	CreatedAt     time.Time       `pg:"createdAt,use_zero" json:"createdAt"`
+	Attempts      int             `pg:"attempts,use_zero" json:"-"`
+	NextAttemptAt time.Time       `pg:"nextAttemptAt,use_zero" json:"-"`
+	SentAt        *time.Time      `pg:"sentAt" json:"-"`
+	DeadAt        *time.Time      `pg:"deadAt" json:"-"`
+	Error         *string         `pg:"error" json:"-"`
+}
+
+// AddOutboxEvent adds event to outbox. Use the same orm.DB as for entity change.
+func AddOutboxEvent(ctx context.Context, db orm.DB, aggregate string, aggregateID int, op Operation, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	e := &OutboxEvent{
+		Aggregate:     aggregate,
+		AggregateID:   aggregateID,
+		Type:          aggregate + "." + string(op),
+		Payload:       b,
+		ActorID:       ActorFromContext(ctx),
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+	_, err = db.ModelContext(ctx, e).Insert()
+	return err
+}
+
+// WithOutbox is a function that adds hooks which write OutboxEvent on every write, hooks are not added if outbox is
+// not enabled by RepoOpts.Outbox. Function payload converts entity to event payload, if it is nil entity is used as is.
+func (r Repo[T, S]) WithOutbox(payload func(*T) any) Repo[T, S] {
+	if !r.outbox {
+		return r
+	} else if payload == nil {
+		payload = func(obj *T) any { return obj }
+	}
+
+	add := func(op Operation) HookFunc[T] {
+		return func(ctx context.Context, db orm.DB, obj *T) error {
+			return AddOutboxEvent(ctx, db, r.Table(), r.id(obj), op, payload(obj))
+		}
+	}
+
+	return r.
+		WithHook(HookAfterInsert, add(OperationInsert)).
+		WithHook(HookAfterUpdate, add(OperationUpdate)).
+		WithHook(HookAfterDelete, add(OperationDelete))
+}
+
+type OutboxRepo struct {
+	db orm.DB
+}
+
+// NewOutboxRepo returns new repository
+func NewOutboxRepo(db orm.DB) OutboxRepo {
+	return OutboxRepo{db: db}
+}
+
+// WithTransaction is a function that wraps OutboxRepo with pg.Tx transaction.
+func (or OutboxRepo) WithTransaction(tx *pg.Tx) OutboxRepo {
+	or.db = tx
+	return or
+}
+
+// ClaimOutboxEvents returns ready to send events and postpones their next attempt by lease, so events are sent
+// without open transaction and claimed again only if relay fails to save delivery state in lease time.
+// Only the oldest pending event of every aggregate is returned, so events of one aggregate are delivered in order.
+// Dead events don't block aggregate.
+func (or OutboxRepo) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) (list []OutboxEvent, err error) {
+	_, err = or.db.QueryContext(ctx, &list, `
+UPDATE "outboxEvents" SET "nextAttemptAt" = now() + ? * interval '1 millisecond'
+WHERE "eventId" IN (
+	SELECT t."eventId" FROM "outboxEvents" t
+	WHERE t."sentAt" IS NULL AND t."deadAt" IS NULL AND t."nextAttemptAt" <= now()
+	  AND NOT EXISTS (
+		SELECT 1 FROM "outboxEvents" p
+		WHERE p."aggregate" = t."aggregate" AND p."aggregateId" = t."aggregateId"
+		  AND p."eventId" < t."eventId" AND p."sentAt" IS NULL AND p."deadAt" IS NULL
+	  )
+	ORDER BY t."eventId"
+	LIMIT ?
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *`, lease.Milliseconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(list, func(a, b OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+	return list, nil
+}
+
+// UpdateOutboxEvent saves delivery state of event.
+func (or OutboxRepo) UpdateOutboxEvent(ctx context.Context, e *OutboxEvent) error {
+	_, err := or.db.ModelContext(ctx, e).
+		Column(Outbox.Columns.Attempts, Outbox.Columns.NextAttemptAt, Outbox.Columns.SentAt, Outbox.Columns.DeadAt, Outbox.Columns.Error).
+		WherePK().
+		Update()
+	return err
+}
+
+// DeleteOutboxEvents deletes sent and dead events created before given time.
+func (or OutboxRepo) DeleteOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	res, err := or.db.ModelContext(ctx, (*OutboxEvent)(nil)).
+		Where(`?TableAlias.? < ?`, pg.Ident(Outbox.Columns.CreatedAt), before).
+		Where(`(?TableAlias.? IS NOT NULL OR ?TableAlias.? IS NOT NULL)`, pg.Ident(Outbox.Columns.SentAt), pg.Ident(Outbox.Columns.DeadAt)).
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// OutboxLag returns age of the oldest pending event and count of pending events.
+func (or OutboxRepo) OutboxLag(ctx context.Context) (lag time.Duration, pending int, err error) {
+	var seconds float64
+	_, err = or.db.QueryOneContext(ctx, pg.Scan(&seconds, &pending), `
+SELECT COALESCE(EXTRACT(EPOCH FROM now() - min("createdAt")), 0), count(*)
+FROM "outboxEvents" WHERE "sentAt" IS NULL AND "deadAt" IS NULL`)
+
+	return time.Duration(seconds * float64(time.Second)), pending, err
+}
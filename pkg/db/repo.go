@@ -0,0 +1,318 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+const (
+	columnCreatedAt = "createdAt"
+	columnStatusID  = "statusId"
+)
+
+// txRunner is implemented by *pg.DB and DB.
+type txRunner interface {
+	RunInTransaction(ctx context.Context, fn func(*pg.Tx) error) error
+}
+
+// HookEvent is a repository write event that hooks could be attached to.
+type HookEvent int
+
+const (
+	HookBeforeInsert HookEvent = iota
+	HookAfterInsert
+	HookBeforeUpdate
+	HookAfterUpdate
+	HookBeforeDelete
+	HookAfterDelete
+)
+
+// HookFunc is a function that is called by Repo on write event. It receives the same orm.DB as the query.
+// For delete events obj contains only primary key and status.
+type HookFunc[T any] func(ctx context.Context, db orm.DB, obj *T) error
+
+// RepoOpts is a set of defaults applied by Repo to every query.
+type RepoOpts struct {
+	// Filters are base filters for select and count queries.
+	Filters []Filter
+	// Sort is default sort, see Repo.DefaultSort.
+	Sort []SortField
+	// Join is a list of columns and relations for full select, see Repo.Full.
+	Join []string
+	// Outbox turns on hooks added by Repo.WithOutbox.
+	Outbox bool
+}
+
+// RepoOption is an option of repository constructors like NewCommonRepo, it is applied to RepoOpts of every table.
+type RepoOption func(*RepoOpts)
+
+// with returns opts with applied options.
+func (opts RepoOpts) with(options []RepoOption) RepoOpts {
+	for _, o := range options {
+		o(&opts)
+	}
+	return opts
+}
+
+// Repo is a generic repository for table T with search S.
+type Repo[T any, S Searcher] struct {
+	db      orm.DB
+	table   *orm.Table
+	filters []Filter
+	sort    []SortField
+	join    []string
+	outbox  bool
+	hooks   map[HookEvent][]HookFunc[T]
+}
+
+// NewRepo returns new generic repository for model T.
+func NewRepo[T any, S Searcher](db orm.DB, opts RepoOpts) Repo[T, S] {
+	return Repo[T, S]{
+		db:      db,
+		table:   orm.GetTable(reflect.TypeFor[T]()),
+		filters: opts.Filters,
+		sort:    opts.Sort,
+		join:    opts.Join,
+		outbox:  opts.Outbox,
+	}
+}
+
+// WithTransaction is a function that wraps Repo with pg.Tx transaction.
+func (r Repo[T, S]) WithTransaction(tx *pg.Tx) Repo[T, S] {
+	r.db = tx
+	return r
+}
+
+// WithEnabledOnly is a function that adds "statusId"=1 as base filter.
+func (r Repo[T, S]) WithEnabledOnly() Repo[T, S] {
+	r.filters = append(slices.Clone(r.filters), StatusEnabledFilter)
+	return r
+}
+
+// WithHook is a function that adds hook for given event. Hooks are called in order of registration.
+func (r Repo[T, S]) WithHook(event HookEvent, fn HookFunc[T]) Repo[T, S] {
+	hooks := make(map[HookEvent][]HookFunc[T], len(r.hooks)+1)
+	for e, fns := range r.hooks {
+		hooks[e] = slices.Clone(fns)
+	}
+	hooks[event] = append(hooks[event], fn)
+	r.hooks = hooks
+
+	return r
+}
+
+// DB returns current orm.DB of repository.
+func (r Repo[T, S]) DB() orm.DB {
+	return r.db
+}
+
+// Table returns table name of T.
+func (r Repo[T, S]) Table() string {
+	return strings.Trim(string(r.table.SQLName), `"`)
+}
+
+// Full returns full joins with all columns.
+func (r Repo[T, S]) Full() OpFunc {
+	return WithColumns(r.join...)
+}
+
+// DefaultSort returns default sort.
+func (r Repo[T, S]) DefaultSort() OpFunc {
+	return WithSort(r.sort...)
+}
+
+// ByID is a function that returns T by ID or nil.
+func (r Repo[T, S]) ByID(ctx context.Context, id int, ops ...OpFunc) (*T, error) {
+	var search S
+	return r.One(ctx, search, append([]OpFunc{r.wherePK(id)}, ops...)...)
+}
+
+// One is a function that returns one T by filters. It could return pg.ErrMultiRows.
+func (r Repo[T, S]) One(ctx context.Context, search S, ops ...OpFunc) (*T, error) {
+	obj := new(T)
+	err := buildQuery(ctx, r.db, obj, search, r.filters, PagerTwo, ops...).Select()
+
+	if errors.Is(err, pg.ErrMultiRows) {
+		return nil, err
+	} else if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	}
+
+	return obj, err
+}
+
+// ByFilters returns T list.
+func (r Repo[T, S]) ByFilters(ctx context.Context, search S, pager Pager, ops ...OpFunc) (list []T, err error) {
+	err = buildQuery(ctx, r.db, &list, search, r.filters, pager, ops...).Select()
+	return
+}
+
+// Count returns count of T.
+func (r Repo[T, S]) Count(ctx context.Context, search S, ops ...OpFunc) (int, error) {
+	return buildQuery(ctx, r.db, new(T), search, r.filters, PagerOne, ops...).Count()
+}
+
+// ForEach calls fn for every T found by filters without loading all rows into memory.
+func (r Repo[T, S]) ForEach(ctx context.Context, search S, fn func(*T) error, ops ...OpFunc) error {
+	q := filterQuery(r.db.ModelContext(ctx, new(T)), search, r.filters)
+	applyOps(q, ops...)
+	return q.ForEach(fn)
+}
+
+// Add adds T to DB. Column "createdAt" is filled by DB unless ops name it explicitly.
+func (r Repo[T, S]) Add(ctx context.Context, obj *T, ops ...OpFunc) (*T, error) {
+	err := r.run(ctx, HookBeforeInsert, HookAfterInsert, obj, func(db orm.DB) error {
+		q := db.ModelContext(ctx, obj)
+		applyOps(q, ops...)
+		r.excludeAutoColumns(q, ops...)
+		_, err := q.Insert()
+		return err
+	})
+
+	return obj, err
+}
+
+// Update updates T in DB by primary key. Column "createdAt" is not updated unless ops name it explicitly.
+func (r Repo[T, S]) Update(ctx context.Context, obj *T, ops ...OpFunc) (bool, error) {
+	var updated bool
+	err := r.run(ctx, HookBeforeUpdate, HookAfterUpdate, obj, func(db orm.DB) error {
+		q := db.ModelContext(ctx, obj).WherePK()
+		applyOps(q, ops...)
+		r.excludeAutoColumns(q, ops...)
+		res, err := q.Update()
+		if err != nil {
+			return err
+		}
+		updated = res.RowsAffected() > 0
+		return nil
+	})
+
+	return updated, err
+}
+
+// Delete sets statusId to deleted in DB. Tables without statusId column are deleted physically.
+func (r Repo[T, S]) Delete(ctx context.Context, id int) (bool, error) {
+	var deleted bool
+	obj := new(T)
+	v := reflect.ValueOf(obj).Elem()
+	r.pk().Value(v).SetInt(int64(id))
+
+	soft := r.table.HasField(columnStatusID)
+	if soft {
+		r.table.FieldsMap[columnStatusID].Value(v).SetInt(StatusDeleted)
+	}
+
+	err := r.run(ctx, HookBeforeDelete, HookAfterDelete, obj, func(db orm.DB) error {
+		var (
+			res orm.Result
+			err error
+		)
+		if soft {
+			res, err = db.ModelContext(ctx, obj).WherePK().Column(columnStatusID).Update()
+		} else {
+			res, err = db.ModelContext(ctx, obj).WherePK().Delete()
+		}
+		if err != nil {
+			return err
+		}
+		deleted = res.RowsAffected() > 0
+		return nil
+	})
+
+	return deleted, err
+}
+
+// run executes fn between before and after hooks. If hooks are set, everything is executed in one transaction.
+func (r Repo[T, S]) run(ctx context.Context, before, after HookEvent, obj *T, fn func(orm.DB) error) error {
+	return r.inTx(ctx, len(r.hooks[before])+len(r.hooks[after]) > 0, func(db orm.DB) error {
+		if err := r.callHooks(ctx, db, before, obj); err != nil {
+			return err
+		}
+		if err := fn(db); err != nil {
+			return err
+		}
+		return r.callHooks(ctx, db, after, obj)
+	})
+}
+
+// inTx executes fn in new transaction if needed and repository is not already in transaction.
+func (r Repo[T, S]) inTx(ctx context.Context, needed bool, fn func(orm.DB) error) error {
+	if _, ok := r.db.(*pg.Tx); ok || !needed {
+		return fn(r.db)
+	}
+
+	dbc, ok := r.db.(txRunner)
+	if !ok {
+		return fn(r.db)
+	}
+
+	return dbc.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		return fn(tx)
+	})
+}
+
+// callHooks calls all hooks for event until first error.
+func (r Repo[T, S]) callHooks(ctx context.Context, db orm.DB, event HookEvent, obj *T) error {
+	for _, fn := range r.hooks[event] {
+		if err := fn(ctx, db, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// id returns primary key value of obj.
+func (r Repo[T, S]) id(obj *T) int {
+	return int(r.pk().Value(reflect.ValueOf(obj).Elem()).Int())
+}
+
+// excludeAutoColumns excludes columns filled by DB defaults from q built with ops, unless ops name them explicitly.
+// Ops are replayed on probe queries: the column is excluded only if it is still selected after ops and
+// ops do not add it back to a query without it.
+func (r Repo[T, S]) excludeAutoColumns(q *orm.Query, ops ...OpFunc) {
+	if !r.table.HasField(columnCreatedAt) {
+		return
+	}
+
+	selected := func(exclude bool) bool {
+		pq := orm.NewQuery(nil, new(T))
+		if exclude {
+			pq.ExcludeColumn(columnCreatedAt)
+		}
+		applyOps(pq, ops...)
+		pq.ExcludeColumn(columnCreatedAt)
+		_, err := orm.NewSelectQuery(pq).AppendQuery(orm.NewFormatter(), nil)
+		return err == nil
+	}
+
+	if selected(false) && !selected(true) {
+		q.ExcludeColumn(columnCreatedAt)
+	}
+}
+
+// allColumns names all columns of T explicitly, so columns filled by DB defaults are written too.
+func (r Repo[T, S]) allColumns() OpFunc {
+	return func(query *orm.Query) {
+		for _, f := range r.table.Fields {
+			query.Column(f.SQLName)
+		}
+	}
+}
+
+// wherePK returns filter by primary key.
+func (r Repo[T, S]) wherePK(id int) OpFunc {
+	return func(query *orm.Query) {
+		Filter{Field: r.pk().SQLName, Value: id}.Apply(query)
+	}
+}
+
+// pk returns primary key field of T.
+func (r Repo[T, S]) pk() *orm.Field {
+	return r.table.PKs[0]
+}
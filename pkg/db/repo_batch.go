@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// DefaultBatchSize is a default count of rows in one batch query.
+const DefaultBatchSize = 1000
+
+var (
+	ErrEmptySearch = errors.New("search is required for bulk update")
+	ErrNoColumns   = errors.New("columns are required for bulk update")
+)
+
+// AddMany adds list of T to DB in chunks of batchSize rows. All chunks are inserted in one transaction.
+// Insert hooks are called for every element of list.
+func (r Repo[T, S]) AddMany(ctx context.Context, list []T, batchSize int, ops ...OpFunc) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	return r.inTx(ctx, true, func(db orm.DB) error {
+		for i := range list {
+			if err := r.callHooks(ctx, db, HookBeforeInsert, &list[i]); err != nil {
+				return err
+			}
+		}
+
+		err := chunks(list, batchSize, func(chunk []T) error {
+			q := db.ModelContext(ctx, &chunk)
+			applyOps(q, ops...)
+			r.excludeAutoColumns(q, ops...)
+			_, err := q.Insert()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for i := range list {
+			if err := r.callHooks(ctx, db, HookAfterInsert, &list[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Upsert inserts list of T or updates columns from update on conflict by target columns.
+// If update is empty, all data columns are updated. Use Columns for target and update, e.g.
+//
+//	repo.Upsert(ctx, users, []string{Columns.User.Login}, []string{Columns.User.StatusID}, DefaultBatchSize)
+//
+// Insert hooks are called for every element of list.
+func (r Repo[T, S]) Upsert(ctx context.Context, list []T, target, update []string, batchSize int) error {
+	if len(target) == 0 {
+		target = []string{r.pk().SQLName}
+	}
+
+	ops := []OpFunc{OnConflictDoUpdate(target, update)}
+	if slices.Contains(update, columnCreatedAt) {
+		ops = append(ops, r.allColumns())
+	}
+
+	return r.AddMany(ctx, list, batchSize, ops...)
+}
+
+// UpdateByFilters sets columns from obj to all rows matched by search and base filters. Search must have at least one
+// condition and columns must be set explicitly. It returns IDs of updated rows.
+// After update hooks are called for every updated row with copy of obj.
+func (r Repo[T, S]) UpdateByFilters(ctx context.Context, search S, obj *T, columns ...string) ([]int, error) {
+	if len(columns) == 0 {
+		return nil, ErrNoColumns
+	}
+	if ok, err := hasConditions[T](search); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrEmptySearch
+	}
+
+	var ids []int
+	err := r.inTx(ctx, len(r.hooks[HookAfterUpdate]) > 0, func(db orm.DB) error {
+		q := db.ModelContext(ctx, obj).Column(columns...)
+		for _, filter := range r.filters {
+			filter.Apply(q)
+		}
+		search.Apply(q)
+
+		if _, err := q.Returning("?", pg.Ident(r.pk().SQLName)).Update(&ids); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			item := *obj
+			r.pk().Value(reflect.ValueOf(&item).Elem()).SetInt(int64(id))
+			if err := r.callHooks(ctx, db, HookAfterUpdate, &item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return ids, err
+}
+
+// hasConditions checks that search adds conditions to query of T, queries with and without search are compared.
+func hasConditions[T any, S Searcher](search S) (bool, error) {
+	if reflect.ValueOf(search).IsNil() {
+		return false, nil
+	}
+
+	render := func(apply func(*orm.Query)) (string, error) {
+		q := orm.NewQuery(nil, new(T))
+		apply(q)
+		b, err := orm.NewSelectQuery(q).AppendQuery(orm.NewFormatter(), nil)
+		return string(b), err
+	}
+
+	empty, err := render(func(*orm.Query) {})
+	if err != nil {
+		return false, err
+	}
+	filtered, err := render(func(q *orm.Query) { search.Apply(q) })
+
+	return filtered != empty, err
+}
+
+// chunks calls fn for every part of list with size less or equal batchSize.
+func chunks[T any](list []T, batchSize int, fn func([]T) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	for i := 0; i < len(list); i += batchSize {
+		end := min(i+batchSize, len(list))
+		if err := fn(list[i:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRepo_chunks(t *testing.T) {
+	Convey("Test chunks", t, func() {
+		list := []int{1, 2, 3, 4, 5}
+
+		Convey("by two", func() {
+			var parts [][]int
+			err := chunks(list, 2, func(c []int) error {
+				parts = append(parts, c)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(parts, ShouldResemble, [][]int{{1, 2}, {3, 4}, {5}})
+		})
+
+		Convey("default size", func() {
+			var parts [][]int
+			err := chunks(list, 0, func(c []int) error {
+				parts = append(parts, c)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(parts, ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestRepo_UpdateByFilters(t *testing.T) {
+	Convey("Test bulk update arguments", t, func() {
+		ctx, repo := t.Context(), NewRepo[User, *UserSearch](nil, RepoOpts{})
+		obj := &User{StatusID: StatusDisabled}
+
+		_, err := repo.UpdateByFilters(ctx, &UserSearch{Login: new(string)}, obj)
+		So(err, ShouldEqual, ErrNoColumns)
+
+		for _, search := range []*UserSearch{nil, {}, {IDs: []int{}}} {
+			_, err = repo.UpdateByFilters(ctx, search, obj, Columns.User.StatusID)
+			So(err, ShouldEqual, ErrEmptySearch)
+		}
+
+		ok, err := hasConditions[User](&UserSearch{IDs: []int{1}})
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+	})
+}
+
+func TestOnConflictDoUpdate(t *testing.T) {
+	Convey("Test upsert query", t, func() {
+		users := []User{{Login: "admin", StatusID: StatusEnabled}}
+		q := orm.NewQuery(nil, &users)
+		OnConflictDoUpdate([]string{Columns.User.Login}, []string{Columns.User.StatusID, Columns.User.Password})(q)
+		q.ExcludeColumn(Columns.User.CreatedAt)
+
+		b, err := orm.NewInsertQuery(q).AppendQuery(orm.NewFormatter(), nil)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldContainSubstring, `ON CONFLICT ("login") DO UPDATE SET "statusId" = EXCLUDED."statusId", "password" = EXCLUDED."password"`)
+		So(string(b), ShouldNotContainSubstring, `"createdAt"`)
+	})
+
+	Convey("Test do nothing query", t, func() {
+		q := orm.NewQuery(nil, &User{})
+		OnConflictDoNothing(Columns.User.ID, Columns.User.Login)(q)
+
+		b, err := orm.NewInsertQuery(q).AppendQuery(orm.NewFormatter(), nil)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldContainSubstring, `ON CONFLICT ("userId", "login") DO NOTHING`)
+	})
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v10/orm"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRepo_excludeAutoColumns(t *testing.T) {
+	Convey("Test auto columns in insert query", t, func() {
+		repo := NewRepo[User, *UserSearch](nil, RepoOpts{})
+		insert := func(ops ...OpFunc) string {
+			q := orm.NewQuery(nil, &User{Login: "admin"})
+			applyOps(q, ops...)
+			repo.excludeAutoColumns(q, ops...)
+			b, err := orm.NewInsertQuery(q).AppendQuery(orm.NewFormatter(), nil)
+			So(err, ShouldBeNil)
+			return string(b)
+		}
+
+		Convey("without ops", func() {
+			So(insert(), ShouldNotContainSubstring, `"createdAt"`)
+		})
+
+		Convey("with ops not naming columns", func() {
+			So(insert(OnConflictDoNothing(Columns.User.Login)), ShouldNotContainSubstring, `"createdAt"`)
+			So(insert(WithoutColumns(Columns.User.Password)), ShouldNotContainSubstring, `"createdAt"`)
+		})
+
+		Convey("with explicit columns", func() {
+			b := insert(WithColumns(Columns.User.Login))
+			So(b, ShouldContainSubstring, `"login"`)
+			So(b, ShouldNotContainSubstring, `"createdAt"`)
+
+			So(insert(WithColumns(Columns.User.Login, Columns.User.CreatedAt)), ShouldContainSubstring, `"createdAt"`)
+			So(insert(repo.allColumns()), ShouldContainSubstring, `"createdAt"`)
+		})
+	})
+}
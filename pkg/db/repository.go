@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+)
+
+// Interfaces of this file are used by services instead of repositories, in-memory implementations for unit tests are in pkg/db/test.
+
+// UserRepository is a set of User methods used by services. It is implemented by CommonRepo.
+type UserRepository interface {
+	DefaultUserSort() OpFunc
+	FullUser() OpFunc
+
+	UserByID(ctx context.Context, id int, ops ...OpFunc) (*User, error)
+	OneUser(ctx context.Context, search *UserSearch, ops ...OpFunc) (*User, error)
+	UsersByFilters(ctx context.Context, search *UserSearch, pager Pager, ops ...OpFunc) ([]User, error)
+	CountUsers(ctx context.Context, search *UserSearch, ops ...OpFunc) (int, error)
+	ForEachUser(ctx context.Context, search *UserSearch, fn func(*User) error, ops ...OpFunc) error
+
+	AddUser(ctx context.Context, user *User, ops ...OpFunc) (*User, error)
+	UpdateUser(ctx context.Context, user *User, ops ...OpFunc) (bool, error)
+	DeleteUser(ctx context.Context, id int) (bool, error)
+
+	AuthenticateUser(ctx context.Context, dbu *User, authKey string) (bool, error)
+	UpdateUserActivity(ctx context.Context, dbu *User) (bool, error)
+	UpdateUserPassword(ctx context.Context, dbu *User) (bool, error)
+	EnabledUserByAuthKey(ctx context.Context, authKey string) (*User, error)
+	EnabledUserByLogin(ctx context.Context, login string) (*User, error)
+}
+
+// JobRepository is a set of Job methods used by services. It is implemented by JobRepo.
+type JobRepository interface {
+	DefaultJobSort() OpFunc
+
+	JobByID(ctx context.Context, id int, ops ...OpFunc) (*Job, error)
+	JobsByFilters(ctx context.Context, search *JobSearch, pager Pager, ops ...OpFunc) ([]Job, error)
+	CountJobs(ctx context.Context, search *JobSearch, ops ...OpFunc) (int, error)
+
+	RetryJob(ctx context.Context, id int) (bool, error)
+	CancelJob(ctx context.Context, id int) (bool, error)
+}
+
+// RevisionRepository is a set of Revision methods used by services. It is implemented by RevisionRepo.
+type RevisionRepository interface {
+	RevisionsByEntity(ctx context.Context, entity string, entityID int, pager Pager) ([]Revision, error)
+	RevisionByNumber(ctx context.Context, entity string, entityID, revision int) (*Revision, error)
+}
+
+// StatusRepository is a set of Status methods used by services. It is implemented by StatusRepo.
+type StatusRepository interface {
+	Statuses(ctx context.Context) ([]Status, error)
+}
+
+// ExistsRepository checks rows for unique and exists validation tags. It is implemented by ExistsRepo.
+type ExistsRepository interface {
+	RowExists(ctx context.Context, table, column string, value any, notID int) (bool, error)
+}
+
+// VfsFolderRepository is a set of vfs methods for folders management with change events. It is implemented by VfsRepo.
+type VfsFolderRepository interface {
+	VfsFolderByID(ctx context.Context, id int, ops ...OpFunc) (*VfsFolder, error)
+	VfsFolderBranch(ctx context.Context, folderID int) ([]VfsFolder, error)
+	AddVfsFolder(ctx context.Context, vfsFolder *VfsFolder, ops ...OpFunc) (*VfsFolder, error)
+	UpdateVfsFolder(ctx context.Context, vfsFolder *VfsFolder, ops ...OpFunc) (bool, error)
+	DeleteVfsFolder(ctx context.Context, id int) (bool, error)
+}
+
+// VfsAccessRepository is a set of vfs methods for private media access. It is implemented by VfsRepo.
+type VfsAccessRepository interface {
+	IsPrivateFolder(ctx context.Context, folderID int) (bool, error)
+	IsPrivateFile(ctx context.Context, path string) (bool, error)
+	SetVfsFolderPrivate(ctx context.Context, folderID int, private bool) (bool, error)
+}
+
+// VfsQuotaRepository is a set of vfs methods for storage usage and quotas. It is implemented by VfsRepo.
+type VfsQuotaRepository interface {
+	VfsFolderUsage(ctx context.Context, folderID int) (VfsUsage, error)
+	VfsFolderQuotas(ctx context.Context, folderID int) ([]VfsFolder, error)
+	SetVfsFolderQuota(ctx context.Context, folderID int, quota *int64) (bool, error)
+	VfsNamespaceUsage(ctx context.Context) ([]VfsUsage, error)
+}
+
+// VfsScanRepository is a set of vfs methods for content scan statuses. It is implemented by VfsRepo.
+type VfsScanRepository interface {
+	SetVfsFileScanStatus(ctx context.Context, fileID int, status string) (bool, error)
+	SetVfsHashScanStatus(ctx context.Context, ns, hash, status string) (bool, error)
+	PendingVfsFiles(ctx context.Context, limit int) ([]VfsFile, error)
+	PendingVfsHashes(ctx context.Context, limit int) ([]VfsHashScan, error)
+}
+
+// VfsDedupRepository is a set of vfs methods for files deduplication, files with the same content share one path.
+// It is implemented by VfsRepo.
+type VfsDedupRepository interface {
+	VfsFilesByFilters(ctx context.Context, search *VfsFileSearch, pager Pager, ops ...OpFunc) ([]VfsFile, error)
+	CountVfsFiles(ctx context.Context, search *VfsFileSearch, ops ...OpFunc) (int, error)
+	AddVfsFile(ctx context.Context, vfsFile *VfsFile, ops ...OpFunc) (*VfsFile, error)
+	SetVfsFileHash(ctx context.Context, fileID int, hash string) (bool, error)
+	DeleteVfsFile(ctx context.Context, id int) (bool, error)
+	VfsDuplicates(ctx context.Context, limit int) ([]VfsDuplicate, error)
+}
+
+var (
+	_ UserRepository      = CommonRepo{}
+	_ JobRepository       = JobRepo{}
+	_ RevisionRepository  = RevisionRepo{}
+	_ StatusRepository    = StatusRepo{}
+	_ VfsAccessRepository = VfsRepo{}
+	_ VfsQuotaRepository  = VfsRepo{}
+	_ VfsScanRepository   = VfsRepo{}
+	_ VfsDedupRepository  = VfsRepo{}
+)
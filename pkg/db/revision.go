@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+var Revisions = struct {
+	Table   string
+	Columns struct {
+		ID, Entity, EntityID, Revision, Data, ActorID, CreatedAt string
+	}
+}{
+	Table: "revisions",
+	Columns: struct {
+		ID, Entity, EntityID, Revision, Data, ActorID, CreatedAt string
+	}{
+		ID:        "revisionId",
+		Entity:    "entity",
+		EntityID:  "entityId",
+		Revision:  "revision",
+		Data:      "data",
+		ActorID:   "actorId",
+		CreatedAt: "createdAt",
+	},
+}
+
+// Revision is a snapshot of entity after write. Revisions are numbered from 1 for every entity.
+type Revision struct {
+	tableName struct{} `pg:"revisions,alias:t,discard_unknown_columns"`
+
+	ID        int             `pg:"revisionId,pk"`
+	Entity    string          `pg:"entity,use_zero"`
+	EntityID  int             `pg:"entityId,use_zero"`
+	Revision  int             `pg:"revision,use_zero"`
+	Data      json.RawMessage `pg:"data,type:jsonb"`
+	ActorID   *int            `pg:"actorId"`
+	CreatedAt time.Time       `pg:"createdAt,use_zero"`
+}
+
+type RevisionSearch struct {
+	search
+
+	Entity   *string
+	EntityID *int
+	Revision *int
+}
+
+func (rs *RevisionSearch) Apply(query *orm.Query) *orm.Query {
+	if rs == nil {
+		return query
+	}
+	if rs.Entity != nil {
+		rs.where(query, "t", Revisions.Columns.Entity, rs.Entity)
+	}
+	if rs.EntityID != nil {
+		rs.where(query, "t", Revisions.Columns.EntityID, rs.EntityID)
+	}
+	if rs.Revision != nil {
+		rs.where(query, "t", Revisions.Columns.Revision, rs.Revision)
+	}
+
+	rs.apply(query)
+
+	return query
+}
+
+func (rs *RevisionSearch) Q() applier {
+	return func(query *orm.Query) (*orm.Query, error) {
+		if rs == nil {
+			return query, nil
+		}
+		return rs.Apply(query), nil
+	}
+}
+
+// AddRevision adds snapshot of entity as the next revision. Snapshot equal to the last revision is skipped,
+// so writes of excluded fields (e.g. last activity) don't produce revisions. Use the same orm.DB as for entity change,
+// revisions of entity are numbered under transaction lock.
+func AddRevision(ctx context.Context, db orm.DB, entity string, entityID int, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err = db.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext(?), ?)`, entity, entityID); err != nil {
+		return err
+	}
+
+	last := &Revision{}
+	err = db.ModelContext(ctx, last).
+		Where(`?TableAlias.? = ?`, pg.Ident(Revisions.Columns.Entity), entity).
+		Where(`?TableAlias.? = ?`, pg.Ident(Revisions.Columns.EntityID), entityID).
+		OrderExpr(`?TableAlias.? DESC`, pg.Ident(Revisions.Columns.Revision)).
+		Limit(1).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		last = nil
+	} else if err != nil {
+		return err
+	}
+
+	rev := &Revision{
+		Entity:    entity,
+		EntityID:  entityID,
+		Revision:  1,
+		Data:      b,
+		ActorID:   ActorFromContext(ctx),
+		CreatedAt: time.Now(),
+	}
+	if last != nil {
+		if same, er := jsonEqual(last.Data, b); er != nil || same {
+			return er
+		}
+		rev.Revision = last.Revision + 1
+	}
+
+	_, err = db.ModelContext(ctx, rev).Insert()
+	return err
+}
+
+// WithRevisions is a function that adds hooks which save full entity as Revision after insert and update.
+// Function snapshot converts entity to stored data, if it is nil entity is used as is.
+// Entity is reloaded before snapshot, because update could be partial.
+func (r Repo[T, S]) WithRevisions(snapshot func(*T) any) Repo[T, S] {
+	if snapshot == nil {
+		snapshot = func(obj *T) any { return obj }
+	}
+
+	add := func(ctx context.Context, db orm.DB, obj *T) error {
+		full := new(T)
+		r.pk().Value(reflect.ValueOf(full).Elem()).SetInt(int64(r.id(obj)))
+		if err := db.ModelContext(ctx, full).WherePK().Select(); err != nil {
+			return err
+		}
+		return AddRevision(ctx, db, r.Table(), r.id(obj), snapshot(full))
+	}
+
+	return r.
+		WithHook(HookAfterInsert, add).
+		WithHook(HookAfterUpdate, add)
+}
+
+// jsonEqual compares JSON documents regardless of formatting and keys order.
+func jsonEqual(a, b []byte) (bool, error) {
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(va, vb), nil
+}
+
+type RevisionRepo struct {
+	revisions Repo[Revision, *RevisionSearch]
+}
+
+// NewRevisionRepo returns new repository
+func NewRevisionRepo(db orm.DB) RevisionRepo {
+	return RevisionRepo{
+		revisions: NewRepo[Revision, *RevisionSearch](db, RepoOpts{
+			Sort: []SortField{{Column: Revisions.Columns.Revision, Direction: SortDesc}},
+		}),
+	}
+}
+
+// WithTransaction is a function that wraps RevisionRepo with pg.Tx transaction.
+func (rr RevisionRepo) WithTransaction(tx *pg.Tx) RevisionRepo {
+	rr.revisions = rr.revisions.WithTransaction(tx)
+	return rr
+}
+
+// RevisionsByEntity returns revisions of entity, the latest first.
+func (rr RevisionRepo) RevisionsByEntity(ctx context.Context, entity string, entityID int, pager Pager) ([]Revision, error) {
+	return rr.revisions.ByFilters(ctx, &RevisionSearch{Entity: &entity, EntityID: &entityID}, pager, rr.revisions.DefaultSort())
+}
+
+// RevisionByNumber returns revision of entity by its number or nil.
+func (rr RevisionRepo) RevisionByNumber(ctx context.Context, entity string, entityID, revision int) (*Revision, error) {
+	return rr.revisions.One(ctx, &RevisionSearch{Entity: &entity, EntityID: &entityID, Revision: &revision})
+}
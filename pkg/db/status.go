@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+var Statuses = struct {
+	Table   string
+	Columns struct {
+		ID, Title, Alias, Titles string
+	}
+}{
+	Table: "statuses",
+	Columns: struct {
+		ID, Title, Alias, Titles string
+	}{
+		ID:     "statusId",
+		Title:  "title",
+		Alias:  "alias",
+		Titles: "titles",
+	},
+}
+
+// Status is an entity status. Title is default, Titles are localized titles by language, e.g. "en".
+type Status struct {
+	tableName struct{} `pg:"statuses,alias:t,discard_unknown_columns"`
+
+	ID     int               `pg:"statusId,pk"`
+	Title  string            `pg:"title,use_zero"`
+	Alias  string            `pg:"alias,use_zero"`
+	Titles map[string]string `pg:"titles,type:jsonb"`
+}
+
+// LocalizedTitle returns title in the first of languages that status has, or default title.
+func (s Status) LocalizedTitle(languages ...string) string {
+	for _, lang := range languages {
+		if t, ok := s.Titles[lang]; ok && t != "" {
+			return t
+		}
+	}
+	return s.Title
+}
+
+type StatusSearch struct {
+	search
+
+	ID    *int
+	Alias *string
+	IDs   []int
+}
+
+func (ss *StatusSearch) Apply(query *orm.Query) *orm.Query {
+	if ss == nil {
+		return query
+	}
+	if ss.ID != nil {
+		ss.where(query, "t", Statuses.Columns.ID, ss.ID)
+	}
+	if ss.Alias != nil {
+		ss.where(query, "t", Statuses.Columns.Alias, ss.Alias)
+	}
+	if len(ss.IDs) > 0 {
+		Filter{Statuses.Columns.ID, ss.IDs, SearchTypeArray, false}.Apply(query)
+	}
+
+	ss.apply(query)
+
+	return query
+}
+
+func (ss *StatusSearch) Q() applier {
+	return func(query *orm.Query) (*orm.Query, error) {
+		if ss == nil {
+			return query, nil
+		}
+		return ss.Apply(query), nil
+	}
+}
+
+type StatusRepo struct {
+	statuses Repo[Status, *StatusSearch]
+}
+
+// NewStatusRepo returns new repository
+func NewStatusRepo(db orm.DB) StatusRepo {
+	return StatusRepo{
+		statuses: NewRepo[Status, *StatusSearch](db, RepoOpts{
+			Sort: []SortField{{Column: Statuses.Columns.ID, Direction: SortAsc}},
+		}),
+	}
+}
+
+// WithTransaction is a function that wraps StatusRepo with pg.Tx transaction.
+func (sr StatusRepo) WithTransaction(tx *pg.Tx) StatusRepo {
+	sr.statuses = sr.statuses.WithTransaction(tx)
+	return sr
+}
+
+// Statuses returns all statuses ordered by id.
+func (sr StatusRepo) Statuses(ctx context.Context) ([]Status, error) {
+	return sr.statuses.ByFilters(ctx, nil, PagerNoLimit, sr.statuses.DefaultSort())
+}
@@ -0,0 +1,225 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Factories create entities for tests. If entity in has ID, it is fetched from database and returned as is.
+// Otherwise ops are applied to in, entity is added and Cleaner deletes it with all related entities created by ops.
+// With SetupTx cleaners are not required, all changes are rolled back.
+
+type (
+	UserOpFunc      func(t *testing.T, dbo orm.DB, in *db.User) Cleaner
+	VfsFolderOpFunc func(t *testing.T, dbo orm.DB, in *db.VfsFolder) Cleaner
+	VfsFileOpFunc   func(t *testing.T, dbo orm.DB, in *db.VfsFile) Cleaner
+	JobOpFunc       func(t *testing.T, dbo orm.DB, in *db.Job) Cleaner
+)
+
+// fakeString returns unique string with prefix.
+func fakeString(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), rand.IntN(1e6))
+}
+
+// deleteClean returns Cleaner that deletes entity by primary key and then runs cleaners in reverse order.
+func deleteClean(t *testing.T, dbo orm.DB, model any, cleaners []Cleaner) Cleaner {
+	return func() {
+		if _, err := dbo.ModelContext(context.Background(), model).WherePK().Delete(); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range slices.Backward(cleaners) {
+			c()
+		}
+	}
+}
+
+func User(t *testing.T, dbo orm.DB, in *db.User, ops ...UserOpFunc) (*db.User, Cleaner) {
+	repo := db.NewCommonRepo(dbo)
+	if in == nil {
+		in = &db.User{}
+	}
+
+	if in.ID != 0 {
+		user, err := repo.UserByID(t.Context(), in.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if user == nil {
+			t.Fatalf("user not found, id=%d", in.ID)
+		}
+		return user, emptyClean
+	}
+
+	var cleaners []Cleaner
+	for _, op := range ops {
+		cleaners = append(cleaners, op(t, dbo, in))
+	}
+
+	user, err := repo.AddUser(t.Context(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user, deleteClean(t, dbo, &db.User{ID: user.ID}, cleaners)
+}
+
+// WithFakeUser fills required fields of user with unique login. Password is not hashed.
+func WithFakeUser(_ *testing.T, _ orm.DB, in *db.User) Cleaner {
+	if in.Login == "" {
+		in.Login = fakeString("user")
+	}
+	if in.Password == "" {
+		in.Password = fakeString("password")
+	}
+	if in.StatusID == 0 {
+		in.StatusID = db.StatusEnabled
+	}
+	return emptyClean
+}
+
+func VfsFolder(t *testing.T, dbo orm.DB, in *db.VfsFolder, ops ...VfsFolderOpFunc) (*db.VfsFolder, Cleaner) {
+	repo := db.NewVfsRepo(dbo)
+	if in == nil {
+		in = &db.VfsFolder{}
+	}
+
+	if in.ID != 0 {
+		folder, err := repo.VfsFolderByID(t.Context(), in.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if folder == nil {
+			t.Fatalf("vfs folder not found, id=%d", in.ID)
+		}
+		return folder, emptyClean
+	}
+
+	var cleaners []Cleaner
+	for _, op := range ops {
+		cleaners = append(cleaners, op(t, dbo, in))
+	}
+
+	folder, err := repo.AddVfsFolder(t.Context(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return folder, deleteClean(t, dbo, &db.VfsFolder{ID: folder.ID}, cleaners)
+}
+
+func WithFakeVfsFolder(_ *testing.T, _ orm.DB, in *db.VfsFolder) Cleaner {
+	if in.Title == "" {
+		in.Title = fakeString("folder")
+	}
+	if in.StatusID == 0 {
+		in.StatusID = db.StatusEnabled
+	}
+	return emptyClean
+}
+
+func VfsFile(t *testing.T, dbo orm.DB, in *db.VfsFile, ops ...VfsFileOpFunc) (*db.VfsFile, Cleaner) {
+	repo := db.NewVfsRepo(dbo)
+	if in == nil {
+		in = &db.VfsFile{}
+	}
+
+	if in.ID != 0 {
+		file, err := repo.VfsFileByID(t.Context(), in.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if file == nil {
+			t.Fatalf("vfs file not found, id=%d", in.ID)
+		}
+		return file, emptyClean
+	}
+
+	var cleaners []Cleaner
+	for _, op := range ops {
+		cleaners = append(cleaners, op(t, dbo, in))
+	}
+
+	file, err := repo.AddVfsFile(t.Context(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file, deleteClean(t, dbo, &db.VfsFile{ID: file.ID}, cleaners)
+}
+
+// WithVfsFileRelations creates folder for file if FolderID is not set.
+func WithVfsFileRelations(t *testing.T, dbo orm.DB, in *db.VfsFile) Cleaner {
+	if in.FolderID != 0 {
+		return emptyClean
+	}
+
+	folder, cleaner := VfsFolder(t, dbo, in.Folder, WithFakeVfsFolder)
+	in.FolderID, in.Folder = folder.ID, folder
+
+	return cleaner
+}
+
+func WithFakeVfsFile(_ *testing.T, _ orm.DB, in *db.VfsFile) Cleaner {
+	if in.Title == "" {
+		in.Title = fakeString("file") + ".txt"
+	}
+	if in.Path == "" {
+		in.Path = fakeString("path") + ".txt"
+	}
+	if in.MimeType == "" {
+		in.MimeType = "text/plain"
+	}
+	if in.StatusID == 0 {
+		in.StatusID = db.StatusEnabled
+	}
+	return emptyClean
+}
+
+func Job(t *testing.T, dbo orm.DB, in *db.Job, ops ...JobOpFunc) (*db.Job, Cleaner) {
+	repo := db.NewJobRepo(dbo)
+	if in == nil {
+		in = &db.Job{}
+	}
+
+	if in.ID != 0 {
+		job, err := repo.JobByID(t.Context(), in.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if job == nil {
+			t.Fatalf("job not found, id=%d", in.ID)
+		}
+		return job, emptyClean
+	}
+
+	var cleaners []Cleaner
+	for _, op := range ops {
+		cleaners = append(cleaners, op(t, dbo, in))
+	}
+
+	if ok, err := repo.EnqueueJob(t.Context(), in); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf("job is not enqueued, uniqueKey=%v", in.UniqueKey)
+	}
+
+	return in, deleteClean(t, dbo, &db.Job{ID: in.ID}, cleaners)
+}
+
+// WithFakeJob fills job kind and args. Job is pending and could be run immediately.
+func WithFakeJob(_ *testing.T, _ orm.DB, in *db.Job) Cleaner {
+	if in.Kind == "" {
+		in.Kind = fakeString("job")
+	}
+	if in.Args == nil {
+		in.Args = []byte("{}")
+	}
+	if in.MaxAttempts == 0 {
+		in.MaxAttempts = 1
+	}
+	return emptyClean
+}
@@ -0,0 +1,432 @@
+package test
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+const (
+	columnCreatedAt = "createdAt"
+	columnStatusID  = "statusId"
+)
+
+var (
+	ErrUnsupportedSearch = errors.New("unsupported search field")
+	ErrUnsupportedFilter = errors.New("unsupported filter")
+)
+
+// searchSuffixes maps suffixes of search fields to search types, e.g. LoginILike or CreatedAtFrom.
+var searchSuffixes = []struct {
+	suffix     string
+	searchType int
+}{
+	{"ILike", db.SearchTypeILike},
+	{"Like", db.SearchTypeLike},
+	{"From", db.SearchTypeGE},
+	{"To", db.SearchTypeLE},
+}
+
+// MemRepo is an in-memory repository for unit tests, it behaves like db.Repo for T with search S:
+//   - search fields are matched with T fields by name: X is equal, NotX is not equal, Xs is in list,
+//     XILike and XLike are substrings, XFrom and XTo are ranges. Conditions added by With are ignored;
+//   - base filters are applied, e.g. db.StatusFilter hides deleted rows;
+//   - Delete sets statusId to deleted if T has it, otherwise row is removed;
+//   - ops are ignored, rows are sorted by primary key and returned without relations.
+//
+// Copies of MemRepo share rows.
+type MemRepo[T any, S db.Searcher] struct {
+	store   *memStore[T]
+	table   *orm.Table
+	filters []db.Filter
+}
+
+type memStore[T any] struct {
+	mu   sync.RWMutex
+	rows map[int]T
+	seq  int
+}
+
+// NewMemRepo returns empty in-memory repository with base filters.
+func NewMemRepo[T any, S db.Searcher](filters ...db.Filter) MemRepo[T, S] {
+	return MemRepo[T, S]{
+		store:   &memStore[T]{rows: make(map[int]T)},
+		table:   orm.GetTable(reflect.TypeFor[T]()),
+		filters: filters,
+	}
+}
+
+// WithEnabledOnly is a function that adds "statusId"=1 as base filter.
+func (r MemRepo[T, S]) WithEnabledOnly() MemRepo[T, S] {
+	r.filters = append(slices.Clone(r.filters), db.StatusEnabledFilter)
+	return r
+}
+
+// ByID is a function that returns T by ID or nil.
+func (r MemRepo[T, S]) ByID(_ context.Context, id int, _ ...db.OpFunc) (*T, error) {
+	r.store.mu.RLock()
+	row, ok := r.store.rows[id]
+	r.store.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	if matched, err := r.match(&row, r.filters); err != nil || !matched {
+		return nil, err
+	}
+
+	return &row, nil
+}
+
+// One is a function that returns one T by filters. It could return pg.ErrMultiRows.
+func (r MemRepo[T, S]) One(_ context.Context, search S, _ ...db.OpFunc) (*T, error) {
+	list, err := r.find(search, &db.PagerTwo)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(list) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &list[0], nil
+	}
+
+	return nil, pg.ErrMultiRows
+}
+
+// ByFilters returns T list.
+func (r MemRepo[T, S]) ByFilters(_ context.Context, search S, pager db.Pager, _ ...db.OpFunc) ([]T, error) {
+	return r.find(search, &pager)
+}
+
+// Count returns count of T.
+func (r MemRepo[T, S]) Count(_ context.Context, search S, _ ...db.OpFunc) (int, error) {
+	list, err := r.find(search, nil)
+	return len(list), err
+}
+
+// ForEach calls fn for every T found by filters.
+func (r MemRepo[T, S]) ForEach(_ context.Context, search S, fn func(*T) error, _ ...db.OpFunc) error {
+	list, err := r.find(search, nil)
+	if err != nil {
+		return err
+	}
+
+	for i := range list {
+		if err = fn(&list[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Exists checks that there is a row with column value except row with notID primary key, like db.RowExists.
+func (r MemRepo[T, S]) Exists(_ context.Context, column string, value any, notID int) (bool, error) {
+	filters := append(slices.Clone(r.filters), db.Filter{Field: column, Value: value})
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for id, row := range r.store.rows {
+		if id == notID {
+			continue
+		}
+		if ok, err := r.match(&row, filters); err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+// Add adds T. Primary key is generated if it is not set, "createdAt" is set to now if it is zero.
+func (r MemRepo[T, S]) Add(_ context.Context, obj *T, _ ...db.OpFunc) (*T, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	v := reflect.ValueOf(obj).Elem()
+	pk := r.table.PKs[0].Value(v)
+	if id := int(pk.Int()); id == 0 {
+		r.store.seq++
+		pk.SetInt(int64(r.store.seq))
+	} else if _, ok := r.store.rows[id]; ok {
+		return nil, fmt.Errorf("duplicate key %s=%d", r.table.PKs[0].SQLName, id)
+	} else {
+		r.store.seq = max(r.store.seq, id)
+	}
+
+	if f, ok := r.table.FieldsMap[columnCreatedAt]; ok && f.HasZeroValue(v) {
+		f.Value(v).Set(reflect.ValueOf(time.Now()))
+	}
+
+	r.store.rows[r.id(obj)] = *obj
+	return obj, nil
+}
+
+// Update updates T by primary key. Column "createdAt" is not changed.
+func (r MemRepo[T, S]) Update(_ context.Context, obj *T, _ ...db.OpFunc) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	cur, ok := r.store.rows[r.id(obj)]
+	if !ok {
+		return false, nil
+	}
+
+	row := *obj
+	if f, ok := r.table.FieldsMap[columnCreatedAt]; ok {
+		f.Value(reflect.ValueOf(&row).Elem()).Set(f.Value(reflect.ValueOf(&cur).Elem()))
+	}
+
+	r.store.rows[r.id(obj)] = row
+	return true, nil
+}
+
+// UpdateFunc changes T by primary key with fn. Row is saved only if fn returns true.
+func (r MemRepo[T, S]) UpdateFunc(_ context.Context, id int, fn func(obj *T) bool) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	row, ok := r.store.rows[id]
+	if !ok || !fn(&row) {
+		return false, nil
+	}
+
+	r.store.rows[id] = row
+	return true, nil
+}
+
+// Delete sets statusId to deleted. Rows without statusId column are removed.
+func (r MemRepo[T, S]) Delete(ctx context.Context, id int) (bool, error) {
+	f, soft := r.table.FieldsMap[columnStatusID]
+	if soft {
+		return r.UpdateFunc(ctx, id, func(obj *T) bool {
+			f.Value(reflect.ValueOf(obj).Elem()).SetInt(db.StatusDeleted)
+			return true
+		})
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	_, ok := r.store.rows[id]
+	delete(r.store.rows, id)
+	return ok, nil
+}
+
+// find returns copies of rows matched by base filters and search, sorted by primary key.
+func (r MemRepo[T, S]) find(search S, pager *db.Pager) ([]T, error) {
+	filters, err := searchFilters(r.table, search)
+	if err != nil {
+		return nil, err
+	}
+	filters = append(slices.Clone(r.filters), filters...)
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var list []T
+	for _, id := range slices.Sorted(maps.Keys(r.store.rows)) {
+		row := r.store.rows[id]
+		if ok, er := r.match(&row, filters); er != nil {
+			return nil, er
+		} else if ok {
+			list = append(list, row)
+		}
+	}
+
+	if pager != nil {
+		p := pager.Pager()
+		offset, limit := min(p.GetOffset(), len(list)), p.GetLimit()
+		list = list[offset:]
+		if limit > 0 && limit < len(list) {
+			list = list[:limit]
+		}
+	}
+
+	return list, nil
+}
+
+// match checks that obj is matched by all filters.
+func (r MemRepo[T, S]) match(obj *T, filters []db.Filter) (bool, error) {
+	v := reflect.ValueOf(obj).Elem()
+	for _, f := range filters {
+		field, ok := r.table.FieldsMap[strings.TrimPrefix(f.Field, db.TablePrefix+".")]
+		if !ok {
+			return false, fmt.Errorf("%w: %s", ErrUnsupportedFilter, f.Field)
+		}
+
+		if matched, err := matchFilter(field.Value(v), f); err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (r MemRepo[T, S]) id(obj *T) int {
+	return int(r.table.PKs[0].Value(reflect.ValueOf(obj).Elem()).Int())
+}
+
+// searchFilters converts search fields to filters by naming convention of generated searches.
+func searchFilters(table *orm.Table, search any) ([]db.Filter, error) {
+	v := reflect.ValueOf(search)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	columns := make(map[string]string, len(table.Fields))
+	for _, f := range table.Fields {
+		columns[f.GoName] = f.SQLName
+	}
+
+	var filters []db.Filter
+	for i := range v.NumField() {
+		sf, fv := v.Type().Field(i), v.Field(i)
+		if !sf.IsExported() || sf.Anonymous || fv.IsZero() || (fv.Kind() == reflect.Slice && fv.Len() == 0) {
+			continue
+		}
+
+		f, ok := searchFilter(columns, sf.Name, fv.Kind() == reflect.Slice)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedSearch, sf.Name)
+		}
+		f.Value = reflect.Indirect(fv).Interface()
+		filters = append(filters, f)
+	}
+
+	return filters, nil
+}
+
+// searchFilter returns filter without value for search field name.
+func searchFilter(columns map[string]string, name string, isSlice bool) (db.Filter, bool) {
+	if isSlice {
+		col, ok := columns[strings.TrimSuffix(name, "s")]
+		return db.Filter{Field: col, SearchType: db.SearchTypeArray}, ok
+	}
+
+	if col, ok := columns[name]; ok {
+		return db.Filter{Field: col}, true
+	}
+
+	if col, ok := columns[strings.TrimPrefix(name, "Not")]; ok && strings.HasPrefix(name, "Not") {
+		return db.Filter{Field: col, Exclude: true}, true
+	}
+
+	for _, s := range searchSuffixes {
+		if col, ok := columns[strings.TrimSuffix(name, s.suffix)]; ok && strings.HasSuffix(name, s.suffix) {
+			return db.Filter{Field: col, SearchType: s.searchType}, true
+		}
+	}
+
+	return db.Filter{}, false
+}
+
+// matchFilter checks field value v with filter f. Like SQL, NULL values are matched only by SearchTypeNull.
+func matchFilter(v reflect.Value, f db.Filter) (bool, error) {
+	isNull := v.Kind() == reflect.Pointer && v.IsNil()
+	if f.SearchType == db.SearchTypeNull {
+		return isNull != f.Exclude, nil
+	} else if isNull {
+		return false, nil
+	}
+	v = reflect.Indirect(v)
+
+	var (
+		ok  bool
+		c   int
+		err error
+	)
+	switch f.SearchType {
+	case db.SearchTypeEquals:
+		c, err = compare(v, f.Value)
+		ok = c == 0
+	case db.SearchTypeGE, db.SearchTypeLE, db.SearchTypeGreater, db.SearchTypeLess:
+		c, err = compare(v, f.Value)
+		ok = map[int]bool{db.SearchTypeGE: c >= 0, db.SearchTypeLE: c <= 0, db.SearchTypeGreater: c > 0, db.SearchTypeLess: c < 0}[f.SearchType]
+	case db.SearchTypeLike, db.SearchTypeILike:
+		ok, err = like(v, f.Value, f.SearchType == db.SearchTypeILike)
+	case db.SearchTypeArray:
+		values := reflect.ValueOf(f.Value)
+		for i := 0; i < values.Len() && !ok && err == nil; i++ {
+			c, err = compare(v, values.Index(i).Interface())
+			ok = c == 0
+		}
+	default:
+		return false, fmt.Errorf("%w: search type %d", ErrUnsupportedFilter, f.SearchType)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return ok != f.Exclude, nil
+}
+
+// compare compares v with value of the same kind.
+func compare(v reflect.Value, value any) (int, error) {
+	val := reflect.Indirect(reflect.ValueOf(value))
+	switch {
+	case v.Type() == reflect.TypeFor[time.Time]() && val.Type() == v.Type():
+		return v.Interface().(time.Time).Compare(val.Interface().(time.Time)), nil
+	case v.CanInt() && val.CanInt():
+		return cmp.Compare(v.Int(), val.Int()), nil
+	case v.CanUint() && val.CanUint():
+		return cmp.Compare(v.Uint(), val.Uint()), nil
+	case v.CanFloat() && val.CanFloat():
+		return cmp.Compare(v.Float(), val.Float()), nil
+	case v.Kind() == reflect.String && val.Kind() == reflect.String:
+		return strings.Compare(v.String(), val.String()), nil
+	case v.Kind() == reflect.Bool && val.Kind() == reflect.Bool:
+		if v.Bool() == val.Bool() {
+			return 0, nil
+		}
+		return 1, nil
+	}
+
+	return 0, fmt.Errorf("%w: compare %s with %T", ErrUnsupportedFilter, v.Type(), value)
+}
+
+// like checks that string v contains pattern with SQL wildcards like db.Filter does.
+func like(v reflect.Value, value any, ignoreCase bool) (bool, error) {
+	pattern, ok := value.(string)
+	if v.Kind() != reflect.String || !ok {
+		return false, fmt.Errorf("%w: like %s with %T", ErrUnsupportedFilter, v.Type(), value)
+	}
+
+	var b strings.Builder
+	if ignoreCase {
+		b.WriteString("(?is)")
+	} else {
+		b.WriteString("(?s)")
+	}
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	return regexp.MatchString(b.String(), v.String())
+}
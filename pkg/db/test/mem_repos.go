@@ -0,0 +1,570 @@
+package test
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"apisrv/pkg/db"
+)
+
+// UserRepo is an in-memory db.UserRepository. Like db.CommonRepo it hides deleted users.
+type UserRepo struct {
+	users MemRepo[db.User, *db.UserSearch]
+}
+
+// NewUserRepo returns UserRepo with given users.
+func NewUserRepo(users ...db.User) UserRepo {
+	ur := UserRepo{users: NewMemRepo[db.User, *db.UserSearch](db.StatusFilter)}
+	for i := range users {
+		_, _ = ur.users.Add(context.Background(), &users[i])
+	}
+	return ur
+}
+
+// Users returns in-memory User repository.
+func (ur UserRepo) Users() MemRepo[db.User, *db.UserSearch] {
+	return ur.users
+}
+
+func (ur UserRepo) DefaultUserSort() db.OpFunc {
+	return db.WithSort(db.NewSortField(db.Columns.User.CreatedAt, true))
+}
+
+func (ur UserRepo) FullUser() db.OpFunc {
+	return db.WithColumns(db.TableColumns)
+}
+
+func (ur UserRepo) UserByID(ctx context.Context, id int, ops ...db.OpFunc) (*db.User, error) {
+	return ur.users.ByID(ctx, id, ops...)
+}
+
+func (ur UserRepo) OneUser(ctx context.Context, search *db.UserSearch, ops ...db.OpFunc) (*db.User, error) {
+	return ur.users.One(ctx, search, ops...)
+}
+
+func (ur UserRepo) UsersByFilters(ctx context.Context, search *db.UserSearch, pager db.Pager, ops ...db.OpFunc) ([]db.User, error) {
+	return ur.users.ByFilters(ctx, search, pager, ops...)
+}
+
+func (ur UserRepo) CountUsers(ctx context.Context, search *db.UserSearch, ops ...db.OpFunc) (int, error) {
+	return ur.users.Count(ctx, search, ops...)
+}
+
+func (ur UserRepo) ForEachUser(ctx context.Context, search *db.UserSearch, fn func(*db.User) error, ops ...db.OpFunc) error {
+	return ur.users.ForEach(ctx, search, fn, ops...)
+}
+
+func (ur UserRepo) AddUser(ctx context.Context, user *db.User, ops ...db.OpFunc) (*db.User, error) {
+	return ur.users.Add(ctx, user, ops...)
+}
+
+func (ur UserRepo) UpdateUser(ctx context.Context, user *db.User, ops ...db.OpFunc) (bool, error) {
+	return ur.users.Update(ctx, user, ops...)
+}
+
+func (ur UserRepo) DeleteUser(ctx context.Context, id int) (bool, error) {
+	return ur.users.Delete(ctx, id)
+}
+
+func (ur UserRepo) AuthenticateUser(ctx context.Context, dbu *db.User, authKey string) (bool, error) {
+	dbu.AuthKey = authKey
+	now := time.Now()
+	dbu.LastActivityAt = &now
+	return ur.UpdateUser(ctx, dbu)
+}
+
+func (ur UserRepo) UpdateUserActivity(ctx context.Context, dbu *db.User) (bool, error) {
+	now := time.Now()
+	dbu.LastActivityAt = &now
+	return ur.UpdateUser(ctx, dbu)
+}
+
+func (ur UserRepo) UpdateUserPassword(ctx context.Context, dbu *db.User) (bool, error) {
+	return ur.UpdateUser(ctx, dbu)
+}
+
+func (ur UserRepo) EnabledUserByAuthKey(ctx context.Context, authKey string) (*db.User, error) {
+	return ur.OneUser(ctx, &db.UserSearch{AuthKey: &authKey, StatusID: Ptr(db.StatusEnabled)})
+}
+
+func (ur UserRepo) EnabledUserByLogin(ctx context.Context, login string) (*db.User, error) {
+	return ur.OneUser(ctx, &db.UserSearch{Login: &login, StatusID: Ptr(db.StatusEnabled)})
+}
+
+// JobRepo is an in-memory db.JobRepository.
+type JobRepo struct {
+	jobs MemRepo[db.Job, *db.JobSearch]
+}
+
+// NewJobRepo returns JobRepo with given jobs. Jobs without state are pending.
+func NewJobRepo(jobs ...db.Job) JobRepo {
+	jr := JobRepo{jobs: NewMemRepo[db.Job, *db.JobSearch]()}
+	for i := range jobs {
+		if jobs[i].State == "" {
+			jobs[i].State = db.JobStatePending
+		}
+		_, _ = jr.jobs.Add(context.Background(), &jobs[i])
+	}
+	return jr
+}
+
+// Jobs returns in-memory Job repository.
+func (jr JobRepo) Jobs() MemRepo[db.Job, *db.JobSearch] {
+	return jr.jobs
+}
+
+func (jr JobRepo) DefaultJobSort() db.OpFunc {
+	return db.WithSort(db.NewSortField(db.Jobs.Columns.ID, true))
+}
+
+func (jr JobRepo) JobByID(ctx context.Context, id int, ops ...db.OpFunc) (*db.Job, error) {
+	return jr.jobs.ByID(ctx, id, ops...)
+}
+
+func (jr JobRepo) JobsByFilters(ctx context.Context, search *db.JobSearch, pager db.Pager, ops ...db.OpFunc) ([]db.Job, error) {
+	return jr.jobs.ByFilters(ctx, search, pager, ops...)
+}
+
+func (jr JobRepo) CountJobs(ctx context.Context, search *db.JobSearch, ops ...db.OpFunc) (int, error) {
+	return jr.jobs.Count(ctx, search, ops...)
+}
+
+func (jr JobRepo) RetryJob(ctx context.Context, id int) (bool, error) {
+	return jr.jobs.UpdateFunc(ctx, id, func(job *db.Job) bool {
+		if job.State != db.JobStateFailed && job.State != db.JobStateCanceled {
+			return false
+		}
+		job.State, job.RunAt, job.Attempts = db.JobStatePending, time.Now(), 0
+		job.LockedAt, job.FinishedAt, job.Error = nil, nil, nil
+		return true
+	})
+}
+
+func (jr JobRepo) CancelJob(ctx context.Context, id int) (bool, error) {
+	return jr.jobs.UpdateFunc(ctx, id, func(job *db.Job) bool {
+		if job.State != db.JobStatePending && job.State != db.JobStateRunning {
+			return false
+		}
+		job.State, job.LockedAt, job.FinishedAt = db.JobStateCanceled, nil, Ptr(time.Now())
+		return true
+	})
+}
+
+// RevisionRepo is an in-memory db.RevisionRepository.
+type RevisionRepo struct {
+	revisions MemRepo[db.Revision, *db.RevisionSearch]
+}
+
+// NewRevisionRepo returns RevisionRepo with given revisions.
+func NewRevisionRepo(revisions ...db.Revision) RevisionRepo {
+	rr := RevisionRepo{revisions: NewMemRepo[db.Revision, *db.RevisionSearch]()}
+	for i := range revisions {
+		_, _ = rr.revisions.Add(context.Background(), &revisions[i])
+	}
+	return rr
+}
+
+// Revisions returns in-memory Revision repository.
+func (rr RevisionRepo) Revisions() MemRepo[db.Revision, *db.RevisionSearch] {
+	return rr.revisions
+}
+
+// RevisionsByEntity returns revisions of entity, the latest first.
+func (rr RevisionRepo) RevisionsByEntity(ctx context.Context, entity string, entityID int, pager db.Pager) ([]db.Revision, error) {
+	list, err := rr.revisions.ByFilters(ctx, &db.RevisionSearch{Entity: &entity, EntityID: &entityID}, db.PagerNoLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(list)
+	p := pager.Pager()
+	list = list[min(p.GetOffset(), len(list)):]
+	return list[:min(p.GetLimit(), len(list))], nil
+}
+
+func (rr RevisionRepo) RevisionByNumber(ctx context.Context, entity string, entityID, revision int) (*db.Revision, error) {
+	return rr.revisions.One(ctx, &db.RevisionSearch{Entity: &entity, EntityID: &entityID, Revision: &revision})
+}
+
+// StatusRepo is an in-memory db.StatusRepository.
+type StatusRepo struct {
+	statuses MemRepo[db.Status, *db.StatusSearch]
+}
+
+// NewStatusRepo returns StatusRepo with given statuses.
+func NewStatusRepo(statuses ...db.Status) StatusRepo {
+	sr := StatusRepo{statuses: NewMemRepo[db.Status, *db.StatusSearch]()}
+	for i := range statuses {
+		_, _ = sr.statuses.Add(context.Background(), &statuses[i])
+	}
+	return sr
+}
+
+// Repo returns in-memory Status repository.
+func (sr StatusRepo) Repo() MemRepo[db.Status, *db.StatusSearch] {
+	return sr.statuses
+}
+
+func (sr StatusRepo) Statuses(ctx context.Context) ([]db.Status, error) {
+	return sr.statuses.ByFilters(ctx, nil, db.PagerNoLimit)
+}
+
+// ExistsRepo is an in-memory db.ExistsRepository, rows are checked in repositories of tables.
+type ExistsRepo struct {
+	tables map[string]func(ctx context.Context, column string, value any, notID int) (bool, error)
+}
+
+// NewExistsRepo returns ExistsRepo for users and statuses.
+func NewExistsRepo(ur UserRepo, sr StatusRepo) ExistsRepo {
+	return ExistsRepo{tables: map[string]func(ctx context.Context, column string, value any, notID int) (bool, error){
+		db.Tables.User.Name: ur.users.Exists,
+		"statuses":          sr.statuses.Exists,
+	}}
+}
+
+func (er ExistsRepo) RowExists(ctx context.Context, table, column string, value any, notID int) (bool, error) {
+	exists, ok := er.tables[table]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", db.ErrUnknownTable, table)
+	}
+	return exists(ctx, column, value, notID)
+}
+
+// VfsFolderRepo is an in-memory db.VfsFolderRepository.
+type VfsFolderRepo struct {
+	folders MemRepo[db.VfsFolder, *db.VfsFolderSearch]
+}
+
+// NewVfsFolderRepo returns VfsFolderRepo with given folders.
+func NewVfsFolderRepo(folders ...db.VfsFolder) VfsFolderRepo {
+	vr := VfsFolderRepo{folders: NewMemRepo[db.VfsFolder, *db.VfsFolderSearch](db.StatusFilter)}
+	for i := range folders {
+		_, _ = vr.folders.Add(context.Background(), &folders[i])
+	}
+	return vr
+}
+
+// Folders returns in-memory VfsFolder repository.
+func (vr VfsFolderRepo) Folders() MemRepo[db.VfsFolder, *db.VfsFolderSearch] {
+	return vr.folders
+}
+
+func (vr VfsFolderRepo) VfsFolderByID(ctx context.Context, id int, ops ...db.OpFunc) (*db.VfsFolder, error) {
+	return vr.folders.ByID(ctx, id, ops...)
+}
+
+func (vr VfsFolderRepo) VfsFolderBranch(ctx context.Context, folderID int) ([]db.VfsFolder, error) {
+	var list []db.VfsFolder
+	for id := &folderID; id != nil; {
+		f, err := vr.folders.ByID(ctx, *id)
+		if err != nil || f == nil {
+			return list, err
+		}
+		list = append([]db.VfsFolder{*f}, list...)
+		id = f.ParentFolderID
+	}
+	return list, nil
+}
+
+func (vr VfsFolderRepo) AddVfsFolder(ctx context.Context, vfsFolder *db.VfsFolder, ops ...db.OpFunc) (*db.VfsFolder, error) {
+	return vr.folders.Add(ctx, vfsFolder, ops...)
+}
+
+func (vr VfsFolderRepo) UpdateVfsFolder(ctx context.Context, vfsFolder *db.VfsFolder, ops ...db.OpFunc) (bool, error) {
+	return vr.folders.Update(ctx, vfsFolder, ops...)
+}
+
+func (vr VfsFolderRepo) DeleteVfsFolder(ctx context.Context, id int) (bool, error) {
+	return vr.folders.Delete(ctx, id)
+}
+
+// VfsAccessRepo is an in-memory db.VfsAccessRepository.
+type VfsAccessRepo struct {
+	folders MemRepo[db.VfsFolder, *db.VfsFolderSearch]
+	files   MemRepo[db.VfsFile, *db.VfsFileSearch]
+}
+
+// NewVfsAccessRepo returns VfsAccessRepo with given folders and files.
+func NewVfsAccessRepo(folders []db.VfsFolder, files ...db.VfsFile) VfsAccessRepo {
+	vr := VfsAccessRepo{
+		folders: NewMemRepo[db.VfsFolder, *db.VfsFolderSearch](db.StatusFilter),
+		files:   NewMemRepo[db.VfsFile, *db.VfsFileSearch](db.StatusFilter),
+	}
+	for i := range folders {
+		_, _ = vr.folders.Add(context.Background(), &folders[i])
+	}
+	for i := range files {
+		_, _ = vr.files.Add(context.Background(), &files[i])
+	}
+	return vr
+}
+
+func (vr VfsAccessRepo) IsPrivateFolder(ctx context.Context, folderID int) (bool, error) {
+	for id := &folderID; id != nil; {
+		f, err := vr.folders.ByID(ctx, *id)
+		if err != nil || f == nil {
+			return false, err
+		} else if f.IsPrivate {
+			return true, nil
+		}
+		id = f.ParentFolderID
+	}
+	return false, nil
+}
+
+func (vr VfsAccessRepo) IsPrivateFile(ctx context.Context, path string) (bool, error) {
+	files, err := vr.files.ByFilters(ctx, &db.VfsFileSearch{Path: &path}, db.PagerNoLimit)
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range files {
+		if private, err := vr.IsPrivateFolder(ctx, f.FolderID); err != nil || private {
+			return private, err
+		}
+	}
+	return false, nil
+}
+
+func (vr VfsAccessRepo) SetVfsFolderPrivate(ctx context.Context, folderID int, private bool) (bool, error) {
+	return vr.folders.UpdateFunc(ctx, folderID, func(f *db.VfsFolder) bool {
+		f.IsPrivate = private
+		return true
+	})
+}
+
+// VfsQuotaRepo is an in-memory db.VfsQuotaRepository. Namespace usage is set by test.
+type VfsQuotaRepo struct {
+	folders MemRepo[db.VfsFolder, *db.VfsFolderSearch]
+	files   MemRepo[db.VfsFile, *db.VfsFileSearch]
+
+	Namespaces []db.VfsUsage
+}
+
+// NewVfsQuotaRepo returns VfsQuotaRepo with given folders and files.
+func NewVfsQuotaRepo(folders []db.VfsFolder, files ...db.VfsFile) *VfsQuotaRepo {
+	vr := &VfsQuotaRepo{
+		folders: NewMemRepo[db.VfsFolder, *db.VfsFolderSearch](db.StatusFilter),
+		files:   NewMemRepo[db.VfsFile, *db.VfsFileSearch](db.StatusFilter),
+	}
+	for i := range folders {
+		_, _ = vr.folders.Add(context.Background(), &folders[i])
+	}
+	for i := range files {
+		_, _ = vr.files.Add(context.Background(), &files[i])
+	}
+	return vr
+}
+
+func (vr *VfsQuotaRepo) VfsFolderUsage(ctx context.Context, folderID int) (db.VfsUsage, error) {
+	var u db.VfsUsage
+	folders, err := vr.folders.ByFilters(ctx, nil, db.PagerNoLimit)
+	if err != nil {
+		return u, err
+	}
+
+	tree := map[int]bool{folderID: true}
+	for changed := true; changed; {
+		changed = false
+		for _, f := range folders {
+			if f.ParentFolderID != nil && tree[*f.ParentFolderID] && !tree[f.ID] {
+				tree[f.ID], changed = true, true
+			}
+		}
+	}
+
+	err = vr.files.ForEach(ctx, nil, func(f *db.VfsFile) error {
+		if tree[f.FolderID] {
+			u.Files++
+			if f.FileSize != nil {
+				u.Size += int64(*f.FileSize)
+			}
+		}
+		return nil
+	})
+	return u, err
+}
+
+func (vr *VfsQuotaRepo) VfsFolderQuotas(ctx context.Context, folderID int) ([]db.VfsFolder, error) {
+	var list []db.VfsFolder
+	for id := &folderID; id != nil; {
+		f, err := vr.folders.ByID(ctx, *id)
+		if err != nil || f == nil {
+			return list, err
+		} else if f.Quota != nil {
+			list = append(list, *f)
+		}
+		id = f.ParentFolderID
+	}
+	return list, nil
+}
+
+func (vr *VfsQuotaRepo) SetVfsFolderQuota(ctx context.Context, folderID int, quota *int64) (bool, error) {
+	return vr.folders.UpdateFunc(ctx, folderID, func(f *db.VfsFolder) bool {
+		f.Quota = quota
+		return true
+	})
+}
+
+func (vr *VfsQuotaRepo) VfsNamespaceUsage(context.Context) ([]db.VfsUsage, error) {
+	return slices.Clone(vr.Namespaces), nil
+}
+
+// VfsScanRepo is an in-memory db.VfsScanRepository. Scan statuses of hashes are kept in HashStatuses by "namespace/hash".
+type VfsScanRepo struct {
+	files  MemRepo[db.VfsFile, *db.VfsFileSearch]
+	hashes []db.VfsHashScan
+
+	HashStatuses map[string]string
+}
+
+// NewVfsScanRepo returns VfsScanRepo with given hashes and files, hashes are pending.
+func NewVfsScanRepo(hashes []db.VfsHashScan, files ...db.VfsFile) *VfsScanRepo {
+	vr := &VfsScanRepo{
+		files:        NewMemRepo[db.VfsFile, *db.VfsFileSearch](db.StatusFilter),
+		hashes:       hashes,
+		HashStatuses: make(map[string]string),
+	}
+	for i := range files {
+		_, _ = vr.files.Add(context.Background(), &files[i])
+	}
+	for _, h := range hashes {
+		vr.HashStatuses[h.Namespace+"/"+h.Hash] = db.VfsScanPending
+	}
+	return vr
+}
+
+// Files returns in-memory VfsFile repository.
+func (vr *VfsScanRepo) Files() MemRepo[db.VfsFile, *db.VfsFileSearch] {
+	return vr.files
+}
+
+func (vr *VfsScanRepo) SetVfsFileScanStatus(ctx context.Context, fileID int, status string) (bool, error) {
+	return vr.files.UpdateFunc(ctx, fileID, func(f *db.VfsFile) bool {
+		f.ScanStatus = &status
+		if status == db.VfsScanInfected {
+			f.StatusID = db.StatusDisabled
+		}
+		return true
+	})
+}
+
+func (vr *VfsScanRepo) SetVfsHashScanStatus(_ context.Context, ns, hash, status string) (bool, error) {
+	if _, ok := vr.HashStatuses[ns+"/"+hash]; !ok {
+		return false, nil
+	}
+	vr.HashStatuses[ns+"/"+hash] = status
+	return true, nil
+}
+
+func (vr *VfsScanRepo) PendingVfsFiles(ctx context.Context, limit int) ([]db.VfsFile, error) {
+	var list []db.VfsFile
+	err := vr.files.ForEach(ctx, nil, func(f *db.VfsFile) error {
+		if f.ScanStatus != nil && *f.ScanStatus == db.VfsScanPending && f.StatusID != db.StatusDeleted && len(list) < limit {
+			list = append(list, *f)
+		}
+		return nil
+	})
+	return list, err
+}
+
+func (vr *VfsScanRepo) PendingVfsHashes(_ context.Context, limit int) ([]db.VfsHashScan, error) {
+	var list []db.VfsHashScan
+	for _, h := range vr.hashes {
+		if vr.HashStatuses[h.Namespace+"/"+h.Hash] == db.VfsScanPending && len(list) < limit {
+			list = append(list, h)
+		}
+	}
+	return list, nil
+}
+
+// VfsDedupRepo is an in-memory db.VfsDedupRepository.
+type VfsDedupRepo struct {
+	files MemRepo[db.VfsFile, *db.VfsFileSearch]
+}
+
+// NewVfsDedupRepo returns VfsDedupRepo with given files.
+func NewVfsDedupRepo(files ...db.VfsFile) VfsDedupRepo {
+	vr := VfsDedupRepo{files: NewMemRepo[db.VfsFile, *db.VfsFileSearch](db.StatusFilter)}
+	for i := range files {
+		_, _ = vr.files.Add(context.Background(), &files[i])
+	}
+	return vr
+}
+
+// Files returns in-memory VfsFile repository.
+func (vr VfsDedupRepo) Files() MemRepo[db.VfsFile, *db.VfsFileSearch] {
+	return vr.files
+}
+
+func (vr VfsDedupRepo) VfsFilesByFilters(ctx context.Context, search *db.VfsFileSearch, pager db.Pager, ops ...db.OpFunc) ([]db.VfsFile, error) {
+	return vr.files.ByFilters(ctx, search, pager, ops...)
+}
+
+func (vr VfsDedupRepo) CountVfsFiles(ctx context.Context, search *db.VfsFileSearch, ops ...db.OpFunc) (int, error) {
+	return vr.files.Count(ctx, search, ops...)
+}
+
+func (vr VfsDedupRepo) AddVfsFile(ctx context.Context, vfsFile *db.VfsFile, ops ...db.OpFunc) (*db.VfsFile, error) {
+	return vr.files.Add(ctx, vfsFile, ops...)
+}
+
+func (vr VfsDedupRepo) SetVfsFileHash(ctx context.Context, fileID int, hash string) (bool, error) {
+	return vr.files.UpdateFunc(ctx, fileID, func(f *db.VfsFile) bool {
+		f.Hash = &hash
+		return true
+	})
+}
+
+func (vr VfsDedupRepo) DeleteVfsFile(ctx context.Context, id int) (bool, error) {
+	return vr.files.Delete(ctx, id)
+}
+
+func (vr VfsDedupRepo) VfsDuplicates(ctx context.Context, limit int) ([]db.VfsDuplicate, error) {
+	files, err := vr.files.ByFilters(ctx, nil, db.PagerNoLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string]*db.VfsDuplicate)
+	for _, f := range files {
+		if f.Hash == nil {
+			continue
+		}
+		d, ok := byHash[*f.Hash]
+		if !ok {
+			d = &db.VfsDuplicate{Hash: *f.Hash}
+			byHash[*f.Hash] = d
+		}
+		d.Files++
+		if f.FileSize != nil {
+			d.Size = max(d.Size, int64(*f.FileSize))
+		}
+	}
+
+	var list []db.VfsDuplicate
+	for _, d := range byHash {
+		if d.Files > 1 {
+			list = append(list, *d)
+		}
+	}
+	slices.SortFunc(list, func(a, b db.VfsDuplicate) int {
+		return cmp.Or(cmp.Compare(int64(b.Files-1)*b.Size, int64(a.Files-1)*a.Size), cmp.Compare(a.Hash, b.Hash))
+	})
+
+	return list[:min(limit, len(list))], nil
+}
+
+var (
+	_ db.UserRepository      = UserRepo{}
+	_ db.JobRepository       = JobRepo{}
+	_ db.RevisionRepository  = RevisionRepo{}
+	_ db.StatusRepository    = StatusRepo{}
+	_ db.VfsAccessRepository = VfsAccessRepo{}
+	_ db.VfsQuotaRepository  = &VfsQuotaRepo{}
+	_ db.VfsScanRepository   = &VfsScanRepo{}
+	_ db.VfsDedupRepository  = VfsDedupRepo{}
+)
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"apisrv/pkg/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemRepo(t *testing.T) {
+	Convey("Test in-memory repository", t, func() {
+		ctx := t.Context()
+		now := time.Now()
+		repo := NewUserRepo(
+			db.User{Login: "admin", StatusID: db.StatusEnabled, LastActivityAt: &now},
+			db.User{Login: "Manager", StatusID: db.StatusDisabled},
+			db.User{Login: "deleted", StatusID: db.StatusDeleted},
+		).Users()
+
+		logins := func(search *db.UserSearch) []string {
+			list, err := repo.ByFilters(ctx, search, db.PagerNoLimit)
+			So(err, ShouldBeNil)
+
+			var r []string
+			for _, u := range list {
+				r = append(r, u.Login)
+			}
+			return r
+		}
+
+		Convey("Search fields are matched by name", func() {
+			So(logins(nil), ShouldResemble, []string{"admin", "Manager"})
+			So(logins(&db.UserSearch{LoginILike: Ptr("man")}), ShouldResemble, []string{"Manager"})
+			So(logins(&db.UserSearch{NotID: Ptr(1)}), ShouldResemble, []string{"Manager"})
+			So(logins(&db.UserSearch{IDs: []int{2, 3}}), ShouldResemble, []string{"Manager"})
+			So(logins(&db.UserSearch{LastActivityAtFrom: Ptr(now.Add(-time.Minute))}), ShouldResemble, []string{"admin"})
+			So(logins(&db.UserSearch{StatusID: Ptr(db.StatusDeleted)}), ShouldBeEmpty)
+		})
+
+		Convey("Exists skips deleted and current rows", func() {
+			exists := func(login string, notID int) bool {
+				ok, err := repo.Exists(ctx, "login", login, notID)
+				So(err, ShouldBeNil)
+				return ok
+			}
+			So(exists("admin", 0), ShouldBeTrue)
+			So(exists("admin", 1), ShouldBeFalse)
+			So(exists("deleted", 0), ShouldBeFalse)
+		})
+
+		Convey("Pager and count", func() {
+			list, err := repo.ByFilters(ctx, nil, db.Pager{Page: 2, PageSize: 1})
+			So(err, ShouldBeNil)
+			So(list, ShouldHaveLength, 1)
+			So(list[0].ID, ShouldEqual, 2)
+
+			count, err := repo.WithEnabledOnly().Count(ctx, nil)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("Delete is soft and update keeps createdAt", func() {
+			u, err := repo.ByID(ctx, 1)
+			So(err, ShouldBeNil)
+			createdAt := u.CreatedAt
+			So(createdAt.IsZero(), ShouldBeFalse)
+
+			u.CreatedAt = time.Time{}
+			ok, err := repo.Update(ctx, u)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			u, err = repo.ByID(ctx, 1)
+			So(err, ShouldBeNil)
+			So(u.CreatedAt, ShouldEqual, createdAt)
+
+			ok, err = repo.Delete(ctx, 1)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			u, err = repo.ByID(ctx, 1)
+			So(err, ShouldBeNil)
+			So(u, ShouldBeNil)
+		})
+
+		Convey("Unsupported search field", func() {
+			_, err := NewJobRepo().Jobs().Count(ctx, &db.JobSearch{States: []string{db.JobStatePending}})
+			So(err, ShouldBeNil)
+
+			_, err = searchFilters(repo.table, struct{ Unknown *int }{Unknown: Ptr(1)})
+			So(err, ShouldWrap, ErrUnsupportedSearch)
+		})
+	})
+}
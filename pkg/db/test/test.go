@@ -2,19 +2,41 @@ package test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 
+	"apisrv/docs"
 	"apisrv/pkg/db"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/vmkteam/embedlog"
 )
 
+// createLock is an advisory lock for database creation, test binaries of packages are run in parallel.
+const createLock = 7250617069737276
+
 var logger embedlog.Logger
 
+var (
+	// shared is a connection pool for SetupTx.
+	shared struct {
+		once sync.Once
+		conn *pg.DB
+		err  error
+	}
+
+	// created guards database creation in current process.
+	created struct {
+		once sync.Once
+		err  error
+	}
+)
+
 func getenv(key, fallback string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
@@ -24,7 +46,14 @@ func getenv(key, fallback string) string {
 
 type Cleaner func()
 
+// emptyClean is returned by factories for entities that were not created.
+func emptyClean() {}
+
+// Setup returns connection to test database. Database is created from embedded schema if it doesn't exist.
+// Data is not rolled back, use SetupTx for isolated tests.
 func Setup(t *testing.T) (db.DB, embedlog.Logger) {
+	skipShort(t)
+
 	// Create db connection
 	conn, err := setup()
 	if err != nil {
@@ -47,6 +76,40 @@ func Setup(t *testing.T) (db.DB, embedlog.Logger) {
 	return db.New(conn), logger
 }
 
+// SetupTx returns transaction in test database which is rolled back in t.Cleanup.
+// Connection pool is shared between tests, so SetupTx could be used in parallel tests.
+func SetupTx(t *testing.T) (*pg.Tx, embedlog.Logger) {
+	skipShort(t)
+
+	shared.once.Do(func() {
+		shared.conn, shared.err = setup()
+		logger = embedlog.NewLogger(true, true)
+	})
+	if shared.err != nil {
+		t.Fatal(shared.err)
+	}
+
+	tx, err := shared.conn.BeginContext(context.WithoutCancel(t.Context()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, pg.ErrTxDone) {
+			t.Fatal(err)
+		}
+	})
+
+	return tx, logger
+}
+
+// skipShort skips database tests in short mode, use in-memory repositories for unit tests.
+func skipShort(t *testing.T) {
+	if t != nil && testing.Short() {
+		t.Skip("database is not used in short mode")
+	}
+}
+
 func setup() (*pg.DB, error) {
 	var (
 		pghost = getenv("PGHOST", "localhost")
@@ -62,6 +125,14 @@ func setup() (*pg.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	created.once.Do(func() {
+		created.err = createDatabase(cfg)
+	})
+	if created.err != nil {
+		return nil, fmt.Errorf("create test database: %w", created.err)
+	}
+
 	conn := pg.Connect(cfg)
 
 	if r := getenv("DB_LOG_QUERY", "false"); r == "true" {
@@ -71,6 +142,49 @@ func setup() (*pg.DB, error) {
 	return conn, nil
 }
 
+// createDatabase creates database from cfg and loads embedded schema and init data if database doesn't exist.
+func createDatabase(cfg *pg.Options) error {
+	ctx := context.Background()
+	maintenance := *cfg
+	maintenance.Database = "postgres"
+	maintenance.PoolSize = 1
+
+	mdb := pg.Connect(&maintenance)
+	defer mdb.Close()
+
+	conn := mdb.Conn()
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "select pg_advisory_lock(?)", createLock); err != nil {
+		return err
+	}
+	defer func() { _, _ = conn.ExecContext(ctx, "select pg_advisory_unlock(?)", createLock) }()
+
+	var exists bool
+	if _, err := conn.QueryOneContext(ctx, pg.Scan(&exists), "select exists(select 1 from pg_database where datname = ?)", cfg.Database); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, "create database ?", pg.Ident(cfg.Database)); err != nil {
+		return err
+	}
+
+	dbc := pg.Connect(cfg)
+	defer dbc.Close()
+
+	// schema is exported with BOM
+	for _, sql := range []string{strings.TrimPrefix(docs.Schema, "\uFEFF"), docs.InitData} {
+		if _, err := dbc.ExecContext(ctx, sql); err != nil {
+			_, _ = conn.ExecContext(ctx, "drop database ?", pg.Ident(cfg.Database))
+			return err
+		}
+	}
+
+	return nil
+}
+
 type testDBLogQuery struct{}
 
 func (d testDBLogQuery) BeforeQuery(ctx context.Context, _ *pg.QueryEvent) (context.Context, error) {
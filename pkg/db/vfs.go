@@ -2,67 +2,67 @@ package db
 
 import (
 	"context"
-	"errors"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/go-pg/pg/v10/orm"
 )
 
 type VfsRepo struct {
-	db      orm.DB
-	filters map[string][]Filter
-	sort    map[string][]SortField
-	join    map[string][]string
+	vfsFiles   Repo[VfsFile, *VfsFileSearch]
+	vfsFolders Repo[VfsFolder, *VfsFolderSearch]
 }
 
 // NewVfsRepo returns new repository
-func NewVfsRepo(db orm.DB) VfsRepo {
+func NewVfsRepo(db orm.DB, opts ...RepoOption) VfsRepo {
 	return VfsRepo{
-		db: db,
-		filters: map[string][]Filter{
-			Tables.VfsFile.Name:   {StatusFilter},
-			Tables.VfsFolder.Name: {StatusFilter},
-		},
-		sort: map[string][]SortField{
-			Tables.VfsFile.Name:   {{Column: Columns.VfsFile.CreatedAt, Direction: SortDesc}},
-			Tables.VfsFolder.Name: {{Column: Columns.VfsFolder.CreatedAt, Direction: SortDesc}},
-		},
-		join: map[string][]string{
-			Tables.VfsFile.Name:   {TableColumns, Columns.VfsFile.Folder},
-			Tables.VfsFolder.Name: {TableColumns, Columns.VfsFolder.ParentFolder},
-		},
+		vfsFiles: NewRepo[VfsFile, *VfsFileSearch](db, RepoOpts{
+			Filters: []Filter{StatusFilter},
+			Sort:    []SortField{{Column: Columns.VfsFile.CreatedAt, Direction: SortDesc}},
+			Join:    []string{TableColumns, Columns.VfsFile.Folder},
+		}.with(opts)).WithChangeEvents().WithOutbox(nil),
+		vfsFolders: NewRepo[VfsFolder, *VfsFolderSearch](db, RepoOpts{
+			Filters: []Filter{StatusFilter},
+			Sort:    []SortField{{Column: Columns.VfsFolder.CreatedAt, Direction: SortDesc}},
+			Join:    []string{TableColumns, Columns.VfsFolder.ParentFolder},
+		}.with(opts)).WithChangeEvents().WithOutbox(nil),
 	}
 }
 
 // WithTransaction is a function that wraps VfsRepo with pg.Tx transaction.
 func (vr VfsRepo) WithTransaction(tx *pg.Tx) VfsRepo {
-	vr.db = tx
+	vr.vfsFiles = vr.vfsFiles.WithTransaction(tx)
+	vr.vfsFolders = vr.vfsFolders.WithTransaction(tx)
 	return vr
 }
 
 // WithEnabledOnly is a function that adds "statusId"=1 as base filter.
 func (vr VfsRepo) WithEnabledOnly() VfsRepo {
-	f := make(map[string][]Filter, len(vr.filters))
-	for i := range vr.filters {
-		f[i] = make([]Filter, len(vr.filters[i]))
-		copy(f[i], vr.filters[i])
-		f[i] = append(f[i], StatusEnabledFilter)
-	}
-	vr.filters = f
-
+	vr.vfsFiles = vr.vfsFiles.WithEnabledOnly()
+	vr.vfsFolders = vr.vfsFolders.WithEnabledOnly()
 	return vr
 }
 
 /*** VfsFile ***/
 
+// VfsFiles returns generic VfsFile repository.
+func (vr VfsRepo) VfsFiles() Repo[VfsFile, *VfsFileSearch] {
+	return vr.vfsFiles
+}
+
+// WithVfsFileHook is a function that adds hook for VfsFile write event.
+func (vr VfsRepo) WithVfsFileHook(event HookEvent, fn HookFunc[VfsFile]) VfsRepo {
+	vr.vfsFiles = vr.vfsFiles.WithHook(event, fn)
+	return vr
+}
+
 // FullVfsFile returns full joins with all columns
 func (vr VfsRepo) FullVfsFile() OpFunc {
-	return WithColumns(vr.join[Tables.VfsFile.Name]...)
+	return vr.vfsFiles.Full()
 }
 
 // DefaultVfsFileSort returns default sort.
 func (vr VfsRepo) DefaultVfsFileSort() OpFunc {
-	return WithSort(vr.sort[Tables.VfsFile.Name]...)
+	return vr.vfsFiles.DefaultSort()
 }
 
 // VfsFileByID is a function that returns VfsFile by ID(s) or nil.
@@ -72,67 +72,70 @@ func (vr VfsRepo) VfsFileByID(ctx context.Context, id int, ops ...OpFunc) (*VfsF
 
 // OneVfsFile is a function that returns one VfsFile by filters. It could return pg.ErrMultiRows.
 func (vr VfsRepo) OneVfsFile(ctx context.Context, search *VfsFileSearch, ops ...OpFunc) (*VfsFile, error) {
-	obj := &VfsFile{}
-	err := buildQuery(ctx, vr.db, obj, search, vr.filters[Tables.VfsFile.Name], PagerTwo, ops...).Select()
-
-	if errors.Is(err, pg.ErrMultiRows) {
-		return nil, err
-	} else if errors.Is(err, pg.ErrNoRows) {
-		return nil, nil
-	}
-
-	return obj, err
+	return vr.vfsFiles.One(ctx, search, ops...)
 }
 
 // VfsFilesByFilters returns VfsFile list.
-func (vr VfsRepo) VfsFilesByFilters(ctx context.Context, search *VfsFileSearch, pager Pager, ops ...OpFunc) (vfsFiles []VfsFile, err error) {
-	err = buildQuery(ctx, vr.db, &vfsFiles, search, vr.filters[Tables.VfsFile.Name], pager, ops...).Select()
-	return
+func (vr VfsRepo) VfsFilesByFilters(ctx context.Context, search *VfsFileSearch, pager Pager, ops ...OpFunc) ([]VfsFile, error) {
+	return vr.vfsFiles.ByFilters(ctx, search, pager, ops...)
 }
 
 // CountVfsFiles returns count
 func (vr VfsRepo) CountVfsFiles(ctx context.Context, search *VfsFileSearch, ops ...OpFunc) (int, error) {
-	return buildQuery(ctx, vr.db, &VfsFile{}, search, vr.filters[Tables.VfsFile.Name], PagerOne, ops...).Count()
+	return vr.vfsFiles.Count(ctx, search, ops...)
 }
 
 // AddVfsFile adds VfsFile to DB.
 func (vr VfsRepo) AddVfsFile(ctx context.Context, vfsFile *VfsFile, ops ...OpFunc) (*VfsFile, error) {
-	q := vr.db.ModelContext(ctx, vfsFile)
-	applyOps(q, ops...)
-	_, err := q.ExcludeColumn(Columns.VfsFile.CreatedAt).Insert()
-
-	return vfsFile, err
+	return vr.vfsFiles.Add(ctx, vfsFile, ops...)
 }
 
 // UpdateVfsFile updates VfsFile in DB.
 func (vr VfsRepo) UpdateVfsFile(ctx context.Context, vfsFile *VfsFile, ops ...OpFunc) (bool, error) {
-	q := vr.db.ModelContext(ctx, vfsFile).WherePK()
-	applyOps(q, ops...)
-	res, err := q.ExcludeColumn(Columns.VfsFile.CreatedAt).Update()
-	if err != nil {
-		return false, err
-	}
+	return vr.vfsFiles.Update(ctx, vfsFile, ops...)
+}
+
+// AddVfsFiles adds VfsFile list to DB in batches.
+func (vr VfsRepo) AddVfsFiles(ctx context.Context, vfsFiles []VfsFile, ops ...OpFunc) error {
+	return vr.vfsFiles.AddMany(ctx, vfsFiles, DefaultBatchSize, ops...)
+}
+
+// UpsertVfsFiles inserts VfsFile list or updates given columns on conflict by target columns.
+func (vr VfsRepo) UpsertVfsFiles(ctx context.Context, vfsFiles []VfsFile, target, update []string) error {
+	return vr.vfsFiles.Upsert(ctx, vfsFiles, target, update, DefaultBatchSize)
+}
 
-	return res.RowsAffected() > 0, err
+// UpdateVfsFilesByFilters sets columns from vfsFile to all VfsFile matched by search. It returns IDs of updated rows.
+func (vr VfsRepo) UpdateVfsFilesByFilters(ctx context.Context, search *VfsFileSearch, vfsFile *VfsFile, columns ...string) ([]int, error) {
+	return vr.vfsFiles.UpdateByFilters(ctx, search, vfsFile, columns...)
 }
 
 // DeleteVfsFile set statusId to deleted in DB.
 func (vr VfsRepo) DeleteVfsFile(ctx context.Context, id int) (deleted bool, err error) {
-	vfsFile := &VfsFile{ID: id, StatusID: StatusDeleted}
-
-	return vr.UpdateVfsFile(ctx, vfsFile, WithColumns(Columns.VfsFile.StatusID))
+	return vr.vfsFiles.Delete(ctx, id)
 }
 
 /*** VfsFolder ***/
 
+// VfsFolders returns generic VfsFolder repository.
+func (vr VfsRepo) VfsFolders() Repo[VfsFolder, *VfsFolderSearch] {
+	return vr.vfsFolders
+}
+
+// WithVfsFolderHook is a function that adds hook for VfsFolder write event.
+func (vr VfsRepo) WithVfsFolderHook(event HookEvent, fn HookFunc[VfsFolder]) VfsRepo {
+	vr.vfsFolders = vr.vfsFolders.WithHook(event, fn)
+	return vr
+}
+
 // FullVfsFolder returns full joins with all columns
 func (vr VfsRepo) FullVfsFolder() OpFunc {
-	return WithColumns(vr.join[Tables.VfsFolder.Name]...)
+	return vr.vfsFolders.Full()
 }
 
 // DefaultVfsFolderSort returns default sort.
 func (vr VfsRepo) DefaultVfsFolderSort() OpFunc {
-	return WithSort(vr.sort[Tables.VfsFolder.Name]...)
+	return vr.vfsFolders.DefaultSort()
 }
 
 // VfsFolderByID is a function that returns VfsFolder by ID(s) or nil.
@@ -142,53 +145,45 @@ func (vr VfsRepo) VfsFolderByID(ctx context.Context, id int, ops ...OpFunc) (*Vf
 
 // OneVfsFolder is a function that returns one VfsFolder by filters. It could return pg.ErrMultiRows.
 func (vr VfsRepo) OneVfsFolder(ctx context.Context, search *VfsFolderSearch, ops ...OpFunc) (*VfsFolder, error) {
-	obj := &VfsFolder{}
-	err := buildQuery(ctx, vr.db, obj, search, vr.filters[Tables.VfsFolder.Name], PagerTwo, ops...).Select()
-
-	if errors.Is(err, pg.ErrMultiRows) {
-		return nil, err
-	} else if errors.Is(err, pg.ErrNoRows) {
-		return nil, nil
-	}
-
-	return obj, err
+	return vr.vfsFolders.One(ctx, search, ops...)
 }
 
 // VfsFoldersByFilters returns VfsFolder list.
-func (vr VfsRepo) VfsFoldersByFilters(ctx context.Context, search *VfsFolderSearch, pager Pager, ops ...OpFunc) (vfsFolders []VfsFolder, err error) {
-	err = buildQuery(ctx, vr.db, &vfsFolders, search, vr.filters[Tables.VfsFolder.Name], pager, ops...).Select()
-	return
+func (vr VfsRepo) VfsFoldersByFilters(ctx context.Context, search *VfsFolderSearch, pager Pager, ops ...OpFunc) ([]VfsFolder, error) {
+	return vr.vfsFolders.ByFilters(ctx, search, pager, ops...)
 }
 
 // CountVfsFolders returns count
 func (vr VfsRepo) CountVfsFolders(ctx context.Context, search *VfsFolderSearch, ops ...OpFunc) (int, error) {
-	return buildQuery(ctx, vr.db, &VfsFolder{}, search, vr.filters[Tables.VfsFolder.Name], PagerOne, ops...).Count()
+	return vr.vfsFolders.Count(ctx, search, ops...)
 }
 
 // AddVfsFolder adds VfsFolder to DB.
 func (vr VfsRepo) AddVfsFolder(ctx context.Context, vfsFolder *VfsFolder, ops ...OpFunc) (*VfsFolder, error) {
-	q := vr.db.ModelContext(ctx, vfsFolder)
-	applyOps(q, ops...)
-	_, err := q.ExcludeColumn(Columns.VfsFolder.CreatedAt).Insert()
-
-	return vfsFolder, err
+	return vr.vfsFolders.Add(ctx, vfsFolder, ops...)
 }
 
 // UpdateVfsFolder updates VfsFolder in DB.
 func (vr VfsRepo) UpdateVfsFolder(ctx context.Context, vfsFolder *VfsFolder, ops ...OpFunc) (bool, error) {
-	q := vr.db.ModelContext(ctx, vfsFolder).WherePK()
-	applyOps(q, ops...)
-	res, err := q.ExcludeColumn(Columns.VfsFolder.CreatedAt).Update()
-	if err != nil {
-		return false, err
-	}
+	return vr.vfsFolders.Update(ctx, vfsFolder, ops...)
+}
+
+// AddVfsFolders adds VfsFolder list to DB in batches.
+func (vr VfsRepo) AddVfsFolders(ctx context.Context, vfsFolders []VfsFolder, ops ...OpFunc) error {
+	return vr.vfsFolders.AddMany(ctx, vfsFolders, DefaultBatchSize, ops...)
+}
+
+// UpsertVfsFolders inserts VfsFolder list or updates given columns on conflict by target columns.
+func (vr VfsRepo) UpsertVfsFolders(ctx context.Context, vfsFolders []VfsFolder, target, update []string) error {
+	return vr.vfsFolders.Upsert(ctx, vfsFolders, target, update, DefaultBatchSize)
+}
 
-	return res.RowsAffected() > 0, err
+// UpdateVfsFoldersByFilters sets columns from vfsFolder to all VfsFolder matched by search. It returns IDs of updated rows.
+func (vr VfsRepo) UpdateVfsFoldersByFilters(ctx context.Context, search *VfsFolderSearch, vfsFolder *VfsFolder, columns ...string) ([]int, error) {
+	return vr.vfsFolders.UpdateByFilters(ctx, search, vfsFolder, columns...)
 }
 
 // DeleteVfsFolder set statusId to deleted in DB.
 func (vr VfsRepo) DeleteVfsFolder(ctx context.Context, id int) (deleted bool, err error) {
-	vfsFolder := &VfsFolder{ID: id, StatusID: StatusDeleted}
-
-	return vr.UpdateVfsFolder(ctx, vfsFolder, WithColumns(Columns.VfsFolder.StatusID))
+	return vr.vfsFolders.Delete(ctx, id)
 }
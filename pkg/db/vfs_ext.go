@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// privateFolderQuery checks folder and its parents for isPrivate flag.
+const privateFolderQuery = `WITH RECURSIVE "tree" AS (
+	SELECT "folderId", "parentFolderId", "isPrivate" FROM "vfsFolders" WHERE "folderId" = ?0
+	UNION ALL
+	SELECT f."folderId", f."parentFolderId", f."isPrivate" FROM "vfsFolders" f JOIN "tree" t ON f."folderId" = t."parentFolderId"
+)
+SELECT coalesce(bool_or("isPrivate"), false) FROM "tree"`
+
+// IsPrivateFolder checks that folder or any of its parents is private.
+func (vr VfsRepo) IsPrivateFolder(ctx context.Context, folderID int) (bool, error) {
+	var private bool
+	_, err := vr.vfsFolders.DB().QueryOneContext(ctx, pg.Scan(&private), privateFolderQuery, folderID)
+	return private, err
+}
+
+// VfsFolderBranch returns folder with its parents, root folder is first.
+func (vr VfsRepo) VfsFolderBranch(ctx context.Context, folderID int) (list []VfsFolder, err error) {
+	_, err = vr.vfsFolders.DB().QueryContext(ctx, &list, `WITH RECURSIVE "tree" AS (
+	SELECT *, 1 AS "level" FROM "vfsFolders" WHERE "folderId" = ?
+	UNION ALL
+	SELECT f.*, t."level" + 1 FROM "vfsFolders" f JOIN "tree" t ON f."folderId" = t."parentFolderId"
+)
+SELECT * FROM "tree" ORDER BY "level" DESC`, folderID)
+	return
+}
+
+// privateFileQuery checks folders of not deleted files with path and their parents for isPrivate flag.
+const privateFileQuery = `WITH RECURSIVE "tree" AS (
+	SELECT "folderId", "parentFolderId", "isPrivate" FROM "vfsFolders"
+	WHERE "folderId" IN (SELECT "folderId" FROM "vfsFiles" WHERE "path" = ?0 AND "statusId" != ?1)
+	UNION ALL
+	SELECT f."folderId", f."parentFolderId", f."isPrivate" FROM "vfsFolders" f JOIN "tree" t ON f."folderId" = t."parentFolderId"
+)
+SELECT coalesce(bool_or("isPrivate"), false) FROM "tree"`
+
+// IsPrivateFile checks that any file with relative path is in private folder, files with the same content share path.
+// Unknown files are public.
+func (vr VfsRepo) IsPrivateFile(ctx context.Context, path string) (bool, error) {
+	var private bool
+	_, err := vr.vfsFiles.DB().QueryOneContext(ctx, pg.Scan(&private), privateFileQuery, path, StatusDeleted)
+	return private, err
+}
+
+// SetVfsFolderPrivate updates isPrivate flag of folder, nested folders inherit it.
+func (vr VfsRepo) SetVfsFolderPrivate(ctx context.Context, folderID int, private bool) (bool, error) {
+	return vr.UpdateVfsFolder(ctx, &VfsFolder{ID: folderID, IsPrivate: private}, WithColumns(Columns.VfsFolder.IsPrivate))
+}
+
+// VfsUsage is a count and total size of vfs files or hashes.
+type VfsUsage struct {
+	Namespace string `pg:"namespace"`
+	Files     int    `pg:"files"`
+	Size      int64  `pg:"size"`
+}
+
+// folderUsageQuery sums sizes of not deleted files in folder and its subfolders.
+const folderUsageQuery = `WITH RECURSIVE "tree" AS (
+	SELECT "folderId" FROM "vfsFolders" WHERE "folderId" = ?0
+	UNION ALL
+	SELECT f."folderId" FROM "vfsFolders" f JOIN "tree" t ON f."parentFolderId" = t."folderId"
+)
+SELECT count(vf."fileId") AS "files", coalesce(sum(vf."fileSize"), 0) AS "size"
+FROM "vfsFiles" vf JOIN "tree" t ON vf."folderId" = t."folderId" WHERE vf."statusId" != ?1`
+
+// folderQuotasQuery returns folder and its parents with quota.
+const folderQuotasQuery = `WITH RECURSIVE "tree" AS (
+	SELECT "folderId", "parentFolderId", "quota" FROM "vfsFolders" WHERE "folderId" = ?0
+	UNION ALL
+	SELECT f."folderId", f."parentFolderId", f."quota" FROM "vfsFolders" f JOIN "tree" t ON f."folderId" = t."parentFolderId"
+)
+SELECT "folderId", "quota" FROM "tree" WHERE "quota" IS NOT NULL`
+
+// VfsFolderUsage returns usage of folder including subfolders.
+func (vr VfsRepo) VfsFolderUsage(ctx context.Context, folderID int) (VfsUsage, error) {
+	var u VfsUsage
+	_, err := vr.vfsFolders.DB().QueryOneContext(ctx, &u, folderUsageQuery, folderID, StatusDeleted)
+	return u, err
+}
+
+// VfsFolderQuotas returns folder and its parents which have quota, quota of parent limits all subfolders.
+func (vr VfsRepo) VfsFolderQuotas(ctx context.Context, folderID int) ([]VfsFolder, error) {
+	var list []VfsFolder
+	_, err := vr.vfsFolders.DB().QueryContext(ctx, &list, folderQuotasQuery, folderID)
+	return list, err
+}
+
+// SetVfsFolderQuota updates quota of folder in bytes, nil removes quota.
+func (vr VfsRepo) SetVfsFolderQuota(ctx context.Context, folderID int, quota *int64) (bool, error) {
+	return vr.UpdateVfsFolder(ctx, &VfsFolder{ID: folderID, Quota: quota}, WithColumns(Columns.VfsFolder.Quota))
+}
+
+// VfsNamespaceUsage returns usage of vfsHashes by namespaces.
+func (vr VfsRepo) VfsNamespaceUsage(ctx context.Context) ([]VfsUsage, error) {
+	var list []VfsUsage
+	_, err := vr.vfsFiles.DB().QueryContext(ctx, &list,
+		`SELECT "namespace", count(*) AS "files", coalesce(sum("fileSize"), 0) AS "size" FROM "vfsHashes" GROUP BY "namespace" ORDER BY "namespace"`)
+	return list, err
+}
+
+const (
+	VfsScanPending  = "pending"
+	VfsScanClean    = "clean"
+	VfsScanInfected = "infected"
+)
+
+// VfsHashScan is a vfsHashes row waiting for content scan.
+type VfsHashScan struct {
+	Hash      string `pg:"hash"`
+	Namespace string `pg:"namespace"`
+	Extension string `pg:"extension"`
+}
+
+// SetVfsFileScanStatus updates scan status of file, infected file is disabled.
+func (vr VfsRepo) SetVfsFileScanStatus(ctx context.Context, fileID int, status string) (bool, error) {
+	f, columns := &VfsFile{ID: fileID, ScanStatus: &status}, []string{Columns.VfsFile.ScanStatus}
+	if status == VfsScanInfected {
+		f.StatusID = StatusDisabled
+		columns = append(columns, Columns.VfsFile.StatusID)
+	}
+	return vr.UpdateVfsFile(ctx, f, WithColumns(columns...))
+}
+
+// SetVfsHashScanStatus updates scan status of hash.
+func (vr VfsRepo) SetVfsHashScanStatus(ctx context.Context, ns, hash, status string) (bool, error) {
+	res, err := vr.vfsFiles.DB().ExecContext(ctx,
+		`UPDATE "vfsHashes" SET "scanStatus" = ? WHERE "hash" = ? AND "namespace" = ?`, status, hash, ns)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// PendingVfsFiles returns not deleted files waiting for scan, oldest first.
+func (vr VfsRepo) PendingVfsFiles(ctx context.Context, limit int) ([]VfsFile, error) {
+	var list []VfsFile
+	err := vr.vfsFiles.DB().ModelContext(ctx, &list).
+		Where(`? = ?`, pg.Ident(Columns.VfsFile.ScanStatus), VfsScanPending).
+		Where(`? != ?`, pg.Ident(Columns.VfsFile.StatusID), StatusDeleted).
+		Order(Columns.VfsFile.ID).
+		Limit(limit).
+		Select()
+	return list, err
+}
+
+// PendingVfsHashes returns hashes waiting for scan, oldest first.
+func (vr VfsRepo) PendingVfsHashes(ctx context.Context, limit int) ([]VfsHashScan, error) {
+	var list []VfsHashScan
+	_, err := vr.vfsFiles.DB().QueryContext(ctx, &list,
+		`SELECT "hash", "namespace", "extension" FROM "vfsHashes" WHERE "scanStatus" = ? ORDER BY "createdAt" LIMIT ?`, VfsScanPending, limit)
+	return list, err
+}
+
+// SetVfsFileHash updates content hash of file.
+func (vr VfsRepo) SetVfsFileHash(ctx context.Context, fileID int, hash string) (bool, error) {
+	return vr.UpdateVfsFile(ctx, &VfsFile{ID: fileID, Hash: &hash}, WithColumns(Columns.VfsFile.Hash))
+}
+
+// VfsDuplicate is a content hash of not deleted files uploaded more than once.
+type VfsDuplicate struct {
+	Hash  string `pg:"hash"`
+	Files int    `pg:"files"`
+	Size  int64  `pg:"size"`
+}
+
+// VfsDuplicates returns hashes of files with the same content, hashes which waste more space are first.
+func (vr VfsRepo) VfsDuplicates(ctx context.Context, limit int) ([]VfsDuplicate, error) {
+	var list []VfsDuplicate
+	_, err := vr.vfsFiles.DB().QueryContext(ctx, &list,
+		`SELECT "hash", count(*) AS "files", coalesce(max("fileSize"), 0) AS "size" FROM "vfsFiles"
+		WHERE "hash" IS NOT NULL AND "statusId" != ? GROUP BY "hash" HAVING count(*) > 1
+		ORDER BY (count(*) - 1) * coalesce(max("fileSize"), 0) DESC, "hash" LIMIT ?`, StatusDeleted, limit)
+	return list, err
+}
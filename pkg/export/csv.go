@@ -0,0 +1,65 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// utf8BOM makes Excel detect UTF-8 encoding of CSV file.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// formulaPrefixes are first characters of cells that spreadsheet applications evaluate as formulas.
+const formulaPrefixes = "=+-@"
+
+// CSVWriter writes rows as CSV with UTF-8 BOM. Text cells starting with formula characters are prefixed with
+// a single quote, so spreadsheet applications show them as text.
+type CSVWriter struct {
+	w   io.Writer
+	csv *csv.Writer
+	buf []string
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: w, csv: csv.NewWriter(w)}
+}
+
+func (cw *CSVWriter) WriteHeader(titles []string) error {
+	if _, err := cw.w.Write(utf8BOM); err != nil {
+		return err
+	}
+	return cw.csv.Write(titles)
+}
+
+func (cw *CSVWriter) WriteRow(values []any) error {
+	cw.buf = cw.buf[:0]
+	for _, v := range values {
+		s := formatValue(v)
+		if _, ok := number(v); !ok {
+			s = escapeFormula(s)
+		}
+		cw.buf = append(cw.buf, s)
+	}
+	return cw.csv.Write(cw.buf)
+}
+
+func (cw *CSVWriter) Close() error {
+	cw.csv.Flush()
+	return cw.csv.Error()
+}
+
+// escapeFormula prefixes s with a single quote if s starts with formula character.
+func escapeFormula(s string) string {
+	if s != "" && strings.IndexByte(formulaPrefixes, s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeFormula removes single quote added by escapeFormula.
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.IndexByte(formulaPrefixes, s[1]) >= 0 {
+		return s[1:]
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+// Package export streams lists of models as CSV or XLSX files.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Writer writes table rows to underlying stream. Close must be called to finish file.
+type Writer interface {
+	WriteHeader(titles []string) error
+	WriteRow(values []any) error
+	Close() error
+}
+
+// NewWriter returns Writer for format.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatXLSX:
+		return NewXLSXWriter(w)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// ContentType returns MIME type for format.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Column is an exported field of model.
+type Column struct {
+	// Name is JSON name of field.
+	Name string
+	// Title is a header of column.
+	Title string
+
+	index []int
+}
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	stringerType = reflect.TypeFor[fmt.Stringer]()
+)
+
+// Columns returns exported columns of model T by JSON tags. Titles override column headers by JSON name.
+// Nested structs are exported only if they implement fmt.Stringer.
+func Columns[T any](titles map[string]string) []Column {
+	var cols []Column
+	t := reflect.TypeFor[T]()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" || !exportable(f.Type) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		title := name
+		if v, ok := titles[name]; ok {
+			title = v
+		}
+
+		cols = append(cols, Column{Name: name, Title: title, index: f.Index})
+	}
+
+	return cols
+}
+
+// exportable checks that field of type t could be written as a cell.
+func exportable(t reflect.Type) bool {
+	if t.Implements(stringerType) || t == timeType {
+		return true
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if t.Implements(stringerType) || reflect.PointerTo(t).Implements(stringerType) || t == timeType {
+			return true
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// Titles returns column headers.
+func Titles(cols []Column) []string {
+	titles := make([]string, len(cols))
+	for i := range cols {
+		titles[i] = cols[i].Title
+	}
+	return titles
+}
+
+// Values returns cell values of obj for columns. Nil pointers are returned as nil.
+func Values[T any](cols []Column, obj *T) []any {
+	v := reflect.ValueOf(obj).Elem()
+	values := make([]any, len(cols))
+	for i := range cols {
+		f := v.FieldByIndex(cols[i].index)
+		if f.Kind() == reflect.Pointer {
+			if f.IsNil() {
+				continue
+			}
+			if !f.Type().Implements(stringerType) {
+				f = f.Elem()
+			}
+		}
+		values[i] = f.Interface()
+	}
+
+	return values
+}
+
+// Write writes header and all rows from iterator each to w. Function each must call row for every object.
+func Write[T any](w Writer, cols []Column, each func(row func(*T) error) error) error {
+	if err := w.WriteHeader(Titles(cols)); err != nil {
+		return err
+	}
+
+	return each(func(obj *T) error {
+		return w.WriteRow(Values(cols, obj))
+	})
+}
+
+// formatValue converts cell value to string.
+func formatValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return val.Format(timeLayout)
+	case bool:
+		return strconv.FormatBool(val)
+	case fmt.Stringer:
+		return val.String()
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,143 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type status struct{ title string }
+
+func (s status) String() string { return s.title }
+
+type item struct {
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	Secret    string     `json:"-"`
+	Price     *float64   `json:"price"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt"`
+	Status    *status    `json:"status"`
+	Tags      []string   `json:"tags"`
+	IsActive  bool       `json:"isActive"`
+}
+
+func testItems() []item {
+	price := 9.5
+	return []item{
+		{ID: 1, Title: `Hello, "world"`, Price: &price, CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Status: &status{"Enabled"}, IsActive: true},
+		{ID: 2, Title: "<b>&</b>", CreatedAt: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+}
+
+func write(format string) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	So(err, ShouldBeNil)
+
+	items := testItems()
+	err = Write(w, Columns[item](map[string]string{"title": "Title"}), func(row func(*item) error) error {
+		for i := range items {
+			if err := row(&items[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	So(err, ShouldBeNil)
+	So(w.Close(), ShouldBeNil)
+
+	return buf.Bytes()
+}
+
+func TestColumns(t *testing.T) {
+	Convey("Test columns by json tags", t, func() {
+		cols := Columns[item](map[string]string{"id": "ID"})
+		So(Titles(cols), ShouldResemble, []string{"ID", "title", "price", "createdAt", "updatedAt", "status", "isActive"})
+	})
+}
+
+func TestCSVWriter(t *testing.T) {
+	Convey("Test csv export", t, func() {
+		b := write(FormatCSV)
+		So(bytes.HasPrefix(b, utf8BOM), ShouldBeTrue)
+		So(string(b[len(utf8BOM):]), ShouldEqual, "id,Title,price,createdAt,updatedAt,status,isActive\n"+
+			"1,\"Hello, \"\"world\"\"\",9.5,2024-01-02 03:04:05,,Enabled,true\n"+
+			"2,<b>&</b>,,2024-01-03 00:00:00,,,false\n")
+	})
+
+	Convey("Test csv formula escaping", t, func() {
+		var buf bytes.Buffer
+		w := NewCSVWriter(&buf)
+		So(w.WriteRow([]any{"=1+2", "+7", "-x", "@SUM(A1)", "a=b", -5, -1.5}), ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "'=1+2,'+7,'-x,'@SUM(A1),a=b,-5,-1.5\n")
+	})
+
+	Convey("Test unknown format", t, func() {
+		_, err := NewWriter("pdf", io.Discard)
+		So(err, ShouldWrap, ErrUnknownFormat)
+	})
+}
+
+func TestXLSXWriter(t *testing.T) {
+	Convey("Test xlsx export", t, func() {
+		b := write(FormatXLSX)
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		So(err, ShouldBeNil)
+
+		files := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			So(err, ShouldBeNil)
+			data, err := io.ReadAll(rc)
+			So(err, ShouldBeNil)
+			files[f.Name] = string(data)
+		}
+		So(files, ShouldContainKey, "[Content_Types].xml")
+		So(files, ShouldContainKey, "xl/workbook.xml")
+
+		var sheet struct {
+			Rows []struct {
+				Cells []struct {
+					Type  string `xml:"t,attr"`
+					Value string `xml:"v"`
+					Text  string `xml:"is>t"`
+				} `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		So(xml.Unmarshal([]byte(files["xl/worksheets/sheet1.xml"]), &sheet), ShouldBeNil)
+		So(sheet.Rows, ShouldHaveLength, 3)
+		So(sheet.Rows[0].Cells[1].Text, ShouldEqual, "Title")
+		So(sheet.Rows[1].Cells[0].Type, ShouldEqual, "n")
+		So(sheet.Rows[1].Cells[0].Value, ShouldEqual, "1")
+		So(sheet.Rows[1].Cells[2].Value, ShouldEqual, "9.5")
+		So(sheet.Rows[1].Cells[5].Text, ShouldEqual, "Enabled")
+		So(sheet.Rows[1].Cells[6].Type, ShouldEqual, "b")
+		So(sheet.Rows[2].Cells[1].Text, ShouldEqual, "<b>&</b>")
+		So(strings.Count(files["xl/worksheets/sheet1.xml"], "<c/>"), ShouldEqual, 4)
+	})
+
+	Convey("Test xlsx control characters", t, func() {
+		var buf bytes.Buffer
+		w, err := NewXLSXWriter(&buf)
+		So(err, ShouldBeNil)
+		So(w.WriteRow([]any{"a\x00b\x1bc\td\uFFFEe"}), ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+
+		b := buf.Bytes()
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		So(err, ShouldBeNil)
+		rc, err := zr.Open("xl/worksheets/sheet1.xml")
+		So(err, ShouldBeNil)
+		data, err := io.ReadAll(rc)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldContainSubstring, "<t xml:space=\"preserve\">abc&#x9;de</t>")
+	})
+}
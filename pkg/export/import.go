@@ -0,0 +1,230 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownColumn = errors.New("unknown column")
+
+// dateLayouts are accepted formats of time values in imported files.
+var dateLayouts = []string{timeLayout, time.RFC3339, "2006-01-02 15:04", "2006-01-02", "02.01.2006 15:04:05", "02.01.2006"}
+
+// RowFunc receives parsed row. Line is a line number in file, header is line 1.
+// Errs contains parse errors by JSON field name, such fields are left zero.
+type RowFunc[T any] func(line int, obj *T, errs map[string]error) error
+
+// ReadCSV reads CSV file with header into T row by row. Header columns are matched with T fields
+// by JSON name or by title, case-insensitive. Delimiter is detected from header: comma or semicolon.
+// Formula characters escaped by CSVWriter are unescaped.
+func ReadCSV[T any](r io.Reader, titles map[string]string, fn RowFunc[T]) error {
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(len(utf8BOM)); bytes.Equal(bom, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = detectComma(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+
+	cols, err := importColumns[T](header, titles)
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, er := cr.Read()
+		if errors.Is(er, io.EOF) {
+			return nil
+		} else if er != nil {
+			return er
+		}
+
+		if isEmpty(record) {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+
+		obj := new(T)
+		v := reflect.ValueOf(obj).Elem()
+		var errs map[string]error
+		for i, col := range cols {
+			if col == nil || i >= len(record) {
+				continue
+			}
+
+			if err = setValue(v.FieldByIndex(col.index), unescapeFormula(strings.TrimSpace(record[i]))); err != nil {
+				if errs == nil {
+					errs = make(map[string]error)
+				}
+				errs[col.Name] = err
+			}
+		}
+
+		if err = fn(line, obj, errs); err != nil {
+			return err
+		}
+	}
+}
+
+// importColumns maps header to columns of T. Empty header cells are skipped.
+func importColumns[T any](header []string, titles map[string]string) ([]*Column, error) {
+	byName := make(map[string]Column)
+	t := reflect.TypeFor[T]()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" || !settable(f.Type) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		col := Column{Name: name, Title: name, index: f.Index}
+		byName[strings.ToLower(name)] = col
+		if title, ok := titles[name]; ok {
+			col.Title = title
+			byName[strings.ToLower(title)] = col
+		}
+	}
+
+	cols := make([]*Column, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+
+		col, ok := byName[h]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, header[i])
+		}
+		cols[i] = &col
+	}
+
+	return cols, nil
+}
+
+// detectComma returns semicolon if it is used in first line instead of comma, e.g. by Excel with some locales.
+func detectComma(br *bufio.Reader) rune {
+	b, _ := br.Peek(br.Size())
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[:i]
+	}
+
+	if bytes.Count(b, []byte{';'}) > bytes.Count(b, []byte{','}) {
+		return ';'
+	}
+	return ','
+}
+
+// settable checks that field of type t could be parsed from cell.
+func settable(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// setValue parses s into field f. Empty string sets nil for pointers and zero value for others.
+func setValue(f reflect.Value, s string) error {
+	if s == "" {
+		f.SetZero()
+		return nil
+	}
+
+	if f.Kind() == reflect.Pointer {
+		f.Set(reflect.New(f.Type().Elem()))
+		f = f.Elem()
+	}
+
+	if f.Type() == timeType {
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				f.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid time %q", s)
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := parseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+
+	return nil
+}
+
+// parseBool parses bool values, including yes/no and 1/0.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "y", "да":
+		return true, nil
+	case "no", "n", "нет":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+func isEmpty(record []string) bool {
+	for _, s := range record {
+		if strings.TrimSpace(s) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadCSV(t *testing.T) {
+	type row struct {
+		line int
+		obj  item
+		errs map[string]error
+	}
+	read := func(data string) ([]row, error) {
+		var rows []row
+		err := ReadCSV(strings.NewReader(data), map[string]string{"title": "Название"}, func(line int, obj *item, errs map[string]error) error {
+			rows = append(rows, row{line: line, obj: *obj, errs: errs})
+			return nil
+		})
+		return rows, err
+	}
+
+	Convey("Test csv import", t, func() {
+		Convey("comma separated with titles and BOM", func() {
+			rows, err := read(string(utf8BOM) + "ID,название,price,createdAt,isActive\n" +
+				"1,\"Hello, world\",\"9,5\",2024-01-02,да\n" +
+				",,,,\n" +
+				"x,Bad,,tomorrow,maybe\n")
+			So(err, ShouldBeNil)
+			So(rows, ShouldHaveLength, 2)
+
+			So(rows[0].line, ShouldEqual, 2)
+			So(rows[0].errs, ShouldBeNil)
+			So(rows[0].obj.ID, ShouldEqual, 1)
+			So(rows[0].obj.Title, ShouldEqual, "Hello, world")
+			So(*rows[0].obj.Price, ShouldEqual, 9.5)
+			So(rows[0].obj.CreatedAt, ShouldEqual, time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local))
+			So(rows[0].obj.IsActive, ShouldBeTrue)
+
+			So(rows[1].line, ShouldEqual, 4)
+			So(rows[1].obj.Title, ShouldEqual, "Bad")
+			So(rows[1].obj.Price, ShouldBeNil)
+			So(rows[1].errs, ShouldContainKey, "id")
+			So(rows[1].errs, ShouldContainKey, "createdAt")
+			So(rows[1].errs, ShouldContainKey, "isActive")
+		})
+
+		Convey("semicolon separated", func() {
+			rows, err := read("id;title\n5;Five\n")
+			So(err, ShouldBeNil)
+			So(rows, ShouldHaveLength, 1)
+			So(rows[0].obj.ID, ShouldEqual, 5)
+			So(rows[0].obj.Title, ShouldEqual, "Five")
+		})
+
+		Convey("escaped formulas", func() {
+			rows, err := read("id,title\n1,'=1+2\n2,'x\n")
+			So(err, ShouldBeNil)
+			So(rows, ShouldHaveLength, 2)
+			So(rows[0].obj.Title, ShouldEqual, "=1+2")
+			So(rows[1].obj.Title, ShouldEqual, "'x")
+		})
+
+		Convey("unknown column", func() {
+			_, err := read("id,status\n1,enabled\n")
+			So(err, ShouldWrap, ErrUnknownColumn)
+		})
+	})
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+
+	"apisrv/pkg/db"
+)
+
+const (
+	// TopicJobs is a push topic for job progress.
+	TopicJobs = "jobs"
+	// EventProgress is a push event with Progress data.
+	EventProgress = "progress"
+)
+
+type jobCtx string
+
+const jobKey jobCtx = "jobs.job"
+
+// Progress is a job state pushed to TopicJobs on start, finish and progress reports of handler.
+type Progress struct {
+	ID      int     `json:"id"`
+	Kind    string  `json:"kind"`
+	State   string  `json:"state"`
+	Percent *int    `json:"percent,omitempty"`
+	Error   *string `json:"error,omitempty"`
+}
+
+// Progress pushes completion percent of current job. It should be called from job handler, otherwise it does nothing.
+func (q *Queue) Progress(ctx context.Context, percent int) {
+	job, ok := ctx.Value(jobKey).(db.Job)
+	if !ok {
+		return
+	}
+
+	percent = min(max(percent, 0), 100)
+	q.push(ctx, job, &percent)
+}
+
+// push sends job progress to connected clients, errors are only logged.
+func (q *Queue) push(ctx context.Context, job db.Job, percent *int) {
+	p := Progress{ID: job.ID, Kind: job.Kind, State: job.State, Percent: percent, Error: job.Error}
+	if err := db.Push(ctx, q.dbo, TopicJobs, EventProgress, p); err != nil {
+		q.Error(ctx, "push job progress failed", "err", err, "id", job.ID, "kind", job.Kind)
+	}
+}
@@ -0,0 +1,379 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmkteam/embedlog"
+)
+
+const (
+	defaultWorkers      = 4
+	defaultPollInterval = time.Second
+	defaultMaxAttempts  = 10
+	defaultLockTimeout  = time.Minute * 30
+	minRetryDelay       = time.Second * 5
+	maxRetryDelay       = time.Hour * 6
+)
+
+var (
+	ErrUnknownKind = errors.New("unknown job kind")
+
+	// ErrCancel could be returned (wrapped) by handler to fail job without retries.
+	ErrCancel = errors.New("job canceled by handler")
+)
+
+type Config struct {
+	// Workers is a count of concurrently processed jobs.
+	Workers int
+	// PollInterval is a delay between polls of jobs table when queue is empty.
+	PollInterval time.Duration
+	// MaxAttempts is a default count of attempts for a job.
+	MaxAttempts int
+	// LockTimeout is a time after which running job is considered lost and is returned to queue.
+	// Lock of running job is refreshed every third of timeout.
+	LockTimeout time.Duration
+}
+
+// HandlerFunc processes raw job args.
+type HandlerFunc func(ctx context.Context, job db.Job) error
+
+// Handle registers typed handler for kind. Job args are decoded to T.
+func Handle[T any](q *Queue, kind string, fn func(ctx context.Context, args T) error) {
+	q.Register(kind, func(ctx context.Context, job db.Job) error {
+		var args T
+		if err := json.Unmarshal(job.Args, &args); err != nil {
+			return fmt.Errorf("%w: decode args: %w", ErrCancel, err)
+		}
+		return fn(ctx, args)
+	})
+}
+
+// EnqueueOption sets optional job parameters.
+type EnqueueOption func(job *db.Job)
+
+// WithUniqueKey sets unique key of job. Job is not added if there is pending or running job with the same key.
+func WithUniqueKey(key string) EnqueueOption {
+	return func(job *db.Job) {
+		job.UniqueKey = &key
+	}
+}
+
+// WithRunAt schedules job to given time.
+func WithRunAt(t time.Time) EnqueueOption {
+	return func(job *db.Job) {
+		job.RunAt = t
+	}
+}
+
+// WithMaxAttempts overrides default count of attempts.
+func WithMaxAttempts(n int) EnqueueOption {
+	return func(job *db.Job) {
+		job.MaxAttempts = n
+	}
+}
+
+// Queue is a Postgres-backed job queue with pool of workers.
+type Queue struct {
+	embedlog.Logger
+	dbo  db.DB
+	repo db.JobRepo
+	cfg  Config
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+
+	slots    chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	// jobsCtx is canceled when running jobs are not finished before Stop deadline
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+
+	statJobs     *prometheus.CounterVec
+	statDuration *prometheus.HistogramVec
+}
+
+// NewQueue returns new job queue.
+func NewQueue(dbo db.DB, logger embedlog.Logger, cfg Config) *Queue {
+	if cfg.Workers == 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.LockTimeout == 0 {
+		cfg.LockTimeout = defaultLockTimeout
+	}
+
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	return &Queue{
+		Logger:     logger,
+		dbo:        dbo,
+		repo:       db.NewJobRepo(dbo),
+		cfg:        cfg,
+		handlers:   make(map[string]HandlerFunc),
+		slots:      make(chan struct{}, cfg.Workers),
+		done:       make(chan struct{}),
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
+
+		statJobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "app",
+			Subsystem: "jobs",
+			Name:      "processed_total",
+			Help:      "Processed jobs by kind and result.",
+		}, []string{"kind", "result"}),
+		statDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "app",
+			Subsystem: "jobs",
+			Name:      "duration_seconds",
+			Help:      "Job processing duration by kind.",
+		}, []string{"kind"}),
+	}
+}
+
+// RegisterMetrics registers queue metrics in prometheus.
+func (q *Queue) RegisterMetrics() {
+	prometheus.MustRegister(q.statJobs, q.statDuration)
+}
+
+// Register registers handler for job kind. Handlers must be registered before Run.
+func (q *Queue) Register(kind string, fn HandlerFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = fn
+}
+
+// Enqueue adds job with args to queue. It returns nil job if job with the same unique key is already active.
+func (q *Queue) Enqueue(ctx context.Context, kind string, args any, opts ...EnqueueOption) (*db.Job, error) {
+	return Enqueue(ctx, q.repo, kind, args, append([]EnqueueOption{WithMaxAttempts(q.cfg.MaxAttempts)}, opts...)...)
+}
+
+// Enqueue adds job with args using repo. Use repo.WithTransaction to enqueue job in the same transaction as data change.
+func Enqueue(ctx context.Context, repo db.JobRepo, kind string, args any, opts ...EnqueueOption) (*db.Job, error) {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &db.Job{Kind: kind, Args: b, RunAt: time.Now(), MaxAttempts: defaultMaxAttempts}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	ok, err := repo.EnqueueJob(ctx, job)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Run starts fetching and processing jobs in background until ctx is done or Stop is called.
+func (q *Queue) Run(ctx context.Context) {
+	q.mu.RLock()
+	kinds := slices.Sorted(maps.Keys(q.handlers))
+	q.mu.RUnlock()
+	if len(kinds) == 0 {
+		return
+	}
+
+	q.wg.Add(1)
+	go q.loop(ctx, kinds)
+}
+
+// loop fetches jobs of kinds while there are free workers and waits for next poll.
+func (q *Queue) loop(ctx context.Context, kinds []string) {
+	defer q.wg.Done()
+
+	// handlers are not canceled with ctx, so running jobs are drained on Stop until its deadline
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	context.AfterFunc(q.jobsCtx, cancel)
+	t := time.NewTicker(q.cfg.PollInterval)
+	defer t.Stop()
+
+	for {
+		q.requeueStale(ctx)
+
+		// fetch while there are jobs and free workers
+		for {
+			free := cap(q.slots) - len(q.slots)
+			if free == 0 {
+				break
+			}
+
+			list, err := q.repo.FetchJobs(ctx, kinds, free)
+			if err != nil {
+				q.Error(ctx, "fetch jobs failed", "err", err)
+				break
+			}
+
+			for i := range list {
+				q.slots <- struct{}{}
+				q.wg.Add(1)
+				go q.process(jobCtx, list[i])
+			}
+
+			if len(list) < free {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Stop stops fetching new jobs and waits for running jobs. If ctx is done before jobs are finished,
+// their contexts are canceled and ctx error is returned without waiting, such jobs are requeued by lock timeout.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.done) })
+
+	stopped := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		q.cancelJobs()
+		return ctx.Err()
+	}
+}
+
+// process runs handler for job and saves result.
+func (q *Queue) process(ctx context.Context, job db.Job) {
+	defer func() {
+		<-q.slots
+		q.wg.Done()
+	}()
+
+	q.push(ctx, job, nil)
+
+	stopHeartbeat := q.heartbeat(ctx, job)
+	start := time.Now()
+	err := q.call(context.WithValue(ctx, jobKey, job), job)
+	q.statDuration.WithLabelValues(job.Kind).Observe(time.Since(start).Seconds())
+	stopHeartbeat()
+
+	now := time.Now()
+	job.LockedAt = nil
+	job.Attempts++
+	result := finish(&job, err, now)
+	q.statJobs.WithLabelValues(job.Kind, result).Inc()
+	if err != nil {
+		q.Error(ctx, "job failed", "err", err, "id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "state", job.State)
+	}
+
+	if ok, er := q.repo.FinishJob(ctx, &job); er != nil {
+		q.Error(ctx, "save job failed", "err", er, "id", job.ID, "kind", job.Kind)
+	} else if ok {
+		q.push(ctx, job, nil)
+	}
+}
+
+// heartbeat refreshes lock of running job until returned func is called.
+func (q *Queue) heartbeat(ctx context.Context, job db.Job) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(q.cfg.LockTimeout / 3)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if _, err := q.repo.TouchJob(ctx, job.ID); err != nil {
+					q.Error(ctx, "refresh job lock failed", "err", err, "id", job.ID, "kind", job.Kind)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// call runs handler and converts panics to errors.
+func (q *Queue) call(ctx context.Context, job db.Job) (err error) {
+	q.mu.RLock()
+	fn, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKind, job.Kind)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+		}
+	}()
+
+	return fn(ctx, job)
+}
+
+// requeueStale returns lost running jobs to queue.
+func (q *Queue) requeueStale(ctx context.Context) {
+	n, err := q.repo.RequeueStaleJobs(ctx, q.cfg.LockTimeout)
+	if err != nil {
+		q.Error(ctx, "requeue stale jobs failed", "err", err)
+	} else if n > 0 {
+		q.Print(ctx, "stale jobs requeued", "count", n)
+	}
+}
+
+// finish sets job state by handler result and returns result name for metrics.
+func finish(job *db.Job, err error, now time.Time) string {
+	if err == nil {
+		job.State, job.FinishedAt, job.Error = db.JobStateDone, &now, nil
+		return "done"
+	}
+
+	msg := err.Error()
+	job.Error = &msg
+	if errors.Is(err, ErrCancel) || job.Attempts >= job.MaxAttempts {
+		job.State, job.FinishedAt = db.JobStateFailed, &now
+		return "failed"
+	}
+
+	job.State = db.JobStatePending
+	job.RunAt = now.Add(retryDelay(job.Attempts))
+	return "retry"
+}
+
+// retryDelay returns exponential delay for next attempt.
+func retryDelay(attempts int) time.Duration {
+	d := minRetryDelay
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
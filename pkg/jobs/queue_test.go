@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"apisrv/pkg/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/embedlog"
+)
+
+func TestFinish(t *testing.T) {
+	now := time.Now()
+
+	Convey("Test finish job", t, func() {
+		Convey("success", func() {
+			job := db.Job{Attempts: 1, MaxAttempts: 3}
+			So(finish(&job, nil, now), ShouldEqual, "done")
+			So(job.State, ShouldEqual, db.JobStateDone)
+			So(job.FinishedAt, ShouldNotBeNil)
+		})
+
+		Convey("retry", func() {
+			job := db.Job{Attempts: 2, MaxAttempts: 3}
+			So(finish(&job, errors.New("oops"), now), ShouldEqual, "retry")
+			So(job.State, ShouldEqual, db.JobStatePending)
+			So(job.RunAt, ShouldEqual, now.Add(minRetryDelay*2))
+			So(*job.Error, ShouldEqual, "oops")
+		})
+
+		Convey("attempts exceeded", func() {
+			job := db.Job{Attempts: 3, MaxAttempts: 3}
+			So(finish(&job, errors.New("oops"), now), ShouldEqual, "failed")
+			So(job.State, ShouldEqual, db.JobStateFailed)
+		})
+
+		Convey("canceled by handler", func() {
+			job := db.Job{Attempts: 1, MaxAttempts: 3}
+			So(finish(&job, ErrCancel, now), ShouldEqual, "failed")
+		})
+	})
+}
+
+func TestQueue_call(t *testing.T) {
+	type args struct {
+		Name string `json:"name"`
+	}
+
+	Convey("Test handler call", t, func() {
+		q := NewQueue(db.DB{}, embedlog.Logger{}, Config{})
+		var got string
+		Handle(q, "greet", func(_ context.Context, a args) error {
+			got = a.Name
+			return nil
+		})
+		q.Register("panic", func(context.Context, db.Job) error {
+			panic("boom")
+		})
+
+		So(q.call(context.Background(), db.Job{Kind: "greet", Args: json.RawMessage(`{"name":"bob"}`)}), ShouldBeNil)
+		So(got, ShouldEqual, "bob")
+		So(q.call(context.Background(), db.Job{Kind: "greet", Args: json.RawMessage(`[]`)}), ShouldWrap, ErrCancel)
+		So(q.call(context.Background(), db.Job{Kind: "unknown"}), ShouldWrap, ErrUnknownKind)
+		So(q.call(context.Background(), db.Job{Kind: "panic"}), ShouldNotBeNil)
+	})
+}
+
+func TestQueue_Stop(t *testing.T) {
+	Convey("Test queue stop with hung job", t, func() {
+		q := NewQueue(db.DB{}, embedlog.Logger{}, Config{})
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			<-q.jobsCtx.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Millisecond*10)
+		defer cancel()
+		So(q.Stop(ctx), ShouldEqual, context.DeadlineExceeded)
+		So(q.jobsCtx.Err(), ShouldNotBeNil)
+
+		// canceled job is finished, repeated stop waits for it
+		So(q.Stop(t.Context()), ShouldBeNil)
+	})
+}
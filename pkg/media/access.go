@@ -0,0 +1,240 @@
+package media
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/vmkteam/vfs"
+	vfsdb "github.com/vmkteam/vfs/db"
+)
+
+const (
+	paramExpires   = "expires"
+	paramSignature = "signature"
+
+	defaultURLExpires = time.Hour
+	accessCacheTTL    = time.Minute
+	accessCacheSize   = 10000
+)
+
+var ErrNoSecret = errors.New("access secret is required for private files")
+
+type AccessConfig struct {
+	// Secret is a HMAC key of signed urls, it is required if there are private namespaces or folders.
+	Secret string
+	// PrivateNamespaces are hash namespaces served by signed urls only, root hashes are in "default" namespace.
+	PrivateNamespaces []string
+	// URLExpires is a default lifetime of signed urls.
+	URLExpires time.Duration
+}
+
+// access checks visibility of files and signs urls of private ones.
+// Files of private folders are private too, visibility of folders is cached for a minute.
+type access struct {
+	secret     []byte
+	namespaces []string
+	expires    time.Duration
+	repo       db.VfsAccessRepository
+
+	mu    sync.Mutex
+	cache map[string]accessEntry
+}
+
+type accessEntry struct {
+	private bool
+	until   time.Time
+}
+
+func newAccess(cfg AccessConfig, repo db.VfsAccessRepository) *access {
+	if cfg.URLExpires == 0 {
+		cfg.URLExpires = defaultURLExpires
+	}
+
+	return &access{
+		secret:     []byte(cfg.Secret),
+		namespaces: cfg.PrivateNamespaces,
+		expires:    cfg.URLExpires,
+		repo:       repo,
+		cache:      make(map[string]accessEntry),
+	}
+}
+
+// sign returns signature of web path valid until expires.
+func (a *access) sign(webPath string, expires int64) string {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write([]byte(webPath + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signURL returns web path with expires and signature params.
+func (a *access) signURL(webPath string, ttl time.Duration) string {
+	if ttl <= 0 {
+		ttl = a.expires
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	q := url.Values{paramExpires: {strconv.FormatInt(expires, 10)}, paramSignature: {a.sign(webPath, expires)}}
+	return webPath + "?" + q.Encode()
+}
+
+// verify checks signature and expiration of request url.
+func (a *access) verify(r *http.Request) bool {
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get(paramExpires), 10, 64)
+	if err != nil || time.Now().Unix() > expires || len(a.secret) == 0 {
+		return false
+	}
+
+	return hmac.Equal([]byte(q.Get(paramSignature)), []byte(a.sign(r.URL.Path, expires)))
+}
+
+// isPrivateNamespace checks namespace by config, public namespace is default one.
+func (a *access) isPrivateNamespace(ns string) bool {
+	if ns == vfs.NamespacePublic {
+		ns = vfs.DefaultNamespace
+	}
+	return slices.Contains(a.namespaces, ns)
+}
+
+// isPrivateFile checks folder of file with db path, result is cached.
+func (a *access) isPrivateFile(ctx context.Context, filePath string) (bool, error) {
+	if a.repo == nil {
+		return false, nil
+	}
+
+	a.mu.Lock()
+	e, ok := a.cache[filePath]
+	a.mu.Unlock()
+	if ok && time.Now().Before(e.until) {
+		return e.private, nil
+	}
+
+	private, err := a.repo.IsPrivateFile(ctx, filePath)
+	if err != nil {
+		return false, err
+	}
+
+	a.mu.Lock()
+	if len(a.cache) >= accessCacheSize {
+		clear(a.cache)
+	}
+	a.cache[filePath] = accessEntry{private: private, until: time.Now().Add(accessCacheTTL)}
+	a.mu.Unlock()
+
+	return private, nil
+}
+
+// reset clears cached visibility of files.
+func (a *access) reset() {
+	a.mu.Lock()
+	clear(a.cache)
+	a.mu.Unlock()
+}
+
+// isPrivate checks storage key: hash files and presets by namespace, other files by namespace and folder.
+func (m *Media) isPrivate(ctx context.Context, key string) (bool, error) {
+	if img, ok := m.parseImagePath(key); ok {
+		return m.access.isPrivateNamespace(img.ns), nil
+	}
+
+	parts := strings.Split(key, "/")
+	ns := vfs.NamespacePublic
+	if len(parts) > 2 && m.vfs.IsValidNamespace(parts[0]) && parts[0] != vfs.NamespacePublic {
+		ns, parts = parts[0], parts[1:]
+	}
+	if m.access.isPrivateNamespace(ns) {
+		return true, nil
+	}
+
+	// hash files are a/bc/hash.ext
+	if n := len(parts); n == 3 && isHash(strings.TrimSuffix(parts[2], path.Ext(parts[2]))) {
+		return false, nil
+	}
+
+	return m.access.isPrivateFile(ctx, strings.Join(parts, "/"))
+}
+
+// checkAccess returns false if file is private and request url is not signed.
+func (m *Media) checkAccess(r *http.Request, key string) (bool, error) {
+	private, err := m.isPrivate(r.Context(), key)
+	if err != nil || !private {
+		return !private, err
+	}
+
+	return m.access.verify(r), nil
+}
+
+// SignURL returns signed web path of media file, zero ttl means default expiration.
+func (m *Media) SignURL(webPath string, ttl time.Duration) string {
+	return m.access.signURL(webPath, ttl)
+}
+
+// FileURL returns signed url of vfs file.
+func (m *Media) FileURL(ctx context.Context, fileID int, ttl time.Duration) (string, error) {
+	f, err := m.repo.VfsFileByID(ctx, fileID, vfsdb.EnabledOnly())
+	if err != nil {
+		return "", newInternalError(err)
+	} else if f == nil {
+		return "", vfs.ErrNotFound
+	}
+
+	return m.SignURL(path.Join(m.cfg.WebPath, f.Path), ttl), nil
+}
+
+// HashURL returns signed url of hash file or its preset image if preset is not empty.
+func (m *Media) HashURL(ctx context.Context, ns, hash, preset string, ttl time.Duration) (string, error) {
+	if !isHash(hash) || (ns != vfs.NamespacePublic && !m.vfs.IsValidNamespace(ns)) {
+		return "", vfs.ErrInvalidInput
+	}
+	if _, ok := m.presets[preset]; preset != "" && !ok {
+		return "", vfs.ErrInvalidInput
+	}
+
+	hashNs := ns
+	if hashNs == vfs.NamespacePublic {
+		hashNs = vfs.DefaultNamespace
+	} else if ns == vfs.DefaultNamespace {
+		ns = vfs.NamespacePublic
+	}
+
+	h, err := m.repo.VfsHashByID(ctx, hash, hashNs)
+	if err != nil {
+		return "", newInternalError(err)
+	} else if h == nil {
+		return "", vfs.ErrNotFound
+	}
+
+	return m.SignURL(path.Join(m.cfg.WebPath, ns, preset, vfs.NewFileHash(h.Hash, h.Extension).File()), ttl), nil
+}
+
+// SetFolderPrivate changes visibility of folder and its files including nested folders.
+func (m *Media) SetFolderPrivate(ctx context.Context, folderID int, private bool) (bool, error) {
+	if m.access.repo == nil {
+		return false, newError(http.StatusNotImplemented)
+	} else if private && len(m.access.secret) == 0 {
+		return false, newInternalError(ErrNoSecret)
+	}
+
+	ok, err := m.access.repo.SetVfsFolderPrivate(ctx, folderID, private)
+	if err != nil {
+		return false, newInternalError(err)
+	} else if !ok {
+		return false, vfs.ErrNotFound
+	}
+
+	m.access.reset()
+	return true, nil
+}
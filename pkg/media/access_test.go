@@ -0,0 +1,75 @@
+package media
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/db/test"
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/embedlog"
+)
+
+func TestMedia_Access(t *testing.T) {
+	Convey("Test private files", t, func() {
+		ctx, local := t.Context(), storage.NewLocal(t.TempDir())
+		hash := "70c565ef460af43688b7ee6251028db9"
+		for _, key := range []string{"7/0c/" + hash + ".txt", "docs/7/0c/" + hash + ".txt", "202610/1_1.txt", "202610/3_2.txt"} {
+			So(local.Put(ctx, key, strings.NewReader("hello"), 5, ""), ShouldBeNil)
+		}
+
+		repo := test.NewVfsAccessRepo([]db.VfsFolder{
+			{ID: 1, Title: "private", IsPrivate: true, StatusID: db.StatusEnabled},
+			{ID: 2, Title: "public", StatusID: db.StatusEnabled},
+			{ID: 3, Title: "nested", ParentFolderID: test.Ptr(2), StatusID: db.StatusEnabled},
+		}, db.VfsFile{ID: 1, FolderID: 1, Path: "202610/1_1.txt", StatusID: db.StatusEnabled},
+			db.VfsFile{ID: 2, FolderID: 3, Path: "202610/3_2.txt", StatusID: db.StatusEnabled})
+
+		m := newTestMedia(t, local, storage.ServeProxy)
+		m.access = newAccess(AccessConfig{Secret: "secret", PrivateNamespaces: []string{"docs"}}, repo)
+		h := m.ServeHandler()
+		serve := func(target string) int {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			return w.Code
+		}
+
+		Convey("Private namespaces require signature", func() {
+			So(serve("/media/7/0c/"+hash+".txt"), ShouldEqual, http.StatusOK)
+			So(serve("/media/docs/7/0c/"+hash+".txt"), ShouldEqual, http.StatusForbidden)
+			So(serve("/media/docs/square/7/0c/"+hash+".png"), ShouldEqual, http.StatusForbidden)
+			So(serve(m.SignURL("/media/docs/7/0c/"+hash+".txt", 0)), ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Private folders require signature", func() {
+			So(serve("/media/202610/1_1.txt"), ShouldEqual, http.StatusForbidden)
+			So(serve(m.SignURL("/media/202610/1_1.txt", time.Minute)), ShouldEqual, http.StatusOK)
+			So(serve("/media/202610/3_2.txt"), ShouldEqual, http.StatusOK)
+
+			ok, err := m.SetFolderPrivate(ctx, 2, true)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(serve("/media/202610/3_2.txt"), ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("Invalid signatures are rejected", func() {
+			u := m.SignURL("/media/202610/1_1.txt", 0)
+			So(serve(strings.Replace(u, "signature=", "signature=0", 1)), ShouldEqual, http.StatusForbidden)
+			So(serve(strings.Replace(u, "1_1.txt", "3_2.txt", 1)), ShouldEqual, http.StatusOK)
+
+			expired := time.Now().Add(-time.Second).Unix()
+			So(serve(fmt.Sprintf("/media/202610/1_1.txt?expires=%d&signature=%s", expired, m.access.sign("/media/202610/1_1.txt", expired))), ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("Secret is required for private namespaces", func() {
+			_, err := New(m.vfs, local, Config{Access: AccessConfig{PrivateNamespaces: []string{"docs"}}}, nil, embedlog.NewDevLogger())
+			So(err, ShouldEqual, ErrNoSecret)
+		})
+	})
+}
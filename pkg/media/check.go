@@ -0,0 +1,286 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"apisrv/pkg/storage"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/vmkteam/vfs"
+	vfsdb "github.com/vmkteam/vfs/db"
+)
+
+const (
+	OrphansReport     = "report"
+	OrphansQuarantine = "quarantine"
+	OrphansDelete     = "delete"
+
+	defaultGracePeriod      = time.Hour * 24
+	defaultQuarantinePrefix = "_quarantine"
+	reportDirPerm           = 0755
+	reportFilePerm          = 0644
+)
+
+var ErrUnknownOrphansAction = errors.New("unknown orphans action")
+
+type CheckConfig struct {
+	// Orphans is an action for files without vfsFiles and vfsHashes rows: report (default), quarantine or delete.
+	Orphans string
+	// GracePeriod protects recently modified orphans, e.g. uploads which are not saved to db yet.
+	GracePeriod time.Duration
+	// QuarantinePrefix is a storage folder for quarantined orphans and not scanned files, it is never served.
+	QuarantinePrefix string
+	// ReportPath is a directory for json reports, reports are only logged if it is empty.
+	ReportPath string
+}
+
+// CheckReport is a result of consistency check. Lists contain storage keys.
+type CheckReport struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	DryRun     bool      `json:"dryRun"`
+	Orphans    string    `json:"orphansAction"`
+
+	// Objects is a count of checked storage objects.
+	Objects int `json:"objects"`
+	// MissingFiles are vfsFiles without objects, they are marked with fileExists=false.
+	MissingFiles []string `json:"missingFiles"`
+	// RestoredFiles are vfsFiles marked as missing which objects exist, they are marked with fileExists=true.
+	RestoredFiles []string `json:"restoredFiles"`
+	// MissingHashes are vfsHashes without objects.
+	MissingHashes []string `json:"missingHashes"`
+	// OrphanFiles are objects without rows older than grace period.
+	OrphanFiles []string `json:"orphanFiles"`
+	// Errors are failed quarantine or delete operations.
+	Errors []string `json:"errors"`
+}
+
+// checkRefs are storage keys referenced by db rows. Files with the same content share path, see Media.DeleteFiles.
+type checkRefs struct {
+	files  map[string][]vfsdb.VfsFile
+	hashes map[string]struct{}
+}
+
+// filePath returns path of regular files by storage key, files uploaded to namespace are stored with namespace prefix.
+func (cr checkRefs) filePath(m *Media, key string) (string, bool) {
+	if _, ok := cr.files[key]; ok {
+		return key, true
+	}
+
+	ns, rest, ok := strings.Cut(key, "/")
+	if !ok || ns == vfs.NamespacePublic || !m.vfs.IsValidNamespace(ns) {
+		return "", false
+	}
+	_, ok = cr.files[rest]
+	return rest, ok
+}
+
+// Check compares storage with vfsFiles and vfsHashes. Missing files are marked in db,
+// orphans are reported, quarantined or deleted by config. Nothing is changed in dry run mode.
+func (m *Media) Check(ctx context.Context, dryRun bool) (*CheckReport, error) {
+	startedAt := time.Now()
+	refs, err := m.loadRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r, missing, restored, err := m.reconcile(ctx, refs, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	r.StartedAt = startedAt
+
+	if !dryRun {
+		if err = m.setFilesExist(ctx, missing, false); err != nil {
+			return nil, err
+		}
+		if err = m.setFilesExist(ctx, restored, true); err != nil {
+			return nil, err
+		}
+	}
+	r.FinishedAt = time.Now()
+
+	m.Print(ctx, "vfs check finished", "dryRun", dryRun, "objects", r.Objects, "missingFiles", len(r.MissingFiles),
+		"restoredFiles", len(r.RestoredFiles), "missingHashes", len(r.MissingHashes), "orphans", len(r.OrphanFiles), "errors", len(r.Errors))
+
+	return r, m.writeReport(r)
+}
+
+// loadRefs loads paths of all vfsFiles including deleted ones and keys of vfsHashes.
+func (m *Media) loadRefs(ctx context.Context) (checkRefs, error) {
+	refs := checkRefs{files: make(map[string][]vfsdb.VfsFile), hashes: make(map[string]struct{})}
+
+	var files []vfsdb.VfsFile
+	err := m.dbc.ModelContext(ctx, &files).
+		Column(vfsdb.Columns.VfsFile.ID, vfsdb.Columns.VfsFile.Path, vfsdb.Columns.VfsFile.FileExists, vfsdb.Columns.VfsFile.StatusID).
+		Select()
+	if err != nil {
+		return refs, fmt.Errorf("load files: %w", err)
+	}
+	for _, f := range files {
+		refs.files[f.Path] = append(refs.files[f.Path], f)
+	}
+
+	var hashes []vfsdb.VfsHash
+	err = m.dbc.ModelContext(ctx, &hashes).
+		Column(vfsdb.Columns.VfsHash.Hash, vfsdb.Columns.VfsHash.Namespace, vfsdb.Columns.VfsHash.Extension).
+		Select()
+	if err != nil {
+		return refs, fmt.Errorf("load hashes: %w", err)
+	}
+	for _, h := range hashes {
+		key, err := HashKey(h.Namespace, h.Hash, h.Extension)
+		if err != nil {
+			return refs, err
+		}
+		refs.hashes[key] = struct{}{}
+	}
+
+	return refs, nil
+}
+
+// reconcile walks storage and compares it with refs. It returns ids of missing and restored files,
+// orphans are quarantined or deleted unless dry run.
+func (m *Media) reconcile(ctx context.Context, refs checkRefs, dryRun bool) (*CheckReport, []int, []int, error) {
+	r := &CheckReport{DryRun: dryRun, Orphans: m.check.Orphans}
+	seen := make(map[string]struct{})
+	deadline := time.Now().Add(-m.check.GracePeriod)
+	quarantine := m.check.QuarantinePrefix + "/"
+
+	err := m.storage.Walk(ctx, "", func(obj storage.Object) error {
+		// quarantined files of db rows are not scanned or infected, they are not missing
+		if key, ok := strings.CutPrefix(obj.Key, quarantine); ok {
+			if p, ok := refs.filePath(m, key); ok {
+				r.Objects++
+				seen[p] = struct{}{}
+			} else if _, ok = refs.hashes[key]; ok {
+				r.Objects++
+				seen[key] = struct{}{}
+			}
+			return nil
+		}
+		r.Objects++
+
+		if p, ok := refs.filePath(m, obj.Key); ok {
+			seen[p] = struct{}{}
+			return nil
+		} else if _, ok = refs.hashes[obj.Key]; ok {
+			seen[obj.Key] = struct{}{}
+			return nil
+		} else if obj.ModTime.After(deadline) {
+			return nil
+		}
+
+		r.OrphanFiles = append(r.OrphanFiles, obj.Key)
+		if dryRun {
+			return nil
+		}
+
+		var err error
+		switch m.check.Orphans {
+		case OrphansQuarantine:
+			err = m.storage.Move(ctx, obj.Key, quarantine+obj.Key)
+		case OrphansDelete:
+			err = m.storage.Delete(ctx, obj.Key)
+		}
+		if err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s %s: %s", m.check.Orphans, obj.Key, err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("walk storage: %w", err)
+	}
+
+	var missing, restored []int
+	for p, files := range refs.files {
+		_, ok := seen[p]
+		var isMissing, isRestored bool
+		for _, f := range files {
+			switch {
+			case f.StatusID == vfsdb.StatusDeleted:
+			case !ok && f.FileExists:
+				missing, isMissing = append(missing, f.ID), true
+			case ok && !f.FileExists:
+				restored, isRestored = append(restored, f.ID), true
+			}
+		}
+
+		if isMissing {
+			r.MissingFiles = append(r.MissingFiles, p)
+		}
+		if isRestored {
+			r.RestoredFiles = append(r.RestoredFiles, p)
+		}
+	}
+	for key := range refs.hashes {
+		if _, ok := seen[key]; !ok {
+			r.MissingHashes = append(r.MissingHashes, key)
+		}
+	}
+	slices.Sort(r.MissingFiles)
+	slices.Sort(r.RestoredFiles)
+	slices.Sort(r.MissingHashes)
+	slices.Sort(missing)
+	slices.Sort(restored)
+
+	return r, missing, restored, nil
+}
+
+// setFilesExist updates fileExists of vfsFiles.
+func (m *Media) setFilesExist(ctx context.Context, ids []int, exists bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := m.dbc.ModelContext(ctx, (*vfsdb.VfsFile)(nil)).
+		Set(`? = ?`, pg.Ident(vfsdb.Columns.VfsFile.FileExists), exists).
+		Where(`? IN (?)`, pg.Ident(vfsdb.Columns.VfsFile.ID), pg.In(ids)).
+		Update()
+	return err
+}
+
+// writeReport saves report to json file in report path.
+func (m *Media) writeReport(r *CheckReport) error {
+	if m.check.ReportPath == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.check.ReportPath, reportDirPerm); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(m.check.ReportPath, "vfs-check-"+r.StartedAt.Format("20060102-150405")+".json"), b, reportFilePerm)
+}
+
+// withDefaults returns config with default values.
+func (c CheckConfig) withDefaults() (CheckConfig, error) {
+	switch c.Orphans {
+	case "":
+		c.Orphans = OrphansReport
+	case OrphansReport, OrphansQuarantine, OrphansDelete:
+	default:
+		return c, fmt.Errorf("%w: %s", ErrUnknownOrphansAction, c.Orphans)
+	}
+	if c.GracePeriod == 0 {
+		c.GracePeriod = defaultGracePeriod
+	}
+	if c.QuarantinePrefix == "" {
+		c.QuarantinePrefix = defaultQuarantinePrefix
+	}
+
+	return c, nil
+}
@@ -0,0 +1,91 @@
+package media
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	vfsdb "github.com/vmkteam/vfs/db"
+)
+
+func TestMedia_Check(t *testing.T) {
+	Convey("Test consistency check", t, func() {
+		ctx, local := t.Context(), storage.NewLocal(t.TempDir())
+		hash, old := "70c565ef460af43688b7ee6251028db9", time.Now().Add(-time.Hour*48)
+		for _, key := range []string{"7/0c/" + hash + ".txt", "202610/1_1.txt", "docs/202610/1_3.txt", "orphan.txt", "new.txt"} {
+			So(local.Put(ctx, key, strings.NewReader("hello"), 5, ""), ShouldBeNil)
+			if key != "new.txt" {
+				So(os.Chtimes(local.Path(key), old, old), ShouldBeNil)
+			}
+		}
+
+		refs := checkRefs{
+			files: map[string][]vfsdb.VfsFile{
+				"202610/1_1.txt": {{ID: 1, Path: "202610/1_1.txt", FileExists: true, StatusID: vfsdb.StatusEnabled}},
+				"202610/1_2.txt": {
+					{ID: 2, Path: "202610/1_2.txt", FileExists: true, StatusID: vfsdb.StatusEnabled},
+					{ID: 5, Path: "202610/1_2.txt", FileExists: true, StatusID: vfsdb.StatusEnabled},
+				},
+				"202610/1_3.txt": {
+					{ID: 3, Path: "202610/1_3.txt", StatusID: vfsdb.StatusEnabled},
+					{ID: 6, Path: "202610/1_3.txt", StatusID: vfsdb.StatusEnabled},
+				},
+				"202610/1_4.txt": {{ID: 4, Path: "202610/1_4.txt", FileExists: true, StatusID: vfsdb.StatusDeleted}},
+			},
+			hashes: map[string]struct{}{"7/0c/" + hash + ".txt": {}, "a/bc/abc.jpg": {}},
+		}
+		m := newTestMedia(t, local, storage.ServeProxy)
+
+		Convey("Dry run reports changes", func() {
+			r, missing, restored, err := m.reconcile(ctx, refs, true)
+			So(err, ShouldBeNil)
+			So(r.Objects, ShouldEqual, 5)
+			So(r.MissingFiles, ShouldResemble, []string{"202610/1_2.txt"})
+			So(missing, ShouldResemble, []int{2, 5})
+			So(r.RestoredFiles, ShouldResemble, []string{"202610/1_3.txt"})
+			So(restored, ShouldResemble, []int{3, 6})
+			So(r.MissingHashes, ShouldResemble, []string{"a/bc/abc.jpg"})
+			So(r.OrphanFiles, ShouldResemble, []string{"orphan.txt"})
+
+			_, err = local.Stat(ctx, "orphan.txt")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Orphans are quarantined", func() {
+			m.check.Orphans = OrphansQuarantine
+			r, _, _, err := m.reconcile(ctx, refs, false)
+			So(err, ShouldBeNil)
+			So(r.OrphanFiles, ShouldResemble, []string{"orphan.txt"})
+			So(r.Errors, ShouldBeEmpty)
+			_, err = local.Stat(ctx, "_quarantine/orphan.txt")
+			So(err, ShouldBeNil)
+
+			r, _, _, err = m.reconcile(ctx, refs, false)
+			So(err, ShouldBeNil)
+			So(r.Objects, ShouldEqual, 4)
+			So(r.OrphanFiles, ShouldBeEmpty)
+		})
+
+		Convey("Orphans are deleted and report is saved", func() {
+			m.check.Orphans, m.check.ReportPath = OrphansDelete, t.TempDir()
+			r, _, _, err := m.reconcile(ctx, refs, false)
+			So(err, ShouldBeNil)
+			_, err = local.Stat(ctx, "orphan.txt")
+			So(err, ShouldEqual, storage.ErrNotFound)
+
+			So(m.writeReport(r), ShouldBeNil)
+			reports, err := os.ReadDir(m.check.ReportPath)
+			So(err, ShouldBeNil)
+			So(reports, ShouldHaveLength, 1)
+		})
+
+		Convey("Unknown orphans action is rejected", func() {
+			_, err := CheckConfig{Orphans: "archive"}.withDefaults()
+			So(err, ShouldWrap, ErrUnknownOrphansAction)
+		})
+	})
+}
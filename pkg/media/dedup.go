@@ -0,0 +1,216 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/storage"
+
+	"github.com/vmkteam/vfs"
+)
+
+const defaultDuplicatesLimit = 100
+
+// errDuplicateDeleted is returned when duplicate found for upload is deleted before new file is added.
+var errDuplicateDeleted = errors.New("duplicate is deleted")
+
+// Duplicate is a group of not deleted files with the same content.
+type Duplicate struct {
+	Hash  string
+	Size  int64
+	Files []db.VfsFile
+}
+
+// duplicateFile returns not deleted file with the same content in namespace, its physical copy is reused by new file.
+func (m *Media) duplicateFile(ctx context.Context, ns string, tf *tempFile) (*db.VfsFile, error) {
+	if m.dedup == nil {
+		return nil, nil
+	}
+
+	list, err := m.dedup.VfsFilesByFilters(ctx, &db.VfsFileSearch{Hash: &tf.hash}, db.PagerNoLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range list {
+		if f.FileSize == nil || int64(*f.FileSize) != tf.size {
+			continue
+		}
+
+		key, err := storage.Key(ns, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = m.storage.Stat(ctx, m.scanKey(key, scanStatus(f))); err == nil {
+			return &f, nil
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// addFile adds new file with its params, empty hash and scan status are not saved. File is added by db repository
+// with change events, as it is deleted by DeleteFiles.
+func (t mediaTx) addFile(ctx context.Context, f db.VfsFile, params *fileParams) (err error) {
+	if t.dedup == nil {
+		return newError(http.StatusNotImplemented)
+	}
+
+	if f.Params, err = params.dbParams(); err != nil {
+		return err
+	}
+	if f.Hash != nil && *f.Hash == "" {
+		f.Hash = nil
+	}
+	if f.ScanStatus != nil && *f.ScanStatus == "" {
+		f.ScanStatus = nil
+	}
+
+	_, err = t.dedup.AddVfsFile(ctx, &f)
+	return err
+}
+
+// DeleteFiles deletes files and removes their physical copies which are not referenced by other files,
+// it replaces vfs.Service.DeleteFiles.
+func (m *Media) DeleteFiles(ctx context.Context, fileIDs []int64) (bool, error) {
+	if len(fileIDs) == 0 {
+		return false, vfs.ErrInvalidInput
+	} else if m.dedup == nil {
+		return false, newError(http.StatusNotImplemented)
+	}
+
+	ids := make([]int, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		ids = append(ids, int(id))
+	}
+
+	// files are deleted and references are counted under paths lock, see createFile, objects are deleted after commit
+	var deleted bool
+	var unused []string
+	err := m.runInTx(ctx, func(t mediaTx) error {
+		files, err := t.dedup.VfsFilesByFilters(ctx, &db.VfsFileSearch{IDs: ids}, db.PagerNoLimit)
+		if err != nil {
+			return err
+		}
+
+		var paths []string
+		for _, f := range files {
+			if !slices.Contains(paths, f.Path) {
+				paths = append(paths, f.Path)
+			}
+		}
+		if err = t.lock(ctx, pathLocks(paths...)...); err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			if _, err = t.dedup.DeleteVfsFile(ctx, f.ID); err != nil {
+				return err
+			}
+		}
+
+		for _, p := range paths {
+			refs, err := t.dedup.CountVfsFiles(ctx, &db.VfsFileSearch{Path: &p})
+			if err != nil {
+				return err
+			} else if refs == 0 {
+				unused = append(unused, p)
+			}
+		}
+
+		deleted = len(files) > 0
+		return nil
+	})
+	if err != nil {
+		return false, newInternalError(err)
+	}
+
+	for _, p := range unused {
+		for _, key := range m.fileKeys(p) {
+			if err = errors.Join(m.storage.Delete(ctx, key), m.storage.Delete(ctx, m.quarantineKey(key))); err != nil {
+				return false, newInternalError(err)
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// pathLocks returns advisory lock names for file paths, files with the same content share path.
+func pathLocks(paths ...string) []string {
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		names = append(names, "vfsPath:"+p)
+	}
+	return names
+}
+
+// Duplicates returns groups of files with the same content, groups which waste more space are first.
+func (m *Media) Duplicates(ctx context.Context, limit int) ([]Duplicate, error) {
+	if m.dedup == nil {
+		return nil, newError(http.StatusNotImplemented)
+	} else if limit <= 0 {
+		limit = defaultDuplicatesLimit
+	}
+
+	list, err := m.dedup.VfsDuplicates(ctx, limit)
+	if err != nil {
+		return nil, newInternalError(err)
+	}
+
+	r := make([]Duplicate, 0, len(list))
+	for _, d := range list {
+		files, err := m.dedup.VfsFilesByFilters(ctx, &db.VfsFileSearch{Hash: &d.Hash}, db.PagerNoLimit)
+		if err != nil {
+			return nil, newInternalError(err)
+		}
+		r = append(r, Duplicate{Hash: d.Hash, Size: d.Size, Files: files})
+	}
+
+	return r, nil
+}
+
+// FileDuplicates returns other files with the same content as file.
+func (m *Media) FileDuplicates(ctx context.Context, fileID int) ([]db.VfsFile, error) {
+	if m.dedup == nil {
+		return nil, newError(http.StatusNotImplemented)
+	}
+
+	list, err := m.dedup.VfsFilesByFilters(ctx, &db.VfsFileSearch{ID: &fileID}, db.PagerNoLimit)
+	if err != nil {
+		return nil, newInternalError(err)
+	} else if len(list) == 0 {
+		return nil, vfs.ErrNotFound
+	} else if list[0].Hash == nil {
+		return []db.VfsFile{}, nil
+	}
+
+	files, err := m.dedup.VfsFilesByFilters(ctx, &db.VfsFileSearch{Hash: list[0].Hash}, db.PagerNoLimit)
+	if err != nil {
+		return nil, newInternalError(err)
+	}
+
+	return slices.DeleteFunc(files, func(f db.VfsFile) bool { return f.ID == fileID }), nil
+}
+
+// fileKeys returns possible storage keys of file with relative path, files uploaded to namespace are stored with namespace prefix.
+func (m *Media) fileKeys(filePath string) []string {
+	keys := []string{filePath}
+	for _, ns := range m.cfg.Namespaces {
+		keys = append(keys, ns+"/"+filePath)
+	}
+	return keys
+}
+
+// scanStatus returns scan status of file, it is empty for files uploaded without scanner.
+func scanStatus(f db.VfsFile) string {
+	if f.ScanStatus == nil {
+		return ""
+	}
+	return *f.ScanStatus
+}
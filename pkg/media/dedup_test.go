@@ -0,0 +1,101 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/db/test"
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/vfs"
+)
+
+func TestMedia_Dedup(t *testing.T) {
+	Convey("Test deduplication of files", t, func() {
+		ctx, local := t.Context(), storage.NewLocal(t.TempDir())
+		m := newTestMedia(t, local, storage.ServeProxy)
+		hash := "5d41402abc4b2a76b9719d911017c592" // hello
+		repo := test.NewVfsDedupRepo(
+			db.VfsFile{ID: 1, FolderID: 1, Title: "a", Path: "202610/1_1.txt", Hash: &hash, FileSize: test.Ptr(5), StatusID: db.StatusEnabled},
+			db.VfsFile{ID: 2, FolderID: 2, Title: "b", Path: "202610/1_1.txt", Hash: &hash, FileSize: test.Ptr(5), StatusID: db.StatusEnabled},
+			db.VfsFile{ID: 3, FolderID: 1, Title: "c", Path: "202610/1_3.txt", StatusID: db.StatusEnabled},
+		)
+		m.dedup = repo
+		So(local.Put(ctx, "docs/202610/1_1.txt", strings.NewReader("hello"), 5, ""), ShouldBeNil)
+		So(local.Put(ctx, "202610/1_3.txt", strings.NewReader("hello"), 5, ""), ShouldBeNil)
+
+		Convey("Physical copy is reused in the same namespace", func() {
+			tf, err := newTempFile(strings.NewReader("hello"))
+			So(err, ShouldBeNil)
+			defer tf.Remove()
+
+			f, err := m.duplicateFile(ctx, "docs", tf)
+			So(err, ShouldBeNil)
+			So(f, ShouldNotBeNil)
+			So(f.ID, ShouldEqual, 1)
+
+			f, err = m.duplicateFile(ctx, vfs.NamespacePublic, tf)
+			So(err, ShouldBeNil)
+			So(f, ShouldBeNil)
+		})
+
+		Convey("New file is added with hash and params by repository", func() {
+			err := m.noTx().addFile(ctx, db.VfsFile{ID: 4, FolderID: 1, Title: "d", Path: "202610/1_4.png", Hash: test.Ptr(""),
+				ScanStatus: test.Ptr(db.VfsScanClean), StatusID: db.StatusEnabled}, &fileParams{Width: 10, Height: 20})
+			So(err, ShouldBeNil)
+
+			f, err := repo.Files().ByID(ctx, 4)
+			So(err, ShouldBeNil)
+			So(f.Hash, ShouldBeNil)
+			So(*f.ScanStatus, ShouldEqual, db.VfsScanClean)
+			So(*f.Params, ShouldEqual, `{"width":10,"height":20}`)
+		})
+
+		Convey("Duplicates are found", func() {
+			list, err := m.Duplicates(ctx, 0)
+			So(err, ShouldBeNil)
+			So(list, ShouldHaveLength, 1)
+			So(list[0].Hash, ShouldEqual, hash)
+			So(list[0].Size, ShouldEqual, 5)
+			So(list[0].Files, ShouldHaveLength, 2)
+
+			files, err := m.FileDuplicates(ctx, 1)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 1)
+			So(files[0].ID, ShouldEqual, 2)
+
+			files, err = m.FileDuplicates(ctx, 3)
+			So(err, ShouldBeNil)
+			So(files, ShouldBeEmpty)
+
+			_, err = m.FileDuplicates(ctx, 4)
+			So(err, ShouldEqual, vfs.ErrNotFound)
+		})
+
+		Convey("Physical copy is removed with the last reference", func() {
+			ok, err := m.DeleteFiles(ctx, []int64{1})
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			_, err = local.Stat(ctx, "docs/202610/1_1.txt")
+			So(err, ShouldBeNil)
+
+			ok, err = m.DeleteFiles(ctx, []int64{2, 3})
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			_, err = local.Stat(ctx, "docs/202610/1_1.txt")
+			So(err, ShouldEqual, storage.ErrNotFound)
+			_, err = local.Stat(ctx, "202610/1_3.txt")
+			So(err, ShouldEqual, storage.ErrNotFound)
+
+			ok, err = m.DeleteFiles(ctx, []int64{1})
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+
+			n, err := repo.Files().Count(ctx, nil)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+	})
+}
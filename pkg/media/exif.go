@@ -0,0 +1,634 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	exifMake             = 0x010f
+	exifModel            = 0x0110
+	exifOrientation      = 0x0112
+	exifDateTime         = 0x0132
+	exifIFDPointer       = 0x8769
+	exifGPSPointer       = 0x8825
+	exifDateTimeOriginal = 0x9003
+	exifOffsetOriginal   = 0x9011
+
+	exifTimeFormat = "2006:01:02 15:04:05"
+
+	jpegAPP1  = 0xe1
+	jpegAPP14 = 0xee
+	jpegCOM   = 0xfe
+
+	colorSamples = 64
+
+	// maxJPEGHeaderSize is a max size of jpeg metadata segments read to memory.
+	maxJPEGHeaderSize = 4 << 20
+	// maxPNGExifSize is a max size of png exif chunk read to memory, larger chunk is stripped without parsing.
+	maxPNGExifSize = 1 << 20
+)
+
+var (
+	exifHeader    = []byte("Exif\x00\x00")
+	xmpHeader     = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	pngSignature  = []byte("\x89PNG\r\n\x1a\n")
+	pngXMPKeyword = []byte("XML:com.adobe.xmp\x00")
+
+	// exifMimeTypes are image formats with exif which is not stripped, they are rejected by policy that strips it.
+	exifMimeTypes = []string{"image/heic", "image/heif", "image/avif", "image/webp", "image/tiff", "image/jxl"}
+
+	errJPEGHeaderTooLarge = errors.New("jpeg header is too large")
+)
+
+// fileParams are params of vfs file: vfsdb.VfsFileParams with image metadata.
+// Orientation is an exif orientation of stored image, it is empty for normal or rotated by policy images.
+type fileParams struct {
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	TakenAt     *time.Time `json:"takenAt,omitempty"`
+	Orientation int        `json:"orientation,omitempty"`
+	Camera      string     `json:"camera,omitempty"`
+	Color       string     `json:"color,omitempty"`
+}
+
+// prepareImage extracts metadata of uploaded image and applies namespace policy: jpeg is rotated by exif orientation,
+// location or all exif is stripped from jpeg and png. Images of other formats with exif are rejected if it should be
+// stripped. Changed image is returned as a new temp file, caller removes it if it is not tf. Only metadata is read to
+// memory, pixels are decoded only for rotation or dominant color within images MaxPixels. Params are nil for
+// non-image files.
+func (m *Media) prepareImage(ns string, tf *tempFile) (*tempFile, *fileParams, error) {
+	p := m.policy(ns)
+	strip := p.StripLocation || p.StripEXIF
+	if strip && slices.Contains(exifMimeTypes, tf.mimeType) {
+		return nil, nil, newUploadError(http.StatusUnsupportedMediaType, fmt.Errorf("%w: %s", ErrMetadataNotStripped, tf.mimeType))
+	}
+
+	im := tf.imageParams()
+	if im == nil {
+		return tf, nil, nil
+	}
+
+	var (
+		params = &fileParams{Width: im.Width, Height: im.Height}
+		isJPEG = tf.mimeType == "image/jpeg"
+		header []byte
+		ex     *exifInfo
+		out    io.Reader
+		err    error
+	)
+	switch tf.mimeType {
+	case "image/jpeg":
+		// metadata of too large header is not read, it is rejected only if it should be stripped
+		if header, err = readJPEGHeader(tf, maxJPEGHeaderSize); err != nil && strip {
+			return nil, nil, newUploadError(http.StatusBadRequest, fmt.Errorf("%w: %w", ErrMetadataNotStripped, err))
+		}
+		ex = parseJPEGExif(header)
+	case "image/png":
+		if out, ex, err = cleanPNG(tf.File, tf.size, p); err != nil {
+			return nil, nil, err
+		}
+	}
+	if ex != nil {
+		params.TakenAt, params.Camera, params.Orientation = ex.takenAt, ex.camera(), ex.orientation
+	}
+	if err = tf.rewind(); err != nil {
+		return nil, nil, err
+	}
+
+	// broken or too large images are not decoded, they are stored without rotation and color, like in vfs
+	var img image.Image
+	rotate := isJPEG && p.AutoOrient && params.Orientation > 1 && params.Orientation <= 8
+	if p.DominantColor || rotate {
+		if img, err = decodeImage(tf, m.images.MaxPixels); err != nil {
+			img = nil
+		} else if p.DominantColor {
+			params.Color = dominantColor(img)
+		}
+		if err = tf.rewind(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if isJPEG && header != nil {
+		rest := io.NewSectionReader(tf, int64(len(header)), tf.size-int64(len(header)))
+		if out, err = cleanJPEG(header, rest, img, ex, p, m.images.Quality); err != nil {
+			return nil, nil, err
+		}
+
+		// rotated image has normal orientation, sides are swapped by transpose orientations 5-8
+		if out != nil && img != nil && rotate {
+			if params.Orientation >= 5 {
+				params.Width, params.Height = params.Height, params.Width
+			}
+			params.Orientation = 0
+		}
+	}
+	if out == nil {
+		return tf, params, nil
+	}
+
+	nf, err := newTempFile(out)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nf, params, nil
+}
+
+// dbParams returns params of new file with image metadata as json, vfs reads only known params.
+func (p *fileParams) dbParams() (*string, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	params := string(b)
+	return &params, nil
+}
+
+// cleanJPEG applies policy to jpeg with header and rest of data after it and returns new image or nil if nothing is
+// changed. Rotated image is re-encoded from decoded img with kept metadata segments and normal exif orientation, nil
+// img is not rotated. XMP is dropped with location as it could contain it too.
+func cleanJPEG(header []byte, rest io.Reader, img image.Image, ex *exifInfo, p Policy, quality int) (io.Reader, error) {
+	changed := false
+	if ex != nil && p.StripLocation && !p.StripEXIF {
+		changed = ex.stripGPS()
+	}
+
+	segs := jpegSegments(header)
+	drop := func(s jpegSegment) bool {
+		if s.marker != jpegAPP1 {
+			return false
+		}
+		payload := s.payload(header)
+		return (p.StripEXIF && bytes.HasPrefix(payload, exifHeader)) ||
+			((p.StripEXIF || p.StripLocation) && bytes.HasPrefix(payload, xmpHeader))
+	}
+
+	if img != nil && ex != nil && p.AutoOrient && ex.orientation > 1 && ex.orientation <= 8 {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, orient(img, ex.orientation), &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		ex.setOrientation(1)
+
+		// encoded image has no metadata, color transform of adobe segment is not valid for it
+		out := append([]byte{}, header[:2]...)
+		for _, s := range segs {
+			if (s.marker >= 0xe0 && s.marker <= 0xef && s.marker != jpegAPP14 || s.marker == jpegCOM) && !drop(s) {
+				out = append(out, header[s.start:s.end]...)
+			}
+		}
+		return io.MultiReader(bytes.NewReader(out), bytes.NewReader(buf.Bytes()[2:])), nil
+	}
+
+	out, end := append([]byte{}, header[:2]...), 2
+	for _, s := range segs {
+		if drop(s) {
+			changed = true
+		} else {
+			out = append(out, header[s.start:s.end]...)
+		}
+		end = s.end
+	}
+	if !changed {
+		return nil, nil
+	}
+
+	return io.MultiReader(bytes.NewReader(append(out, header[end:]...)), rest), nil
+}
+
+// readJPEGHeader reads jpeg segments before image data, errJPEGHeaderTooLarge is returned if they exceed maxSize.
+// Header is empty for non-jpeg data.
+func readJPEGHeader(r io.Reader, maxSize int) ([]byte, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil || header[0] != 0xff || header[1] != 0xd8 {
+		return nil, nil
+	}
+	header = []byte{0xff, 0xd8}
+	_, _ = br.Discard(2)
+
+	for {
+		b, err := br.Peek(4)
+		if err != nil || b[0] != 0xff {
+			return header, nil
+		}
+
+		switch marker := b[1]; {
+		case marker == 0xff: // fill byte
+			header = append(header, 0xff)
+			_, _ = br.Discard(1)
+			continue
+		case marker == 0xda || marker == 0xd9: // start of scan or end of image
+			return header, nil
+		}
+
+		n := int(binary.BigEndian.Uint16(b[2:]))
+		if n < 2 {
+			return header, nil
+		} else if len(header)+2+n > maxSize {
+			return nil, fmt.Errorf("%w: %v bytes", errJPEGHeaderTooLarge, maxSize)
+		}
+
+		seg := make([]byte, 2+n)
+		if _, err = io.ReadFull(br, seg); err != nil {
+			return header, nil
+		}
+		header = append(header, seg...)
+	}
+}
+
+// cleanPNG strips location or all exif and xmp chunks of png by policy and returns new image or nil if nothing is
+// changed, exif is parsed before it. Unchanged chunks are read from f after return.
+func cleanPNG(f io.ReaderAt, size int64, p Policy) (io.Reader, *exifInfo, error) {
+	var sig [8]byte
+	if _, err := f.ReadAt(sig[:], 0); err != nil || !bytes.Equal(sig[:], pngSignature) {
+		return nil, nil, nil
+	}
+
+	var (
+		ex    *exifInfo
+		parts []io.Reader
+		start int64 // start of unchanged chunks
+		hdr   [8]byte
+	)
+	// replace replaces chunk from off to end with new chunk, nil chunk is dropped
+	replace := func(off, end int64, chunk []byte) {
+		parts = append(parts, io.NewSectionReader(f, start, off-start), bytes.NewReader(chunk))
+		start = end
+	}
+
+	for off := int64(len(sig)); off+12 <= size; {
+		if _, err := f.ReadAt(hdr[:], off); err != nil {
+			return nil, nil, err
+		}
+		n, typ := int64(binary.BigEndian.Uint32(hdr[:4])), string(hdr[4:])
+		end := off + 12 + n
+		if end > size {
+			break
+		}
+
+		switch typ {
+		case "eXIf":
+			var data []byte
+			if n <= maxPNGExifSize {
+				data = make([]byte, n)
+				if _, err := f.ReadAt(data, off+8); err != nil {
+					return nil, nil, err
+				}
+				ex = parseExif(data)
+			}
+
+			switch {
+			case p.StripEXIF || (p.StripLocation && ex == nil):
+				replace(off, end, nil)
+			case p.StripLocation && ex.stripGPS():
+				replace(off, end, pngChunk(typ, data))
+			}
+		case "iTXt", "tEXt", "zTXt":
+			keyword := make([]byte, min(n, int64(len(pngXMPKeyword))))
+			if _, err := f.ReadAt(keyword, off+8); err != nil {
+				return nil, nil, err
+			}
+			if (p.StripEXIF || p.StripLocation) && bytes.Equal(keyword, pngXMPKeyword) {
+				replace(off, end, nil)
+			}
+		}
+
+		if typ == "IEND" {
+			break
+		}
+		off = end
+	}
+
+	if parts == nil {
+		return nil, ex, nil
+	}
+
+	return io.MultiReader(append(parts, io.NewSectionReader(f, start, size-start))...), ex, nil
+}
+
+// pngChunk returns png chunk with length and crc.
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(append(chunk, typ...), data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// jpegSegment is a segment of jpeg header, start and end include marker and length.
+type jpegSegment struct {
+	marker     byte
+	start, end int
+}
+
+func (s jpegSegment) payload(data []byte) []byte {
+	return data[s.start+4 : s.end]
+}
+
+// jpegSegments returns segments before image data, it is empty for non-jpeg data.
+func jpegSegments(data []byte) []jpegSegment {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil
+	}
+
+	var r []jpegSegment
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		switch {
+		case marker == 0xff: // fill byte
+			i++
+			continue
+		case marker == 0xda || marker == 0xd9: // start of scan or end of image
+			return r
+		}
+
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return r
+		}
+		r = append(r, jpegSegment{marker: marker, start: i, end: i + 2 + n})
+		i += 2 + n
+	}
+
+	return r
+}
+
+// exifInfo is a parsed exif of jpeg. Tiff is a part of jpeg data, strip methods change it in place.
+type exifInfo struct {
+	tiff  []byte
+	order binary.ByteOrder
+
+	orientation   int
+	orientationAt int // offset of orientation value
+	gpsAt         int // offset of gps ifd
+	make, model   string
+	takenAt       *time.Time
+}
+
+// exifEntry is an ifd entry, its value of size bytes is at offset value.
+type exifEntry struct {
+	tag, typ uint16
+	at       int
+	value    int
+	size     int
+}
+
+// parseJPEGExif returns exif of jpeg or nil if there is no valid exif.
+func parseJPEGExif(data []byte) *exifInfo {
+	for _, s := range jpegSegments(data) {
+		if payload := s.payload(data); s.marker == jpegAPP1 && bytes.HasPrefix(payload, exifHeader) {
+			return parseExif(payload[len(exifHeader):])
+		}
+	}
+
+	return nil
+}
+
+// parseExif parses tiff structure of exif: ifd0 with exif and gps subifds.
+func parseExif(tiff []byte) *exifInfo {
+	if len(tiff) < 8 {
+		return nil
+	}
+
+	e := &exifInfo{tiff: tiff}
+	switch string(tiff[:2]) {
+	case "II":
+		e.order = binary.LittleEndian
+	case "MM":
+		e.order = binary.BigEndian
+	default:
+		return nil
+	}
+	if e.order.Uint16(tiff[2:]) != 42 {
+		return nil
+	}
+
+	var exifAt int
+	var dateTime string
+	for _, en := range e.entries(int(e.order.Uint32(tiff[4:]))) {
+		switch en.tag {
+		case exifMake:
+			e.make = e.ascii(en)
+		case exifModel:
+			e.model = e.ascii(en)
+		case exifOrientation:
+			e.orientation, e.orientationAt = e.uint(en), en.value
+		case exifDateTime:
+			dateTime = e.ascii(en)
+		case exifIFDPointer:
+			exifAt = e.uint(en)
+		case exifGPSPointer:
+			e.gpsAt = e.uint(en)
+		}
+	}
+
+	var offset string
+	for _, en := range e.entries(exifAt) {
+		switch en.tag {
+		case exifDateTimeOriginal:
+			dateTime = e.ascii(en)
+		case exifOffsetOriginal:
+			offset = e.ascii(en)
+		}
+	}
+	e.takenAt = exifTime(dateTime, offset)
+
+	return e
+}
+
+// entries returns valid entries of ifd at offset.
+func (e *exifInfo) entries(ifd int) []exifEntry {
+	if ifd < 8 || ifd+2 > len(e.tiff) {
+		return nil
+	}
+
+	var r []exifEntry
+	for i := range int(e.order.Uint16(e.tiff[ifd:])) {
+		at := ifd + 2 + i*12
+		if at+12 > len(e.tiff) {
+			break
+		}
+
+		en := exifEntry{tag: e.order.Uint16(e.tiff[at:]), typ: e.order.Uint16(e.tiff[at+2:]), at: at, value: at + 8}
+		en.size = exifTypeSize(en.typ) * int(e.order.Uint32(e.tiff[at+4:]))
+		if en.size > 4 {
+			en.value = int(e.order.Uint32(e.tiff[at+8:]))
+		}
+		if en.size == 0 || en.value+en.size > len(e.tiff) {
+			continue
+		}
+		r = append(r, en)
+	}
+
+	return r
+}
+
+// exifTypeSize returns size of exif type, it is zero for unknown types.
+func exifTypeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 6, 7: // byte, ascii, sbyte, undefined
+		return 1
+	case 3, 8: // short, sshort
+		return 2
+	case 4, 9, 11: // long, slong, float
+		return 4
+	case 5, 10, 12: // rational, srational, double
+		return 8
+	}
+	return 0
+}
+
+func (e *exifInfo) ascii(en exifEntry) string {
+	return strings.TrimSpace(strings.TrimRight(string(e.tiff[en.value:en.value+en.size]), "\x00"))
+}
+
+// uint returns short or long value.
+func (e *exifInfo) uint(en exifEntry) int {
+	switch en.typ {
+	case 3:
+		return int(e.order.Uint16(e.tiff[en.value:]))
+	case 4:
+		return int(e.order.Uint32(e.tiff[en.value:]))
+	}
+	return 0
+}
+
+// camera returns make and model, make is skipped if model contains it.
+func (e *exifInfo) camera() string {
+	if e.make == "" || strings.HasPrefix(strings.ToLower(e.model), strings.ToLower(e.make)) {
+		return e.model
+	}
+	return strings.TrimSpace(e.make + " " + e.model)
+}
+
+// setOrientation updates orientation value if it exists.
+func (e *exifInfo) setOrientation(o int) {
+	if e.orientationAt > 0 {
+		e.order.PutUint16(e.tiff[e.orientationAt:], uint16(o))
+		e.orientation = o
+	}
+}
+
+// stripGPS zeroes gps ifd entries with their values and leaves empty gps ifd. It returns false if there is no gps data.
+func (e *exifInfo) stripGPS() bool {
+	entries := e.entries(e.gpsAt)
+	if len(entries) == 0 {
+		return false
+	}
+
+	for _, en := range entries {
+		clear(e.tiff[en.value : en.value+en.size])
+		clear(e.tiff[en.at : en.at+12])
+	}
+	e.order.PutUint16(e.tiff[e.gpsAt:], 0)
+
+	return true
+}
+
+// exifTime parses exif date with optional offset like +03:00, date without offset is UTC.
+func exifTime(s, offset string) *time.Time {
+	if s == "" {
+		return nil
+	}
+
+	t, err := time.Parse(exifTimeFormat+"-07:00", s+offset)
+	if err != nil {
+		if t, err = time.Parse(exifTimeFormat, s); err != nil {
+			return nil
+		}
+	}
+
+	return &t
+}
+
+// orient returns image rotated and flipped by exif orientation 2-8 to normal orientation.
+func orient(src image.Image, o int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if o < 2 || o > 8 {
+		return src
+	}
+
+	w, h := sw, sh
+	if o >= 5 {
+		w, h = sh, sw
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range sh {
+		for x := range sw {
+			var dx, dy int
+			switch o {
+			case 2: // mirror horizontal
+				dx, dy = sw-1-x, y
+			case 3: // rotate 180
+				dx, dy = sw-1-x, sh-1-y
+			case 4: // mirror vertical
+				dx, dy = x, sh-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 cw
+				dx, dy = sh-1-y, x
+			case 7: // transverse
+				dx, dy = sh-1-y, sw-1-x
+			case 8: // rotate 90 ccw
+				dx, dy = y, sw-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// dominantColor returns the most frequent color of image like #a0b1c2. Colors are grouped by 4 high bits of channels,
+// pixels are sampled by grid of about colorSamples x colorSamples, transparent pixels are skipped.
+func dominantColor(img image.Image) string {
+	b := img.Bounds()
+	if b.Empty() {
+		return ""
+	}
+
+	var buckets [1 << 12]struct{ r, g, b, n int }
+	best := -1
+	stepX, stepY := max(1, b.Dx()/colorSamples), max(1, b.Dy()/colorSamples)
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+
+			i := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+			bk := &buckets[i]
+			bk.r, bk.g, bk.b, bk.n = bk.r+int(c.R), bk.g+int(c.G), bk.b+int(c.B), bk.n+1
+			if best < 0 || bk.n > buckets[best].n {
+				best = i
+			}
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+
+	bk := buckets[best]
+	return fmt.Sprintf("#%02x%02x%02x", bk.r/bk.n, bk.g/bk.n, bk.b/bk.n)
+}
@@ -0,0 +1,269 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/vfs"
+	vfsdb "github.com/vmkteam/vfs/db"
+)
+
+// testEXIF returns exif segment payload with camera, capture date, orientation and gps latitude.
+func testEXIF(orientation int) []byte {
+	be := binary.BigEndian
+	b := make([]byte, 190)
+	entry := func(at int, tag, typ uint16, count, value uint32) {
+		be.PutUint16(b[at:], tag)
+		be.PutUint16(b[at+2:], typ)
+		be.PutUint32(b[at+4:], count)
+		be.PutUint32(b[at+8:], value)
+	}
+
+	copy(b, "MM\x00\x2a")
+	be.PutUint32(b[4:], 8)
+	be.PutUint16(b[8:], 5)
+	entry(10, exifMake, 2, 6, 74)
+	entry(22, exifModel, 2, 10, 80)
+	entry(34, exifOrientation, 3, 1, uint32(orientation)<<16)
+	entry(46, exifIFDPointer, 4, 1, 90)
+	entry(58, exifGPSPointer, 4, 1, 148)
+	copy(b[74:], "Apple\x00iPhone 15\x00")
+
+	be.PutUint16(b[90:], 2)
+	entry(92, exifDateTimeOriginal, 2, 20, 120)
+	entry(104, exifOffsetOriginal, 2, 7, 140)
+	copy(b[120:], "2024:05:01 10:20:30\x00+03:00\x00")
+
+	be.PutUint16(b[148:], 1)
+	entry(150, 2, 5, 3, 166) // GPSLatitude
+	for i, v := range []uint32{55, 1, 45, 1, 30, 1} {
+		be.PutUint32(b[166+i*4:], v)
+	}
+
+	return append([]byte("Exif\x00\x00"), b...)
+}
+
+// testJPEG returns w x h jpeg with APP1 segments.
+func testJPEG(w, h int, segments ...[]byte) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		panic(err)
+	}
+
+	b := append([]byte{}, buf.Bytes()[:2]...)
+	for _, s := range segments {
+		b = append(b, 0xff, jpegAPP1)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(s)+2))
+		b = append(b, s...)
+	}
+	return append(b, buf.Bytes()[2:]...)
+}
+
+// testPNG returns png image with given chunks after header.
+func testPNG(w, h int, chunks ...[]byte) []byte {
+	b := testImage(w, h)
+	ihdrEnd := len(pngSignature) + 25
+	out := append([]byte{}, b[:ihdrEnd]...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return append(out, b[ihdrEnd:]...)
+}
+
+func TestMedia_Metadata(t *testing.T) {
+	Convey("Test image metadata and exif policies", t, func() {
+		m := newTestMedia(t, storage.NewLocal(t.TempDir()), storage.ServeProxy)
+		xmp := append([]byte("http://ns.adobe.com/xap/1.0/\x00"), "<x:xmpmeta/>"...)
+		photo := testJPEG(40, 20, testEXIF(6), xmp)
+		takenAt := time.Date(2024, 5, 1, 7, 20, 30, 0, time.UTC)
+
+		prepare := func(p Policy, b []byte) (*tempFile, *tempFile, *fileParams) {
+			var err error
+			m.policies, err = newPolicies(m.vfs, map[string]Policy{vfs.DefaultNamespace: p})
+			So(err, ShouldBeNil)
+
+			tf, err := newTempFile(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+			Reset(tf.Remove)
+
+			pf, params, err := m.prepareImage(vfs.NamespacePublic, tf)
+			So(err, ShouldBeNil)
+			if pf != tf {
+				Reset(pf.Remove)
+			}
+			return tf, pf, params
+		}
+		content := func(tf *tempFile) []byte {
+			b, err := io.ReadAll(tf)
+			So(err, ShouldBeNil)
+			So(tf.rewind(), ShouldBeNil)
+			return b
+		}
+
+		Convey("Exif is parsed", func() {
+			ex := parseJPEGExif(photo)
+			So(ex, ShouldNotBeNil)
+			So(ex.orientation, ShouldEqual, 6)
+			So(ex.camera(), ShouldEqual, "Apple iPhone 15")
+			So(ex.takenAt.Equal(takenAt), ShouldBeTrue)
+			So(ex.entries(ex.gpsAt), ShouldHaveLength, 1)
+			So(parseJPEGExif(testImage(10, 10)), ShouldBeNil)
+		})
+
+		Convey("Metadata is extracted without changes by default", func() {
+			tf, pf, params := prepare(Policy{}, photo)
+			So(pf, ShouldEqual, tf)
+			So(params.Width, ShouldEqual, 40)
+			So(params.Height, ShouldEqual, 20)
+			So(params.Orientation, ShouldEqual, 6)
+			So(params.Camera, ShouldEqual, "Apple iPhone 15")
+			So(params.TakenAt.Equal(takenAt), ShouldBeTrue)
+			So(params.Color, ShouldBeEmpty)
+
+			js, err := params.dbParams()
+			So(err, ShouldBeNil)
+			var vp vfsdb.VfsFileParams
+			So(json.Unmarshal([]byte(*js), &vp), ShouldBeNil)
+			So(vp, ShouldResemble, vfsdb.VfsFileParams{Width: 40, Height: 20})
+
+			_, _, params = prepare(Policy{}, testImage(10, 10))
+			So(params, ShouldResemble, &fileParams{Width: 10, Height: 10})
+			_, _, params = prepare(Policy{DominantColor: true}, testImage(10, 10))
+			So(params, ShouldResemble, &fileParams{Width: 10, Height: 10, Color: "#ff0000"})
+			_, _, params = prepare(Policy{DominantColor: true}, photo)
+			So(params.Color, ShouldEqual, "#000000")
+
+			tf, pf, params = prepare(Policy{StripEXIF: true}, []byte("hello"))
+			So(pf, ShouldEqual, tf)
+			So(params, ShouldBeNil)
+		})
+
+		Convey("Location is stripped", func() {
+			tf, pf, params := prepare(Policy{StripLocation: true}, photo)
+			So(pf, ShouldNotEqual, tf)
+			So(pf.hash, ShouldNotEqual, tf.hash)
+			So(params.Orientation, ShouldEqual, 6)
+
+			b := content(pf)
+			So(bytes.Contains(b, []byte("xmpmeta")), ShouldBeFalse)
+			ex := parseJPEGExif(b)
+			So(ex.entries(ex.gpsAt), ShouldBeEmpty)
+			So(ex.camera(), ShouldEqual, "Apple iPhone 15")
+
+			img, err := jpeg.Decode(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+			So(img.Bounds().Dx(), ShouldEqual, 40)
+		})
+
+		Convey("Exif is stripped", func() {
+			_, pf, params := prepare(Policy{StripEXIF: true}, photo)
+			So(params.Camera, ShouldEqual, "Apple iPhone 15")
+
+			b := content(pf)
+			So(parseJPEGExif(b), ShouldBeNil)
+			So(bytes.Contains(b, []byte("xmpmeta")), ShouldBeFalse)
+		})
+
+		Convey("Png exif and xmp are stripped", func() {
+			img := testPNG(10, 10, pngChunk("eXIf", testEXIF(1)[len(exifHeader):]), pngChunk("iTXt", append(slices.Clone(pngXMPKeyword), "\x00\x00\x00\x00<x:xmpmeta/>"...)))
+
+			tf, pf, params := prepare(Policy{}, img)
+			So(pf, ShouldEqual, tf)
+			So(params.Camera, ShouldEqual, "Apple iPhone 15")
+
+			_, pf, params = prepare(Policy{StripLocation: true}, img)
+			So(params.Camera, ShouldEqual, "Apple iPhone 15")
+			b := content(pf)
+			So(bytes.Contains(b, []byte("xmpmeta")), ShouldBeFalse)
+			i := bytes.Index(b, []byte("eXIf"))
+			So(i, ShouldBeGreaterThan, 0)
+			ex := parseExif(b[i+4 : i+4+len(testEXIF(1))-len(exifHeader)])
+			So(ex.entries(ex.gpsAt), ShouldBeEmpty)
+			decoded, err := png.Decode(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+			So(decoded.Bounds().Dx(), ShouldEqual, 10)
+
+			_, pf, _ = prepare(Policy{StripEXIF: true}, img)
+			b = content(pf)
+			So(bytes.Contains(b, []byte("eXIf")), ShouldBeFalse)
+			_, err = png.Decode(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Images with exif of other formats are rejected if it should be stripped", func() {
+			var err error
+			m.policies, err = newPolicies(m.vfs, map[string]Policy{vfs.DefaultNamespace: {StripLocation: true}})
+			So(err, ShouldBeNil)
+
+			tf, err := newTempFile(bytes.NewReader(photo))
+			So(err, ShouldBeNil)
+			defer tf.Remove()
+
+			tf.mimeType = "image/heic"
+			_, _, err = m.prepareImage(vfs.NamespacePublic, tf)
+			So(err, ShouldWrap, ErrMetadataNotStripped)
+
+			big := testJPEG(10, 10, make([]byte, 60000), make([]byte, 60000))
+			_, err = readJPEGHeader(bytes.NewReader(big), 100000)
+			So(err, ShouldWrap, errJPEGHeaderTooLarge)
+			header, err := readJPEGHeader(bytes.NewReader(photo), maxJPEGHeaderSize)
+			So(err, ShouldBeNil)
+			So(jpegSegments(header), ShouldResemble, jpegSegments(photo))
+		})
+
+		Convey("Image is rotated by orientation", func() {
+			_, pf, params := prepare(Policy{AutoOrient: true, StripLocation: true}, photo)
+			So(params.Width, ShouldEqual, 20)
+			So(params.Height, ShouldEqual, 40)
+			So(params.Orientation, ShouldEqual, 0)
+
+			b := content(pf)
+			ex := parseJPEGExif(b)
+			So(ex.orientation, ShouldEqual, 1)
+			So(ex.entries(ex.gpsAt), ShouldBeEmpty)
+
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+			So(cfg.Width, ShouldEqual, 20)
+			So(cfg.Height, ShouldEqual, 40)
+
+			red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+			src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+			src.Set(0, 0, red)
+			src.Set(1, 0, blue)
+
+			img := orient(src, 6)
+			So(img.Bounds().Size(), ShouldResemble, image.Pt(1, 2))
+			So(img.At(0, 0), ShouldResemble, red)
+			So(img.At(0, 1), ShouldResemble, blue)
+
+			img = orient(src, 8)
+			So(img.At(0, 0), ShouldResemble, blue)
+			So(img.At(0, 1), ShouldResemble, red)
+		})
+
+		Convey("Too large image is not decoded", func() {
+			m.images.MaxPixels = 40*20 - 1
+			_, pf, params := prepare(Policy{AutoOrient: true, StripLocation: true, DominantColor: true}, photo)
+			So(params.Width, ShouldEqual, 40)
+			So(params.Orientation, ShouldEqual, 6)
+			So(params.Color, ShouldBeEmpty)
+
+			ex := parseJPEGExif(content(pf))
+			So(ex.orientation, ShouldEqual, 6)
+			So(ex.entries(ex.gpsAt), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"apisrv/pkg/storage"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/vmkteam/vfs"
+	vfsdb "github.com/vmkteam/vfs/db"
+	"github.com/vmkteam/zenrpc/v2"
+)
+
+var filenameRegex = regexp.MustCompile(`^([0-9a-z_-])+\.([0-9a-z])+$`)
+
+func newError(code int) *zenrpc.Error {
+	return zenrpc.NewStringError(code, http.StatusText(code))
+}
+
+func newInternalError(err error) *zenrpc.Error {
+	return zenrpc.NewError(http.StatusInternalServerError, err)
+}
+
+// RenameFile renames file in storage and db, it replaces vfs.Service.SetFilePhysicalName.
+func (m *Media) RenameFile(ctx context.Context, fileID int, name string) (bool, error) {
+	if fileID == 0 || name == "" {
+		return false, vfs.ErrInvalidInput
+	}
+
+	f, err := m.repo.VfsFileByID(ctx, fileID, vfsdb.EnabledOnly())
+	if err != nil {
+		return false, newInternalError(err)
+	} else if f == nil {
+		return false, vfs.ErrNotFound
+	}
+
+	if !filenameRegex.MatchString(name) {
+		return false, newError(http.StatusNotAcceptable)
+	}
+
+	oldPath, newPath := f.Path, path.Join(path.Dir(f.Path), name)
+	if _, err = m.storage.Stat(ctx, newPath); err == nil {
+		return false, newError(http.StatusConflict)
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return false, newInternalError(err)
+	}
+
+	// update path of file and files with the same content and move file in transaction
+	err = m.dbc.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		_, err := tx.ModelContext(ctx, (*vfsdb.VfsFile)(nil)).
+			Set(`? = ?`, pg.Ident(vfsdb.Columns.VfsFile.Path), newPath).
+			Where(`? = ?`, pg.Ident(vfsdb.Columns.VfsFile.Path), oldPath).
+			Update()
+		if err != nil {
+			return err
+		}
+
+		return m.storage.Move(ctx, oldPath, newPath)
+	})
+	if err != nil {
+		return false, newInternalError(err)
+	}
+
+	return true, nil
+}
+
+// DeleteHash deletes hash from db and storage, it replaces vfs.Service.DeleteHash.
+func (m *Media) DeleteHash(ctx context.Context, ns, hash string) (bool, error) {
+	vfsHash, err := m.repo.VfsHashByID(ctx, hash, ns)
+	if err != nil {
+		return false, newInternalError(err)
+	} else if vfsHash == nil {
+		return false, vfs.ErrNotFound
+	}
+
+	key, err := HashKey(ns, vfsHash.Hash, vfsHash.Extension)
+	if err != nil {
+		return false, newInternalError(err)
+	}
+
+	if _, err = m.repo.DeleteVfsHash(ctx, hash, ns); err != nil {
+		return false, newInternalError(err)
+	}
+
+	// not scanned and infected hashes are in quarantine
+	if _, err = m.storage.Stat(ctx, key); errors.Is(err, storage.ErrNotFound) {
+		key = m.quarantineKey(key)
+		_, err = m.storage.Stat(ctx, key)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, vfs.ErrNotFound
+	} else if err != nil {
+		return false, newInternalError(err)
+	}
+
+	if err = m.storage.Delete(ctx, key); err != nil {
+		return false, newInternalError(err)
+	}
+	m.deleteCachedImages(ctx, ns, vfsHash.Hash)
+
+	return true, nil
+}
+
+// deleteCachedImages removes preset images of hash from disk cache, errors are only logged.
+func (m *Media) deleteCachedImages(ctx context.Context, ns, hash string) {
+	if ns == vfs.DefaultNamespace {
+		ns = vfs.NamespacePublic
+	}
+
+	// cache path is <cache>/preset/mode/[ns/]a/bc/hash.ext
+	pattern := filepath.Join(m.images.CachePath, "*", "*", ns, hash[:1], hash[1:3], hash+".*")
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		m.Error(ctx, "find cached images failed", "err", err, "hash", hash)
+		return
+	}
+
+	for _, f := range files {
+		if err = os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			m.Error(ctx, "delete cached image failed", "err", err, "path", f)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"time"
+
+	"apisrv/pkg/storage"
+
+	"github.com/bbrks/go-blurhash"
+	"github.com/go-pg/pg/v10"
+	"github.com/vmkteam/vfs"
+	vfsdb "github.com/vmkteam/vfs/db"
+)
+
+// IndexHash returns image size and blurhash of hash file from storage.
+func (m *Media) IndexHash(ctx context.Context, h vfsdb.VfsHash, calcBlurHash bool) (vfs.HashInfo, error) {
+	info := vfs.HashInfo{Hash: h.Hash, Extension: h.Extension}
+
+	key, err := HashKey(h.Namespace, h.Hash, h.Extension)
+	if err != nil {
+		return info, err
+	}
+
+	rc, obj, err := m.storage.Get(ctx, key)
+	if err != nil {
+		return info, err
+	}
+	defer rc.Close()
+	info.FileSize = obj.Size
+
+	img, _, err := image.Decode(rc)
+	if err != nil {
+		return info, err
+	}
+	info.Width, info.Height = img.Bounds().Dx(), img.Bounds().Dy()
+
+	if calcBlurHash {
+		if info.BlurHash, err = blurhash.Encode(4, 3, img); err != nil {
+			return info, err
+		}
+	}
+
+	return info, nil
+}
+
+// ProcessHashQueue indexes not indexed vfsHashes from storage, it replaces vfs.HashIndexer.ProcessQueue.
+// Hashes without files are left in queue, because file could be not uploaded yet.
+func (m *Media) ProcessHashQueue(ctx context.Context, batchSize uint64, calcBlurHash bool) (int, error) {
+	var rows int
+	err := m.dbc.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		list, err := m.repo.WithTransaction(tx).HashesForUpdate(ctx, batchSize)
+		if err != nil {
+			return fmt.Errorf("hash for update failed: %w", err)
+		} else if len(list) == 0 {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		for i := range list {
+			list[i].IndexedAt = &now
+			info, err := m.IndexHash(ctx, list[i], calcBlurHash)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					list[i].IndexedAt = nil
+				}
+				list[i].Error = err.Error()
+				continue
+			}
+
+			list[i].Width, list[i].Height = info.Width, info.Height
+			list[i].Blurhash = &info.BlurHash
+		}
+
+		_, err = tx.ModelContext(ctx, &list).
+			Column(
+				vfsdb.Columns.VfsHash.Hash,
+				vfsdb.Columns.VfsHash.Namespace,
+				vfsdb.Columns.VfsHash.Height,
+				vfsdb.Columns.VfsHash.Width,
+				vfsdb.Columns.VfsHash.IndexedAt,
+				vfsdb.Columns.VfsHash.Blurhash,
+				vfsdb.Columns.VfsHash.Error,
+			).
+			Update()
+
+		rows = len(list)
+		return err
+	})
+
+	return rows, err
+}
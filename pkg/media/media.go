@@ -0,0 +1,320 @@
+package media
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/storage"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-pg/pg/v10"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmkteam/embedlog"
+	"github.com/vmkteam/vfs"
+	vfsdb "github.com/vmkteam/vfs/db"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultJPEGQuality = 85
+	defaultMaxPixels   = 50_000_000
+)
+
+type Config struct {
+	VFS     vfs.Config
+	Storage storage.Config
+	Images  ImageConfig
+	Access  AccessConfig
+	Check   CheckConfig
+	Tus     TusConfig
+	// Policies are upload policies by namespace, root hashes are in "default" namespace.
+	Policies map[string]Policy
+	Scan     ScanConfig
+	// Scanner overrides scanner from Scan config.
+	Scanner Scanner
+}
+
+type ImageConfig struct {
+	// CachePath is a directory for generated preset images.
+	CachePath string
+	// Workers is a max count of concurrent image transforms, default is count of CPUs.
+	Workers int
+	// Quality is a jpeg quality of preset images.
+	Quality int
+	// Presets adds or overrides image presets, e.g. small = "160x120 crop".
+	Presets map[string]string
+	// MaxPixels is a max width*height of decoded images, images are checked by header before decoding.
+	MaxPixels int
+}
+
+// Media handles vfs uploads, serving and file changes on top of storage.
+// vfs package works with local disk only, so its handlers are replaced by Media ones.
+type Media struct {
+	embedlog.Logger
+	vfs     vfs.VFS
+	cfg     vfs.Config
+	storage storage.Storage
+	repo    vfsdb.VfsRepo
+	dbc     *pg.DB
+	dbOpts  []db.RepoOption
+
+	serve      string
+	presignTTL time.Duration
+
+	images  ImageConfig
+	presets map[string]Preset
+	workers chan struct{}
+	flight  singleflight.Group
+
+	access *access
+	check  CheckConfig
+	tus    *tusStore
+
+	policies map[string]Policy
+	quota    db.VfsQuotaRepository
+
+	scanner   Scanner
+	scanCfg   ScanConfig
+	scans     db.VfsScanRepository
+	statScans *prometheus.CounterVec
+
+	dedup db.VfsDedupRepository
+}
+
+func New(vf vfs.VFS, st storage.Storage, cfg Config, dbc *pg.DB, logger embedlog.Logger, dbOpts ...db.RepoOption) (*Media, error) {
+	if cfg.VFS.UploadFormName == "" {
+		cfg.VFS.UploadFormName = "file"
+	}
+	if cfg.Images.CachePath == "" {
+		cfg.Images.CachePath = filepath.Join(os.TempDir(), "apisrv-media")
+	}
+	if cfg.Images.Workers == 0 {
+		cfg.Images.Workers = runtime.NumCPU()
+	}
+	if cfg.Images.Quality == 0 {
+		cfg.Images.Quality = defaultJPEGQuality
+	}
+	if cfg.Images.MaxPixels == 0 {
+		cfg.Images.MaxPixels = defaultMaxPixels
+	}
+	if cfg.Scan.BatchSize == 0 {
+		cfg.Scan.BatchSize = defaultScanBatchSize
+	}
+
+	if len(cfg.Access.PrivateNamespaces) > 0 && cfg.Access.Secret == "" {
+		return nil, ErrNoSecret
+	}
+
+	presets, err := newPresets(cfg.Images.Presets)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Check, err = cfg.Check.withDefaults(); err != nil {
+		return nil, err
+	}
+
+	policies, err := newPolicies(vf, cfg.Policies)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Scanner == nil {
+		if cfg.Scanner, err = NewScanner(cfg.Scan); err != nil {
+			return nil, err
+		}
+	}
+
+	// folders, quotas, scan statuses and duplicates are checked only with db
+	var (
+		accessRepo db.VfsAccessRepository
+		quotaRepo  db.VfsQuotaRepository
+		scanRepo   db.VfsScanRepository
+		dedupRepo  db.VfsDedupRepository
+	)
+	if dbc != nil {
+		vr := db.NewVfsRepo(dbc, dbOpts...)
+		accessRepo, quotaRepo, scanRepo, dedupRepo = vr, vr, vr, vr
+	}
+
+	return &Media{
+		Logger:     logger,
+		vfs:        vf,
+		cfg:        cfg.VFS,
+		storage:    st,
+		repo:       vfsdb.NewVfsRepo(dbc),
+		dbc:        dbc,
+		dbOpts:     dbOpts,
+		serve:      cfg.Storage.Serve,
+		presignTTL: cfg.Storage.PresignTTL(),
+		images:     cfg.Images,
+		presets:    presets,
+		workers:    make(chan struct{}, cfg.Images.Workers),
+		access:     newAccess(cfg.Access, accessRepo),
+		check:      cfg.Check,
+		tus:        newTusStore(cfg.Tus, dbc),
+		policies:   policies,
+		quota:      quotaRepo,
+		scanner:    cfg.Scanner,
+		scanCfg:    cfg.Scan,
+		scans:      scanRepo,
+		statScans:  newScanStat(),
+		dedup:      dedupRepo,
+	}, nil
+}
+
+// Storage returns media storage.
+func (m *Media) Storage() storage.Storage {
+	return m.storage
+}
+
+// HashKey returns storage key of hash file. Default namespace is stored in root like in vfs.
+func HashKey(ns, hash, ext string) (string, error) {
+	if ns == vfs.DefaultNamespace {
+		ns = vfs.NamespacePublic
+	}
+	return storage.Key(ns, vfs.NewFileHash(hash, ext).File())
+}
+
+// mediaTx is a transaction of media db changes with repositories bound to it. Without db repositories are used
+// as is and locks are not taken, e.g. by unit tests with in-memory repositories.
+type mediaTx struct {
+	tx    *pg.Tx
+	repo  vfsdb.VfsRepo
+	quota db.VfsQuotaRepository
+	scans db.VfsScanRepository
+	dedup db.VfsDedupRepository
+}
+
+// noTx returns media repositories without transaction.
+func (m *Media) noTx() mediaTx {
+	return mediaTx{repo: m.repo, quota: m.quota, scans: m.scans, dedup: m.dedup}
+}
+
+// runInTx runs fn in transaction.
+func (m *Media) runInTx(ctx context.Context, fn func(t mediaTx) error) error {
+	if m.dbc == nil {
+		return fn(m.noTx())
+	}
+
+	return m.dbc.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		vr := db.NewVfsRepo(tx, m.dbOpts...)
+		return fn(mediaTx{tx: tx, repo: m.repo.WithTransaction(tx), quota: vr, scans: vr, dedup: vr})
+	})
+}
+
+// lock takes advisory locks by names until the end of transaction. Names are sorted, so concurrent
+// transactions with the same names don't deadlock.
+func (t mediaTx) lock(ctx context.Context, names ...string) error {
+	if t.tx == nil {
+		return nil
+	}
+
+	slices.Sort(names)
+	for _, name := range slices.Compact(names) {
+		if _, err := t.tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext(?))`, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tempFile is an upload saved to local disk for hashing and type detection before it goes to storage.
+type tempFile struct {
+	*os.File
+	size     int64
+	hash     string
+	mimeType string
+}
+
+// newTempFile copies r to temp file and calculates md5 hash and mime type.
+func newTempFile(r io.Reader) (*tempFile, error) {
+	f, err := os.CreateTemp("", "vfs")
+	if err != nil {
+		return nil, err
+	}
+
+	tf := &tempFile{File: f}
+	h := md5.New()
+	if tf.size, err = io.Copy(io.MultiWriter(h, f), r); err != nil {
+		tf.Remove()
+		return nil, err
+	}
+	tf.hash = hex.EncodeToString(h.Sum(nil))
+
+	if err = tf.detectMimeType(); err != nil {
+		tf.Remove()
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// openTempFile opens complete file and calculates md5 hash and mime type like newTempFile.
+func openTempFile(name string) (*tempFile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tf := &tempFile{File: f}
+	h := md5.New()
+	if tf.size, err = io.Copy(h, f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	tf.hash = hex.EncodeToString(h.Sum(nil))
+
+	if err = tf.detectMimeType(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// detectMimeType detects mime type by file content, file is rewound.
+func (tf *tempFile) detectMimeType() error {
+	if err := tf.rewind(); err != nil {
+		return err
+	}
+
+	mt, err := mimetype.DetectReader(tf)
+	if err != nil {
+		return err
+	}
+	tf.mimeType = mt.String()
+
+	return tf.rewind()
+}
+
+func (tf *tempFile) rewind() error {
+	_, err := tf.Seek(0, io.SeekStart)
+	return err
+}
+
+// imageParams returns image size or nil for non-image files.
+func (tf *tempFile) imageParams() *vfsdb.VfsFileParams {
+	defer func() { _ = tf.rewind() }()
+
+	im, _, err := image.DecodeConfig(tf)
+	if err != nil {
+		return nil
+	}
+
+	return &vfsdb.VfsFileParams{Width: im.Width, Height: im.Height}
+}
+
+// Remove closes and removes temp file.
+func (tf *tempFile) Remove() {
+	_ = tf.Close()
+	_ = os.Remove(tf.Name())
+}
@@ -0,0 +1,70 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/embedlog"
+	"github.com/vmkteam/vfs"
+)
+
+func newTestMedia(t *testing.T, st storage.Storage, serve string) *Media {
+	cfg := vfs.Config{
+		WebPath: "/media/", MaxFileSize: 1024, SkipFolderVerify: true,
+		Namespaces: []string{"docs"}, Extensions: []string{"txt", "png"}, MimeTypes: []string{"image/png"},
+	}
+	vf, err := vfs.New(cfg, embedlog.NewDevLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(vf, st, Config{
+		VFS:     cfg,
+		Storage: storage.Config{Serve: serve, PresignExpires: time.Minute},
+		Images:  ImageConfig{CachePath: t.TempDir(), Presets: map[string]string{"square": "64 crop"}},
+	}, nil, embedlog.NewDevLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestTempFile(t *testing.T) {
+	Convey("Test temp file hash and type", t, func() {
+		tf, err := newTempFile(strings.NewReader("hello"))
+		So(err, ShouldBeNil)
+		defer tf.Remove()
+
+		So(tf.size, ShouldEqual, 5)
+		So(tf.hash, ShouldEqual, "5d41402abc4b2a76b9719d911017c592")
+		So(tf.mimeType, ShouldStartWith, "text/plain")
+		So(tf.imageParams(), ShouldBeNil)
+
+		b, err := io.ReadAll(tf)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "hello")
+	})
+}
+
+func testImage(w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := range w {
+		img.Set(x, h/2, color.RGBA{R: 255, A: 255})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,267 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"apisrv/pkg/db"
+
+	"github.com/vmkteam/vfs"
+)
+
+var (
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	ErrImageTooLarge = errors.New("image dimensions exceed limit")
+	ErrFileTooLarge  = errors.New("file size exceed limit")
+	ErrUnknownPolicy = errors.New("policy for unknown namespace")
+
+	ErrMetadataNotStripped = errors.New("image metadata can't be stripped")
+)
+
+// Policy is an upload policy of namespace, zero values mean global vfs limits.
+// Global extensions and mime types are checked for hashes only, like in vfs.
+type Policy struct {
+	// MaxFileSize is a max size of one file in bytes.
+	MaxFileSize int64
+	// Extensions are allowed file extensions.
+	Extensions []string
+	// MimeTypes are allowed mime types, "*" allows all.
+	MimeTypes []string
+	// MaxWidth and MaxHeight limit image dimensions.
+	MaxWidth  int
+	MaxHeight int
+	// Quota is a max total size of namespace hashes in bytes.
+	Quota int64
+	// AutoOrient rotates jpeg images by exif orientation, rotated images are re-encoded.
+	AutoOrient bool
+	// StripLocation removes gps data and xmp from jpeg and png images, other images with exif are rejected.
+	StripLocation bool
+	// StripEXIF removes exif and xmp from jpeg and png images, metadata is extracted to file params before it.
+	// Other images with exif are rejected.
+	StripEXIF bool
+	// DominantColor saves dominant color of images to file params, images are fully decoded for it.
+	DominantColor bool
+}
+
+// Usage is a storage usage of namespace or folder, zero quota means unlimited.
+type Usage struct {
+	Files int
+	Size  int64
+	Quota int64
+}
+
+// newPolicies validates namespaces of policies, public namespace policy is a default one.
+func newPolicies(vf vfs.VFS, policies map[string]Policy) (map[string]Policy, error) {
+	r := make(map[string]Policy, len(policies))
+	for ns, p := range policies {
+		if ns != vfs.DefaultNamespace && !vf.IsValidNamespace(ns) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPolicy, ns)
+		}
+		r[ns] = p
+	}
+
+	return r, nil
+}
+
+// policy returns upload policy of namespace.
+func (m *Media) policy(ns string) Policy {
+	if ns == vfs.NamespacePublic {
+		ns = vfs.DefaultNamespace
+	}
+	return m.policies[ns]
+}
+
+// maxFileSize returns max upload size of namespace.
+func (m *Media) maxFileSize(ns string) int64 {
+	if p := m.policy(ns); p.MaxFileSize > 0 {
+		return p.MaxFileSize
+	}
+	return m.cfg.MaxFileSize
+}
+
+// isValidExtension checks extension by namespace policy or by vfs config.
+func (m *Media) isValidExtension(ns, ext string) bool {
+	if p := m.policy(ns); len(p.Extensions) > 0 {
+		return slices.Contains(p.Extensions, ext)
+	}
+	return m.vfs.IsValidExtension(ext)
+}
+
+// isValidMimeType checks mime type by namespace policy or by vfs config.
+func (m *Media) isValidMimeType(ns, mimeType string) bool {
+	if p := m.policy(ns); len(p.MimeTypes) > 0 {
+		return slices.Contains(p.MimeTypes, "*") || slices.Contains(p.MimeTypes, mimeType)
+	}
+	return m.vfs.IsValidMimeType(mimeType)
+}
+
+// validateUpload checks file by namespace policy, global lists are checked only if global is true.
+func (m *Media) validateUpload(ns, ext string, tf *tempFile, global bool) error {
+	p := m.policy(ns)
+	if p.MaxFileSize > 0 && tf.size > p.MaxFileSize {
+		return newUploadError(http.StatusRequestEntityTooLarge, fmt.Errorf("%w: %v bytes", ErrFileTooLarge, p.MaxFileSize))
+	}
+	if (global || len(p.Extensions) > 0) && !m.isValidExtension(ns, ext) {
+		return newUploadError(http.StatusBadRequest, vfs.ErrInvalidExtension)
+	}
+	if (global || len(p.MimeTypes) > 0) && !m.isValidMimeType(ns, tf.mimeType) {
+		return newUploadError(http.StatusBadRequest, vfs.ErrInvalidMimeType)
+	}
+
+	if p.MaxWidth > 0 || p.MaxHeight > 0 {
+		if im := tf.imageParams(); im != nil && ((p.MaxWidth > 0 && im.Width > p.MaxWidth) || (p.MaxHeight > 0 && im.Height > p.MaxHeight)) {
+			return newUploadError(http.StatusBadRequest, fmt.Errorf("%w: %dx%d, max %dx%d", ErrImageTooLarge, im.Width, im.Height, p.MaxWidth, p.MaxHeight))
+		}
+	}
+
+	return nil
+}
+
+// checkNamespaceQuota checks that new hash fits namespace quota. Existing hashes are not counted twice.
+// Namespace is locked by t until the end of transaction, so concurrent uploads are checked after hash is saved.
+func (m *Media) checkNamespaceQuota(ctx context.Context, t mediaTx, ns, hash string, size int64) error {
+	p, hashNS := m.policy(ns), hashNamespace(ns)
+	if p.Quota == 0 || t.quota == nil {
+		return nil
+	}
+	if err := t.lock(ctx, "vfsNamespace:"+hashNS); err != nil {
+		return err
+	}
+
+	list, err := t.quota.VfsNamespaceUsage(ctx)
+	if err != nil {
+		return err
+	}
+
+	var used int64
+	if i := slices.IndexFunc(list, func(u db.VfsUsage) bool { return u.Namespace == hashNS }); i >= 0 {
+		used = list[i].Size
+	}
+	if used+size <= p.Quota {
+		return nil
+	}
+
+	if h, err := t.repo.VfsHashByID(ctx, hash, hashNS); err != nil || h != nil {
+		return err
+	}
+
+	return newUploadError(http.StatusInsufficientStorage,
+		fmt.Errorf("%w: namespace %s uses %d of %d bytes", ErrQuotaExceeded, hashNS, used, p.Quota))
+}
+
+// checkFolderQuota checks quotas of folder and its parents. Folders with quota are locked by t until the end
+// of transaction, so concurrent uploads are checked after file is added.
+func (m *Media) checkFolderQuota(ctx context.Context, t mediaTx, folderID int, size int64) error {
+	if t.quota == nil {
+		return nil
+	}
+
+	folders, err := t.quota.VfsFolderQuotas(ctx, folderID)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(folders))
+	for _, f := range folders {
+		names = append(names, "vfsFolder:"+strconv.Itoa(f.ID))
+	}
+	if err = t.lock(ctx, names...); err != nil {
+		return err
+	}
+
+	for _, f := range folders {
+		u, err := t.quota.VfsFolderUsage(ctx, f.ID)
+		if err != nil {
+			return err
+		} else if u.Size+size > *f.Quota {
+			return newUploadError(http.StatusInsufficientStorage,
+				fmt.Errorf("%w: folder %d uses %d of %d bytes", ErrQuotaExceeded, f.ID, u.Size, *f.Quota))
+		}
+	}
+
+	return nil
+}
+
+// NamespaceUsage returns size of hashes in namespace and its quota.
+func (m *Media) NamespaceUsage(ctx context.Context, ns string) (Usage, error) {
+	list, err := m.NamespacesUsage(ctx)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	return list[hashNamespace(ns)], nil
+}
+
+// NamespacesUsage returns usage of all namespaces with hashes or policies.
+func (m *Media) NamespacesUsage(ctx context.Context) (map[string]Usage, error) {
+	if m.quota == nil {
+		return nil, newError(http.StatusNotImplemented)
+	}
+
+	list, err := m.quota.VfsNamespaceUsage(ctx)
+	if err != nil {
+		return nil, newInternalError(err)
+	}
+
+	r := make(map[string]Usage, len(list))
+	for ns, p := range m.policies {
+		r[ns] = Usage{Quota: p.Quota}
+	}
+	for _, u := range list {
+		r[u.Namespace] = Usage{Files: u.Files, Size: u.Size, Quota: m.policy(u.Namespace).Quota}
+	}
+
+	return r, nil
+}
+
+// FolderUsage returns size of folder with subfolders and the nearest quota of folder or its parents.
+func (m *Media) FolderUsage(ctx context.Context, folderID int) (Usage, error) {
+	if m.quota == nil {
+		return Usage{}, newError(http.StatusNotImplemented)
+	}
+
+	u, err := m.quota.VfsFolderUsage(ctx, folderID)
+	if err != nil {
+		return Usage{}, newInternalError(err)
+	}
+	r := Usage{Files: u.Files, Size: u.Size}
+
+	folders, err := m.quota.VfsFolderQuotas(ctx, folderID)
+	if err != nil {
+		return r, newInternalError(err)
+	} else if len(folders) > 0 {
+		r.Quota = *folders[0].Quota
+	}
+
+	return r, nil
+}
+
+// SetFolderQuota sets quota of folder in bytes, nil removes quota.
+func (m *Media) SetFolderQuota(ctx context.Context, folderID int, quota *int64) (bool, error) {
+	if m.quota == nil {
+		return false, newError(http.StatusNotImplemented)
+	} else if quota != nil && *quota < 0 {
+		return false, vfs.ErrInvalidInput
+	}
+
+	ok, err := m.quota.SetVfsFolderQuota(ctx, folderID, quota)
+	if err != nil {
+		return false, newInternalError(err)
+	} else if !ok {
+		return false, vfs.ErrNotFound
+	}
+
+	return true, nil
+}
+
+// hashNamespace returns namespace of vfsHashes, public namespace is stored as default.
+func hashNamespace(ns string) string {
+	if ns == vfs.NamespacePublic {
+		return vfs.DefaultNamespace
+	}
+	return ns
+}
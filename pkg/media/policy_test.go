@@ -0,0 +1,103 @@
+package media
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/db/test"
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/vfs"
+)
+
+func TestMedia_Policy(t *testing.T) {
+	Convey("Test upload policies and quotas", t, func() {
+		ctx := t.Context()
+		m := newTestMedia(t, storage.NewLocal(t.TempDir()), storage.ServeProxy)
+		repo := test.NewVfsQuotaRepo(
+			[]db.VfsFolder{
+				{ID: 1, Title: "root", Quota: test.Ptr[int64](20), StatusID: db.StatusEnabled},
+				{ID: 2, ParentFolderID: test.Ptr(1), Title: "sub", StatusID: db.StatusEnabled},
+			},
+			db.VfsFile{ID: 1, FolderID: 1, FileSize: test.Ptr(5), StatusID: db.StatusEnabled},
+			db.VfsFile{ID: 2, FolderID: 2, FileSize: test.Ptr(10), StatusID: db.StatusEnabled},
+			db.VfsFile{ID: 3, FolderID: 2, FileSize: test.Ptr(100), StatusID: db.StatusDeleted},
+		)
+		repo.Namespaces = []db.VfsUsage{{Namespace: vfs.DefaultNamespace, Files: 2, Size: 90}}
+		m.quota = repo
+
+		var err error
+		m.policies, err = newPolicies(m.vfs, map[string]Policy{
+			vfs.DefaultNamespace: {MaxWidth: 100, MaxHeight: 100, Quota: 100},
+			"docs":               {MaxFileSize: 4, Extensions: []string{"md"}, MimeTypes: []string{"*"}},
+		})
+		So(err, ShouldBeNil)
+
+		tempFile := func(b []byte) *tempFile {
+			tf, err := newTempFile(bytes.NewReader(b))
+			So(err, ShouldBeNil)
+			Reset(tf.Remove)
+			return tf
+		}
+
+		Convey("Policy namespaces are validated", func() {
+			_, err := newPolicies(m.vfs, map[string]Policy{"files": {}})
+			So(err, ShouldWrap, ErrUnknownPolicy)
+		})
+
+		Convey("Files are validated by namespace policy", func() {
+			So(m.maxFileSize(vfs.NamespacePublic), ShouldEqual, 1024)
+			So(m.maxFileSize("docs"), ShouldEqual, 4)
+			So(m.isValidExtension("docs", "md"), ShouldBeTrue)
+			So(m.isValidExtension("docs", "txt"), ShouldBeFalse)
+			So(m.isValidExtension(vfs.NamespacePublic, "txt"), ShouldBeTrue)
+
+			text := tempFile([]byte("hello"))
+			So(m.validateUpload("docs", "md", text, false), ShouldWrap, ErrFileTooLarge)
+			So(m.validateUpload(vfs.NamespacePublic, "txt", text, false), ShouldBeNil)
+			So(m.validateUpload(vfs.NamespacePublic, "txt", text, true), ShouldWrap, vfs.ErrInvalidMimeType)
+			So(m.validateUpload("docs", "md", tempFile([]byte("doc")), true), ShouldBeNil)
+
+			So(m.validateUpload(vfs.NamespacePublic, "png", tempFile(testImage(100, 50)), true), ShouldBeNil)
+			err := m.validateUpload(vfs.NamespacePublic, "png", tempFile(testImage(120, 50)), true)
+			So(err, ShouldWrap, ErrImageTooLarge)
+			So(errorResponse(err).Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Folder quota is checked with parents", func() {
+			So(m.checkFolderQuota(ctx, m.noTx(), 2, 5), ShouldBeNil)
+			err := m.checkFolderQuota(ctx, m.noTx(), 2, 6)
+			So(err, ShouldWrap, ErrQuotaExceeded)
+			ur := errorResponse(err)
+			So(ur.Code, ShouldEqual, http.StatusInsufficientStorage)
+			So(ur.Error, ShouldEqual, "storage quota exceeded: folder 1 uses 15 of 20 bytes")
+
+			u, err := m.FolderUsage(ctx, 2)
+			So(err, ShouldBeNil)
+			So(u, ShouldResemble, Usage{Files: 1, Size: 10, Quota: 20})
+
+			ok, err := m.SetFolderQuota(ctx, 1, nil)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(m.checkFolderQuota(ctx, m.noTx(), 2, 1000), ShouldBeNil)
+
+			_, err = m.SetFolderQuota(ctx, 3, nil)
+			So(err, ShouldEqual, vfs.ErrNotFound)
+		})
+
+		Convey("Namespace usage is reported", func() {
+			So(m.checkNamespaceQuota(ctx, m.noTx(), vfs.NamespacePublic, "70c565ef460af43688b7ee6251028db9", 10), ShouldBeNil)
+			So(m.checkNamespaceQuota(ctx, m.noTx(), "docs", "70c565ef460af43688b7ee6251028db9", 1000), ShouldBeNil)
+
+			list, err := m.NamespacesUsage(ctx)
+			So(err, ShouldBeNil)
+			So(list, ShouldResemble, map[string]Usage{
+				vfs.DefaultNamespace: {Files: 2, Size: 90, Quota: 100},
+				"docs":               {},
+			})
+		})
+	})
+}
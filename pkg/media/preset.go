@@ -0,0 +1,88 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+const (
+	ModeFit  = "fit"
+	ModeCrop = "crop"
+)
+
+var (
+	ErrUnknownPreset = errors.New("unknown preset")
+	ErrUnknownMode   = errors.New("unknown resize mode")
+)
+
+// Preset is an image size for media urls like /media/256/a/bc/hash.jpg.
+// Zero height means any height for fit mode.
+type Preset struct {
+	Width  int
+	Height int
+	Mode   string
+}
+
+// defaultPresets are presets from vt media urls.
+var defaultPresets = map[string]Preset{
+	"small":  {Width: 160, Height: 120, Mode: ModeCrop},
+	"medium": {Width: 320, Height: 240, Mode: ModeCrop},
+	"big":    {Width: 800, Height: 600, Mode: ModeFit},
+	"normal": {Width: 1280, Height: 1024, Mode: ModeFit},
+	"128":    {Width: 128, Mode: ModeFit},
+	"256":    {Width: 256, Mode: ModeFit},
+	"512":    {Width: 512, Mode: ModeFit},
+	"768":    {Width: 768, Mode: ModeFit},
+	"1024":   {Width: 1024, Mode: ModeFit},
+	"2048":   {Width: 2048, Mode: ModeFit},
+}
+
+// ParsePreset parses preset like "160x120 crop" or "256 fit". Mode is fit by default.
+func ParsePreset(s string) (Preset, error) {
+	size, mode, _ := strings.Cut(strings.TrimSpace(s), " ")
+	p := Preset{Mode: strings.TrimSpace(mode)}
+	if p.Mode == "" {
+		p.Mode = ModeFit
+	} else if p.Mode != ModeFit && p.Mode != ModeCrop {
+		return p, fmt.Errorf("%w: %s", ErrUnknownMode, p.Mode)
+	}
+
+	w, h, hasHeight := strings.Cut(size, "x")
+	var err error
+	if p.Width, err = strconv.Atoi(w); err != nil || p.Width <= 0 {
+		return p, fmt.Errorf("invalid preset width: %q", s)
+	}
+	if hasHeight {
+		if p.Height, err = strconv.Atoi(h); err != nil || p.Height <= 0 {
+			return p, fmt.Errorf("invalid preset height: %q", s)
+		}
+	}
+
+	return p.withMode(p.Mode), nil
+}
+
+// withMode returns preset with mode, crop of width only preset is square.
+func (p Preset) withMode(mode string) Preset {
+	p.Mode = mode
+	if p.Mode == ModeCrop && p.Height == 0 {
+		p.Height = p.Width
+	}
+	return p
+}
+
+// newPresets returns default presets with overrides from config.
+func newPresets(overrides map[string]string) (map[string]Preset, error) {
+	r := maps.Clone(defaultPresets)
+	for name, s := range overrides {
+		p, err := ParsePreset(s)
+		if err != nil {
+			return nil, fmt.Errorf("preset %s: %w", name, err)
+		}
+		r[name] = p
+	}
+
+	return r, nil
+}
@@ -0,0 +1,126 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/vfs"
+)
+
+func TestMedia_Presets(t *testing.T) {
+	Convey("Test preset images", t, func() {
+		ctx, local := t.Context(), storage.NewLocal(t.TempDir())
+		m := newTestMedia(t, local, storage.ServeProxy)
+		hash := "70c565ef460af43688b7ee6251028db9"
+		b := testImage(600, 400)
+		So(local.Put(ctx, "7/0c/"+hash+".png", bytes.NewReader(b), int64(len(b)), ""), ShouldBeNil)
+
+		get := func(target string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			m.ServeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			return w
+		}
+		size := func(w *httptest.ResponseRecorder) (int, int) {
+			cfg, _, err := image.DecodeConfig(w.Body)
+			So(err, ShouldBeNil)
+			return cfg.Width, cfg.Height
+		}
+
+		Convey("Fit preset keeps aspect ratio", func() {
+			w := get("/media/256/7/0c/" + hash + ".png")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "image/png")
+			width, height := size(w)
+			So(width, ShouldEqual, 256)
+			So(height, ShouldEqual, 170)
+
+			// images are not upscaled
+			width, height = size(get("/media/2048/7/0c/" + hash + ".png"))
+			So(width, ShouldEqual, 600)
+			So(height, ShouldEqual, 400)
+		})
+
+		Convey("Crop preset and mode override", func() {
+			width, height := size(get("/media/square/7/0c/" + hash + ".png"))
+			So(width, ShouldEqual, 64)
+			So(height, ShouldEqual, 64)
+
+			width, height = size(get("/media/small/7/0c/" + hash + ".png?mode=fit"))
+			So(width, ShouldEqual, 160)
+			So(height, ShouldEqual, 106)
+
+			So(get("/media/small/7/0c/"+hash+".png?mode=zoom").Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Concurrent requests use one transform", func() {
+			var wg sync.WaitGroup
+			codes := make([]int, 10)
+			for i := range codes {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					codes[i] = get("/media/512/7/0c/" + hash + ".jpg?mode=crop").Code
+				}()
+			}
+			wg.Wait()
+			So(codes, ShouldNotContain, http.StatusInternalServerError)
+			So(codes[0], ShouldEqual, http.StatusNotFound)
+
+			codes[0] = get("/media/512/7/0c/" + hash + ".png?mode=crop").Code
+			So(codes[0], ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Images over pixel limit are not decoded", func() {
+			m.images.MaxPixels = 600*400 - 1
+			So(get("/media/256/7/0c/"+hash+".png").Code, ShouldEqual, http.StatusNotFound)
+
+			_, err := decodeImage(bytes.NewReader(b), 600*400)
+			So(err, ShouldBeNil)
+			_, err = decodeImage(bytes.NewReader(b), 600*400-1)
+			So(err, ShouldWrap, ErrImageTooLarge)
+		})
+
+		Convey("Cached images are deleted with hash", func() {
+			So(get("/media/256/7/0c/"+hash+".png").Code, ShouldEqual, http.StatusOK)
+			So(get("/media/small/7/0c/"+hash+".png?mode=fit").Code, ShouldEqual, http.StatusOK)
+			files, err := filepath.Glob(filepath.Join(m.images.CachePath, "*", "*", "7", "0c", hash+".*"))
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 2)
+
+			m.deleteCachedImages(ctx, vfs.DefaultNamespace, hash)
+			files, err = filepath.Glob(filepath.Join(m.images.CachePath, "*", "*", "7", "0c", hash+".*"))
+			So(err, ShouldBeNil)
+			So(files, ShouldBeEmpty)
+		})
+
+		Convey("Unknown presets are rejected", func() {
+			So(get("/media/100/7/0c/"+hash+".png").Code, ShouldEqual, http.StatusNotFound)
+			So(get("/media/ns/256/7/0c/"+hash+".png").Code, ShouldEqual, http.StatusNotFound)
+			So(get("/media/256/7/0c/"+strings.Repeat("f", 32)+".png").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Presets are parsed", func() {
+			p, err := ParsePreset("160x120 crop")
+			So(err, ShouldBeNil)
+			So(p, ShouldResemble, Preset{Width: 160, Height: 120, Mode: ModeCrop})
+
+			p, err = ParsePreset("64 crop")
+			So(err, ShouldBeNil)
+			So(p, ShouldResemble, Preset{Width: 64, Height: 64, Mode: ModeCrop})
+
+			_, err = ParsePreset("64 zoom")
+			So(err, ShouldWrap, ErrUnknownMode)
+			_, err = ParsePreset("x100")
+			So(err, ShouldBeError)
+		})
+	})
+}
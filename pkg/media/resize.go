@@ -0,0 +1,72 @@
+package media
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Resize returns image resized by preset. Images are never upscaled in fit mode.
+// Crop mode scales image to cover preset size and cuts center part.
+func Resize(src image.Image, p Preset) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw == 0 || sh == 0 {
+		return src
+	}
+
+	if p.Mode == ModeCrop {
+		// cut center part with preset aspect ratio
+		cw, ch := sw, sw*p.Height/p.Width
+		if ch > sh {
+			cw, ch = sh*p.Width/p.Height, sh
+		}
+		x, y := b.Min.X+(sw-cw)/2, b.Min.Y+(sh-ch)/2
+		return scale(src, image.Rect(x, y, x+cw, y+ch), min(p.Width, cw), min(p.Height, ch))
+	}
+
+	w, h := sw, sh
+	if w > p.Width {
+		w, h = p.Width, max(1, sh*p.Width/sw)
+	}
+	if p.Height > 0 && h > p.Height {
+		w, h = max(1, sw*p.Height/sh), p.Height
+	}
+
+	return scale(src, b, w, h)
+}
+
+// scale resizes r part of src to w x h by area averaging, it gives good quality for downscaling.
+func scale(src image.Image, r image.Rectangle, w, h int) image.Image {
+	rgba := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, r.Min, draw.Src)
+	if w == r.Dx() && h == r.Dy() {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := r.Dx(), r.Dy()
+	for y := range h {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := range w {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+
+			var cr, cg, cb, ca, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					cr += uint64(rgba.Pix[i])
+					cg += uint64(rgba.Pix[i+1])
+					cb += uint64(rgba.Pix[i+2])
+					ca += uint64(rgba.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(cr/n), uint8(cg/n), uint8(cb/n), uint8(ca/n)
+		}
+	}
+
+	return dst
+}
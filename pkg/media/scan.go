@@ -0,0 +1,317 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultScanTimeout   = time.Minute
+	defaultScanBatchSize = 100
+	clamChunkSize        = 1 << 16
+)
+
+var (
+	ErrInfected   = errors.New("file is infected")
+	ErrScanFailed = errors.New("scan failed")
+)
+
+type ScanConfig struct {
+	// ClamAV is a clamd address, e.g. tcp://127.0.0.1:3310 or unix:///run/clamav/clamd.ctl. Scanning is disabled if it is empty.
+	ClamAV string
+	// Timeout is a max duration of one file scan.
+	Timeout time.Duration
+	// BatchSize is a count of pending files and hashes rescanned by one run.
+	BatchSize int
+}
+
+// ScanResult is a verdict of scanner, Signature is a name of found threat.
+type ScanResult struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner checks content of uploaded files before they become visible.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (ScanResult, error)
+}
+
+// NewScanner returns Scanner by config or nil if scanning is disabled.
+func NewScanner(cfg ScanConfig) (Scanner, error) {
+	if cfg.ClamAV == "" {
+		return nil, nil
+	}
+	return NewClamAV(cfg.ClamAV, cfg.Timeout)
+}
+
+// ClamAV scans files by clamd INSTREAM command.
+type ClamAV struct {
+	network, address string
+	timeout          time.Duration
+}
+
+// NewClamAV returns ClamAV scanner for tcp://host:port, unix:///path or host:port address.
+func NewClamAV(address string, timeout time.Duration) (*ClamAV, error) {
+	if timeout == 0 {
+		timeout = defaultScanTimeout
+	}
+
+	c := &ClamAV{network: "tcp", address: address, timeout: timeout}
+	if network, addr, ok := strings.Cut(address, "://"); ok {
+		if network != "tcp" && network != "unix" {
+			return nil, fmt.Errorf("%w: unknown clamd network %s", ErrScanFailed, network)
+		}
+		c.network, c.address = network, addr
+	}
+
+	return c, nil
+}
+
+// Scan streams r to clamd by chunks and parses reply like "stream: OK" or "stream: Eicar-Signature FOUND".
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("%w: %w", ErrScanFailed, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// clamd closes connection when stream limit is exceeded, so reply is read even after write error
+	werr := c.stream(conn, r)
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if reply == "" {
+		return ScanResult{}, fmt.Errorf("%w: %w", ErrScanFailed, errors.Join(werr, err))
+	}
+
+	reply = strings.TrimPrefix(strings.TrimSpace(strings.TrimRight(reply, "\x00")), "stream: ")
+	switch {
+	case reply == "OK":
+		return ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+
+	return ScanResult{}, fmt.Errorf("%w: %s", ErrScanFailed, reply)
+}
+
+// stream writes INSTREAM command with length prefixed chunks, zero length chunk ends stream.
+func (c *ClamAV) stream(w io.Writer, r io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+clamChunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// newScanStat returns counter of scanned files by result.
+func newScanStat() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "media",
+		Name:      "scans_total",
+		Help:      "Count of scanned files by result: clean, infected or error.",
+	}, []string{"result"})
+}
+
+// RegisterMetrics registers media metrics in prometheus.
+func (m *Media) RegisterMetrics() {
+	prometheus.MustRegister(m.statScans)
+}
+
+// quarantineKey returns storage key of not scanned or infected file, quarantine is not served and skipped by check.
+func (m *Media) quarantineKey(key string) string {
+	return m.check.QuarantinePrefix + "/" + key
+}
+
+// scanKey returns storage key of file with scan status.
+func (m *Media) scanKey(key, status string) string {
+	if status == db.VfsScanPending || status == db.VfsScanInfected {
+		return m.quarantineKey(key)
+	}
+	return key
+}
+
+// scan checks r by scanner and returns scan status with signature. Scanner errors are logged and file stays pending.
+func (m *Media) scan(ctx context.Context, key string, r io.Reader) (string, string) {
+	res, err := m.scanner.Scan(ctx, r)
+	switch {
+	case err != nil:
+		m.statScans.WithLabelValues("error").Inc()
+		m.Error(ctx, "scan file failed", "err", err, "key", key)
+		return db.VfsScanPending, ""
+	case res.Infected:
+		m.statScans.WithLabelValues(db.VfsScanInfected).Inc()
+		m.Error(ctx, "infected file found", "key", key, "signature", res.Signature)
+		return db.VfsScanInfected, res.Signature
+	}
+
+	m.statScans.WithLabelValues(db.VfsScanClean).Inc()
+	return db.VfsScanClean, ""
+}
+
+// putScanned scans file and puts it to storage. Infected and not scanned files are put to quarantine,
+// scan status is empty if scanning is disabled.
+func (m *Media) putScanned(ctx context.Context, key string, tf *tempFile) (status, signature string, err error) {
+	if m.scanner != nil {
+		status, signature = m.scan(ctx, key, tf)
+		if err = tf.rewind(); err != nil {
+			return "", "", err
+		}
+	}
+
+	return status, signature, m.storage.Put(ctx, m.scanKey(key, status), tf, tf.size, tf.mimeType)
+}
+
+// infectedError returns upload error for infected file, signature is unknown for reused copies.
+func infectedError(signature string) error {
+	if signature == "" {
+		return newUploadError(http.StatusUnprocessableEntity, ErrInfected)
+	}
+	return newUploadError(http.StatusUnprocessableEntity, fmt.Errorf("%w: %s", ErrInfected, signature))
+}
+
+// setHashScanStatus saves scan status of new hash.
+func (t mediaTx) setHashScanStatus(ctx context.Context, ns, hash, status string) error {
+	if status == "" || t.scans == nil {
+		return nil
+	}
+	_, err := t.scans.SetVfsHashScanStatus(ctx, hashNamespace(ns), hash, status)
+	return err
+}
+
+// ScanPending rescans pending files and hashes in quarantine, clean ones are moved back and become visible.
+// It returns count of files with changed status.
+func (m *Media) ScanPending(ctx context.Context) (int, error) {
+	if m.scanner == nil || m.scans == nil {
+		return 0, nil
+	}
+
+	files, err := m.scans.PendingVfsFiles(ctx, m.scanCfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// files with the same content share path, see Media.DeleteFiles
+	var paths []string
+	byPath := make(map[string][]int)
+	for _, f := range files {
+		if _, ok := byPath[f.Path]; !ok {
+			paths = append(paths, f.Path)
+		}
+		byPath[f.Path] = append(byPath[f.Path], f.ID)
+	}
+
+	var (
+		n    int
+		errs []error
+	)
+	for _, p := range paths {
+		key, err := m.quarantinedFileKey(ctx, p)
+		if err == nil {
+			err = m.rescan(ctx, key, func(status string) error {
+				for _, id := range byPath[p] {
+					if _, err := m.scans.SetVfsFileScanStatus(ctx, id, status); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("file %s: %w", p, err))
+			continue
+		}
+		n += len(byPath[p])
+	}
+
+	hashes, err := m.scans.PendingVfsHashes(ctx, m.scanCfg.BatchSize)
+	if err != nil {
+		return n, errors.Join(append(errs, err)...)
+	}
+	for _, h := range hashes {
+		key, err := HashKey(h.Namespace, h.Hash, h.Extension)
+		if err == nil {
+			err = m.rescan(ctx, key, func(status string) error {
+				_, err := m.scans.SetVfsHashScanStatus(ctx, h.Namespace, h.Hash, status)
+				return err
+			})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hash %s/%s: %w", h.Namespace, h.Hash, err))
+			continue
+		}
+		n++
+	}
+
+	return n, errors.Join(errs...)
+}
+
+// rescan scans quarantined object and saves its status, clean object is moved from quarantine.
+func (m *Media) rescan(ctx context.Context, key string, save func(status string) error) error {
+	rc, _, err := m.storage.Get(ctx, m.quarantineKey(key))
+	if err != nil {
+		return err
+	}
+	status, _ := m.scan(ctx, key, rc)
+	_ = rc.Close()
+
+	switch status {
+	case db.VfsScanPending:
+		return ErrScanFailed
+	case db.VfsScanClean:
+		if err = m.storage.Move(ctx, m.quarantineKey(key), key); err != nil {
+			return err
+		}
+	}
+
+	return save(status)
+}
+
+// quarantinedFileKey returns storage key of quarantined file.
+func (m *Media) quarantinedFileKey(ctx context.Context, filePath string) (string, error) {
+	for _, key := range m.fileKeys(filePath) {
+		if _, err := m.storage.Stat(ctx, m.quarantineKey(key)); err == nil {
+			return key, nil
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return "", err
+		}
+	}
+
+	return "", storage.ErrNotFound
+}
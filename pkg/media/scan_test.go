@@ -0,0 +1,194 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/db/test"
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/vfs"
+)
+
+// scannerFunc is a Scanner for tests.
+type scannerFunc func(ctx context.Context, r io.Reader) (ScanResult, error)
+
+func (f scannerFunc) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	return f(ctx, r)
+}
+
+// fakeClamd runs clamd server which reports streams with "EICAR" as infected and returns its address.
+func fakeClamd(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	handle := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+			_, _ = io.WriteString(conn, "UNKNOWN COMMAND\x00")
+			return
+		}
+
+		var data []byte
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			} else if size == 0 {
+				break
+			}
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			data = append(data, chunk...)
+		}
+
+		reply := "stream: OK"
+		if bytes.Contains(data, []byte("EICAR")) {
+			reply = "stream: Eicar-Test-Signature FOUND"
+		}
+		_, _ = io.WriteString(conn, reply+"\x00")
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+
+	return "tcp://" + l.Addr().String()
+}
+
+func TestClamAV(t *testing.T) {
+	Convey("Test clamd scanner", t, func() {
+		ctx := t.Context()
+		c, err := NewClamAV(fakeClamd(t), time.Second)
+		So(err, ShouldBeNil)
+
+		res, err := c.Scan(ctx, bytes.NewReader(bytes.Repeat([]byte("a"), clamChunkSize*3+1)))
+		So(err, ShouldBeNil)
+		So(res, ShouldResemble, ScanResult{})
+
+		res, err = c.Scan(ctx, strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"))
+		So(err, ShouldBeNil)
+		So(res, ShouldResemble, ScanResult{Infected: true, Signature: "Eicar-Test-Signature"})
+
+		_, err = NewClamAV("udp://127.0.0.1:3310", 0)
+		So(err, ShouldWrap, ErrScanFailed)
+
+		c, err = NewClamAV("127.0.0.1:1", time.Second)
+		So(err, ShouldBeNil)
+		_, err = c.Scan(ctx, strings.NewReader("hello"))
+		So(err, ShouldWrap, ErrScanFailed)
+	})
+}
+
+func TestMedia_Scan(t *testing.T) {
+	Convey("Test scanning of uploads", t, func() {
+		ctx, local := t.Context(), storage.NewLocal(t.TempDir())
+		m := newTestMedia(t, local, storage.ServeProxy)
+
+		var down bool
+		m.scanner = scannerFunc(func(_ context.Context, r io.Reader) (ScanResult, error) {
+			b, err := io.ReadAll(r)
+			if err != nil || down {
+				return ScanResult{}, errors.Join(err, errors.New("clamd is down"))
+			}
+			return ScanResult{Infected: bytes.Contains(b, []byte("EICAR")), Signature: "Eicar"}, nil
+		})
+		put := func(key, content string) (string, string) {
+			tf, err := newTempFile(strings.NewReader(content))
+			So(err, ShouldBeNil)
+			defer tf.Remove()
+
+			status, signature, err := m.putScanned(ctx, key, tf)
+			So(err, ShouldBeNil)
+			return status, signature
+		}
+
+		Convey("Clean files are visible, infected and not scanned ones are quarantined", func() {
+			status, _ := put("202610/1_1.txt", "hello")
+			So(status, ShouldEqual, db.VfsScanClean)
+			_, err := local.Stat(ctx, "202610/1_1.txt")
+			So(err, ShouldBeNil)
+
+			status, signature := put("202610/1_2.txt", "EICAR")
+			So(status, ShouldEqual, db.VfsScanInfected)
+			So(signature, ShouldEqual, "Eicar")
+			So(errorResponse(infectedError(signature)).Error, ShouldEqual, "file is infected: Eicar")
+			_, err = local.Stat(ctx, "_quarantine/202610/1_2.txt")
+			So(err, ShouldBeNil)
+
+			down = true
+			status, _ = put("202610/1_3.txt", "hello")
+			So(status, ShouldEqual, db.VfsScanPending)
+			_, err = local.Stat(ctx, "202610/1_3.txt")
+			So(err, ShouldEqual, storage.ErrNotFound)
+
+			w := httptest.NewRecorder()
+			m.ServeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/media/_quarantine/202610/1_2.txt", nil))
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Pending files are rescanned", func() {
+			hash := "70c565ef460af43688b7ee6251028db9"
+			repo := test.NewVfsScanRepo(
+				[]db.VfsHashScan{{Hash: hash, Namespace: vfs.DefaultNamespace, Extension: "txt"}},
+				db.VfsFile{ID: 1, Path: "202610/1_1.txt", ScanStatus: test.Ptr(db.VfsScanPending), StatusID: db.StatusEnabled},
+				db.VfsFile{ID: 2, Path: "202610/1_2.txt", ScanStatus: test.Ptr(db.VfsScanPending), StatusID: db.StatusEnabled},
+			)
+			m.scans = repo
+			So(local.Put(ctx, "_quarantine/docs/202610/1_1.txt", strings.NewReader("hello"), 5, ""), ShouldBeNil)
+			So(local.Put(ctx, "_quarantine/202610/1_2.txt", strings.NewReader("EICAR"), 5, ""), ShouldBeNil)
+			So(local.Put(ctx, "_quarantine/7/0c/"+hash+".txt", strings.NewReader("hello"), 5, ""), ShouldBeNil)
+
+			down = true
+			n, err := m.ScanPending(ctx)
+			So(err, ShouldWrap, ErrScanFailed)
+			So(n, ShouldEqual, 0)
+
+			down = false
+			n, err = m.ScanPending(ctx)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+
+			_, err = local.Stat(ctx, "docs/202610/1_1.txt")
+			So(err, ShouldBeNil)
+			_, err = local.Stat(ctx, "7/0c/"+hash+".txt")
+			So(err, ShouldBeNil)
+			_, err = local.Stat(ctx, "_quarantine/202610/1_2.txt")
+			So(err, ShouldBeNil)
+
+			f, err := repo.Files().ByID(ctx, 2)
+			So(err, ShouldBeNil)
+			So(*f.ScanStatus, ShouldEqual, db.VfsScanInfected)
+			So(f.StatusID, ShouldEqual, db.StatusDisabled)
+			So(repo.HashStatuses[vfs.DefaultNamespace+"/"+hash], ShouldEqual, db.VfsScanClean)
+
+			n, err = m.ScanPending(ctx)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+	})
+}
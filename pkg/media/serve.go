@@ -0,0 +1,102 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"apisrv/pkg/storage"
+)
+
+// ServeHandler serves files from storage by web path, e.g. /media/7/0c/70c5.jpg.
+// Files are proxied or redirected to presigned urls if storage supports it and serve mode is redirect.
+// Preset images like /media/256/7/0c/70c5.jpg are generated from original hash files, see Preset.
+// Private files are served by signed urls only, see Media.SignURL. Not scanned and infected files are in quarantine.
+func (m *Media) ServeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// quarantine is never served
+		key, err := storage.Key(strings.TrimPrefix(r.URL.Path, m.cfg.WebPath))
+		if err != nil || strings.HasPrefix(key, m.check.QuarantinePrefix+"/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if ok, err := m.checkAccess(r, key); err != nil {
+			m.Error(r.Context(), "check access failed", "err", err, "key", key)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		} else if !ok {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		if img, ok := m.parseImagePath(key); ok {
+			m.serveImage(w, r, img)
+			return
+		}
+
+		if m.serve == storage.ServeRedirect {
+			u, err := m.storage.PresignGet(r.Context(), key, m.presignTTL)
+			if err == nil {
+				http.Redirect(w, r, u, http.StatusFound)
+				return
+			} else if !errors.Is(err, storage.ErrNotSupported) {
+				m.Error(r.Context(), "presign failed", "err", err, "key", key)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		m.proxy(w, r, key)
+	}
+}
+
+// proxy copies file from storage to response.
+func (m *Media) proxy(w http.ResponseWriter, r *http.Request, key string) {
+	rc, obj, err := m.storage.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		m.Error(r.Context(), "get file failed", "err", err, "key", key)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+
+	// local files support ranges
+	if rs, ok := rc.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, key, obj.ModTime, rs)
+		return
+	}
+
+	if !obj.ModTime.IsZero() {
+		if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !obj.ModTime.Truncate(time.Second).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", obj.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if obj.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	}
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err = io.Copy(w, rc); err != nil {
+		m.Error(r.Context(), "serve file failed", "err", err, "key", key)
+	}
+}
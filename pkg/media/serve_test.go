@@ -0,0 +1,69 @@
+package media
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/vfs"
+)
+
+// presignStorage is a local storage with fake presigned urls.
+type presignStorage struct {
+	*storage.Local
+}
+
+func (presignStorage) PresignGet(_ context.Context, key string, expires time.Duration) (string, error) {
+	return "https://s3.local/" + key + "?expires=" + expires.String(), nil
+}
+
+func TestMedia_ServeHandler(t *testing.T) {
+	Convey("Test serving media files", t, func() {
+		ctx, local := t.Context(), storage.NewLocal(t.TempDir())
+		key, err := HashKey(vfs.DefaultNamespace, "70c565ef460af43688b7ee6251028db9", "txt")
+		So(err, ShouldBeNil)
+		So(key, ShouldEqual, "7/0c/70c565ef460af43688b7ee6251028db9.txt")
+		So(local.Put(ctx, key, strings.NewReader("hello"), 5, ""), ShouldBeNil)
+
+		serve := func(h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			for k := range header {
+				r.Header.Set(k, header.Get(k))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+
+		Convey("Files are proxied", func() {
+			h := newTestMedia(t, local, storage.ServeProxy).ServeHandler()
+			w := serve(h, "/media/"+key, nil)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldEqual, "hello")
+			So(w.Header().Get("Content-Type"), ShouldStartWith, "text/plain")
+
+			w = serve(h, "/media/"+key, http.Header{"If-Modified-Since": {w.Header().Get("Last-Modified")}})
+			So(w.Code, ShouldEqual, http.StatusNotModified)
+
+			So(serve(h, "/media/1/2/3.txt", nil).Code, ShouldEqual, http.StatusNotFound)
+			So(serve(h, "/media/../secret", nil).Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Redirect falls back to proxy for local storage", func() {
+			w := serve(newTestMedia(t, local, storage.ServeRedirect).ServeHandler(), "/media/"+key, nil)
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Files are redirected to presigned urls", func() {
+			w := serve(newTestMedia(t, presignStorage{local}, storage.ServeRedirect).ServeHandler(), "/media/"+key, nil)
+			So(w.Code, ShouldEqual, http.StatusFound)
+			So(w.Header().Get("Location"), ShouldEqual, "https://s3.local/"+key+"?expires=1m0s")
+		})
+	})
+}
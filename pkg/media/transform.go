@@ -0,0 +1,213 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"apisrv/pkg/storage"
+
+	"github.com/vmkteam/vfs"
+)
+
+const (
+	cacheDirPerm = 0755
+	hashLength   = 32
+)
+
+var errNotImage = errors.New("original is not an image")
+
+// imagePath is a parsed preset url like [ns/]preset/a/bc/hash.jpg.
+type imagePath struct {
+	ns     string
+	preset string
+	hash   string
+	ext    string
+}
+
+// parseImagePath parses hash file path with preset. It returns false for other paths, e.g. originals.
+func (m *Media) parseImagePath(key string) (imagePath, bool) {
+	parts := strings.Split(key, "/")
+	n := len(parts)
+	if n != 4 && n != 5 {
+		return imagePath{}, false
+	}
+
+	ext := path.Ext(parts[n-1])
+	img := imagePath{preset: parts[n-4], hash: strings.TrimSuffix(parts[n-1], ext), ext: strings.TrimPrefix(ext, ".")}
+	if !isHash(img.hash) || parts[n-3] != img.hash[:1] || parts[n-2] != img.hash[1:3] {
+		return imagePath{}, false
+	}
+
+	if n == 5 {
+		img.ns = parts[0]
+	} else if _, ok := m.presets[img.preset]; !ok && img.preset != vfs.NamespacePublic && m.vfs.IsValidNamespace(img.preset) {
+		// original hash file in namespace
+		return imagePath{}, false
+	}
+
+	return img, true
+}
+
+func isHash(s string) bool {
+	if len(s) != hashLength {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// serveImage serves preset image from disk cache. Image is generated from original hash file on first request,
+// concurrent requests for the same image wait for one transform.
+func (m *Media) serveImage(w http.ResponseWriter, r *http.Request, img imagePath) {
+	p, ok := m.presets[img.preset]
+	if !ok || (img.ns != vfs.NamespacePublic && !m.vfs.IsValidNamespace(img.ns)) {
+		http.Error(w, ErrUnknownPreset.Error(), http.StatusNotFound)
+		return
+	}
+
+	if mode := r.URL.Query().Get("mode"); mode != "" {
+		if mode != ModeFit && mode != ModeCrop {
+			http.Error(w, ErrUnknownMode.Error(), http.StatusBadRequest)
+			return
+		}
+		p = p.withMode(mode)
+	}
+
+	filename := filepath.Join(m.images.CachePath, img.preset, p.Mode, img.ns, img.hash[:1], img.hash[1:3], img.hash+"."+img.ext)
+	if _, err := os.Stat(filename); err != nil {
+		_, err, _ = m.flight.Do(filename, func() (any, error) {
+			return nil, m.transform(context.WithoutCancel(r.Context()), img, p, filename)
+		})
+
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, errNotImage) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			m.Error(r.Context(), "image transform failed", "err", err, "path", r.URL.Path)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.ServeFile(w, r, filename)
+}
+
+// transform resizes original image and saves it to filename. Count of concurrent transforms is limited by workers.
+func (m *Media) transform(ctx context.Context, img imagePath, p Preset, filename string) error {
+	m.workers <- struct{}{}
+	defer func() { <-m.workers }()
+
+	// image could be generated while waiting for worker
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+
+	src, err := m.decodeOriginal(ctx, img)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(filename), cacheDirPerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), ".transform")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	if err = m.encode(f, Resize(src, p), img.ext); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+// decodeOriginal reads original hash file from storage. Preset urls use .jpg for any file,
+// so extension is taken from vfsHashes if there is no file with url extension.
+func (m *Media) decodeOriginal(ctx context.Context, img imagePath) (image.Image, error) {
+	key, err := HashKey(img.ns, img.hash, img.ext)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, _, err := m.storage.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) && m.dbc != nil {
+		ns := img.ns
+		if ns == vfs.NamespacePublic {
+			ns = vfs.DefaultNamespace
+		}
+
+		h, er := m.repo.VfsHashByID(ctx, img.hash, ns)
+		if er != nil {
+			return nil, er
+		} else if h == nil || h.Extension == img.ext {
+			return nil, err
+		}
+
+		if key, err = HashKey(img.ns, h.Hash, h.Extension); err != nil {
+			return nil, err
+		}
+		rc, _, err = m.storage.Get(ctx, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	src, err := decodeImage(rc, m.images.MaxPixels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errNotImage, key, err)
+	}
+
+	return src, nil
+}
+
+// decodeImage decodes image if its size from header is not more than maxPixels, zero means no limit.
+func decodeImage(r io.Reader, maxPixels int) (image.Image, error) {
+	var head bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, err
+	}
+	if maxPixels > 0 && cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d, max %d pixels", ErrImageTooLarge, cfg.Width, cfg.Height, maxPixels)
+	}
+
+	img, _, err := image.Decode(io.MultiReader(&head, r))
+	return img, err
+}
+
+// encode writes image in format by extension, jpeg is default.
+func (m *Media) encode(w io.Writer, img image.Image, ext string) error {
+	switch strings.ToLower(ext) {
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	}
+
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: m.images.Quality})
+}
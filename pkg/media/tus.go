@@ -0,0 +1,512 @@
+package media
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/vmkteam/vfs"
+)
+
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+
+	defaultTusMaxSize    = 1 << 30
+	maxTusSize           = math.MaxInt32 // fileSize of vfsFiles and vfsHashes is int4
+	defaultTusExpiration = time.Hour * 24
+	tusDirPerm           = 0755
+	tusFilePerm          = 0644
+)
+
+var (
+	ErrTusNotFound = errors.New("upload not found")
+	ErrTusLocked   = errors.New("upload is in progress")
+	ErrTusVersion  = errors.New("unsupported tus version")
+	// ErrTusHeader is an error of invalid tus request header, header name is added to it.
+	ErrTusHeader = errors.New("invalid tus header")
+	// ErrBadFolder is an error of invalid folder id of upload.
+	ErrBadFolder = errors.New("bad folder")
+)
+
+type TusConfig struct {
+	// Path is a local directory for partial uploads, it must be shared by replicas.
+	Path string
+	// MaxSize is a max size of one upload, it can't be more than 2^31-1 bytes.
+	MaxSize int64
+	// Expiration is a lifetime of unfinished uploads.
+	Expiration time.Duration
+}
+
+// tusUpload is a state of resumable upload, it is saved as json next to upload data.
+// Offset is a size of data file.
+type tusUpload struct {
+	ID        string              `json:"id"`
+	Length    int64               `json:"length"`
+	Metadata  map[string]string   `json:"metadata"`
+	ExpiresAt time.Time           `json:"expiresAt"`
+	Result    *vfs.UploadResponse `json:"result,omitempty"`
+}
+
+// tusStore keeps partial uploads in local directory. Uploads are locked by process and by postgres advisory lock,
+// because directory is shared by replicas.
+type tusStore struct {
+	cfg    TusConfig
+	dbc    *pg.DB
+	locked sync.Map
+}
+
+func newTusStore(cfg TusConfig, dbc *pg.DB) *tusStore {
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(os.TempDir(), "apisrv-tus")
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = defaultTusMaxSize
+	}
+	cfg.MaxSize = min(cfg.MaxSize, maxTusSize)
+	if cfg.Expiration == 0 {
+		cfg.Expiration = defaultTusExpiration
+	}
+
+	return &tusStore{cfg: cfg, dbc: dbc}
+}
+
+func (ts *tusStore) dataPath(id string) string {
+	return filepath.Join(ts.cfg.Path, id)
+}
+
+func (ts *tusStore) infoPath(id string) string {
+	return filepath.Join(ts.cfg.Path, id+".json")
+}
+
+func (ts *tusStore) create(u *tusUpload) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	u.ID, u.ExpiresAt = hex.EncodeToString(b), time.Now().Add(ts.cfg.Expiration)
+
+	if err := os.MkdirAll(ts.cfg.Path, tusDirPerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(ts.dataPath(u.ID), nil, tusFilePerm); err != nil {
+		return err
+	}
+
+	return ts.save(u)
+}
+
+func (ts *tusStore) save(u *tusUpload) error {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ts.infoPath(u.ID), b, tusFilePerm)
+}
+
+// get returns upload and its offset, expired uploads are not found.
+func (ts *tusStore) get(id string) (*tusUpload, int64, error) {
+	if !isHexID(id) {
+		return nil, 0, ErrTusNotFound
+	}
+
+	b, err := os.ReadFile(ts.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, ErrTusNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	var u tusUpload
+	if err = json.Unmarshal(b, &u); err != nil {
+		return nil, 0, err
+	} else if time.Now().After(u.ExpiresAt) {
+		return nil, 0, ErrTusNotFound
+	} else if u.Result != nil {
+		return &u, u.Length, nil
+	}
+
+	fi, err := os.Stat(ts.dataPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, ErrTusNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	return &u, fi.Size(), nil
+}
+
+// lock marks upload as busy, concurrent patches of one upload on any replica are rejected with ErrTusLocked.
+// Session advisory lock is held on dedicated connection until unlock.
+func (ts *tusStore) lock(ctx context.Context, id string) (unlock func(), err error) {
+	if _, busy := ts.locked.LoadOrStore(id, struct{}{}); busy {
+		return nil, ErrTusLocked
+	}
+	if ts.dbc == nil {
+		return func() { ts.locked.Delete(id) }, nil
+	}
+
+	var ok bool
+	conn := ts.dbc.Conn()
+	if _, err = conn.QueryOneContext(ctx, pg.Scan(&ok), `SELECT pg_try_advisory_lock(hashtext(?), 0)`, "tus:"+id); err != nil || !ok {
+		_ = conn.Close()
+		ts.locked.Delete(id)
+		if err == nil {
+			err = ErrTusLocked
+		}
+		return nil, err
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock(hashtext(?), 0)`, "tus:"+id)
+		_ = conn.Close()
+		ts.locked.Delete(id)
+	}, nil
+}
+
+func (ts *tusStore) remove(id string) {
+	_ = os.Remove(ts.dataPath(id))
+	_ = os.Remove(ts.infoPath(id))
+}
+
+func isHexID(s string) bool {
+	_, err := hex.DecodeString(s)
+	return len(s) == 32 && err == nil
+}
+
+// TusHandler handles tus 1.0 resumable uploads with creation, expiration and termination extensions.
+// Upload metadata: filename, ns (namespace), ext (file extension) and folderID. Uploads with folderID
+// are saved as vfs files like UploadHandler, other uploads are saved as hashes like HashUploadHandler.
+// Result is returned by the last PATCH and HEAD in Vfs-File-Id or Vfs-Hash and Vfs-Web-Path headers.
+func (m *Media) TusHandler(basePath string) http.Handler {
+	basePath = strings.TrimSuffix(basePath, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Tus-Version", tusVersion)
+			w.Header().Set("Tus-Extension", tusExtensions)
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(m.tus.cfg.MaxSize, 10))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			http.Error(w, ErrTusVersion.Error(), http.StatusPreconditionFailed)
+			return
+		}
+
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, basePath), "/")
+		switch {
+		case id == "" && r.Method == http.MethodPost:
+			m.tusCreate(w, r, basePath)
+		case id != "" && r.Method == http.MethodHead:
+			m.tusHead(w, r, id)
+		case id != "" && r.Method == http.MethodPatch:
+			m.tusPatch(w, r, id)
+		case id != "" && r.Method == http.MethodDelete:
+			m.tusDelete(w, r, id)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// tusCreate validates upload length and metadata and creates empty upload.
+func (m *Media) tusCreate(w http.ResponseWriter, r *http.Request, basePath string) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, fmt.Sprintf("%v: Upload-Length", ErrTusHeader), http.StatusBadRequest)
+		return
+	} else if length > m.tus.cfg.MaxSize {
+		http.Error(w, fmt.Sprintf("%v: %v bytes", ErrFileTooLarge, m.tus.cfg.MaxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	meta, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = m.validateTusMetadata(r.Context(), meta, length); err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+
+	u := &tusUpload{Length: length, Metadata: meta}
+	if err = m.tus.create(u); err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", basePath+"/"+u.ID)
+	w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// validateTusMetadata checks namespace, extension, file size and folder before upload is started.
+func (m *Media) validateTusMetadata(ctx context.Context, meta map[string]string, length int64) error {
+	if meta["ext"] == "" {
+		meta["ext"] = strings.TrimPrefix(strings.ToLower(path.Ext(meta["filename"])), ".")
+	}
+	if !m.vfs.IsValidNamespace(meta["ns"]) {
+		return newUploadError(http.StatusBadRequest, vfs.ErrInvalidNamespace)
+	}
+	if !m.isValidExtension(meta["ns"], meta["ext"]) {
+		return newUploadError(http.StatusBadRequest, vfs.ErrInvalidExtension)
+	}
+	if maxSize := m.maxFileSize(meta["ns"]); maxSize > 0 && length > maxSize {
+		return newUploadError(http.StatusRequestEntityTooLarge, fmt.Errorf("%w: %v bytes", ErrFileTooLarge, maxSize))
+	}
+
+	if v, ok := meta["folderID"]; ok {
+		folderID, err := strconv.Atoi(v)
+		if err != nil {
+			return newUploadError(http.StatusBadRequest, fmt.Errorf("%w: %w", ErrBadFolder, err))
+		}
+		folder, err := m.repo.VfsFolderByID(ctx, folderID)
+		if err != nil {
+			return err
+		} else if folder == nil {
+			return newUploadError(http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+		}
+	}
+
+	return nil
+}
+
+func (m *Media) tusHead(w http.ResponseWriter, r *http.Request, id string) {
+	u, offset, err := m.tus.get(id)
+	if err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	setTusResult(w, u.Result)
+	w.WriteHeader(http.StatusOK)
+}
+
+// tusPatch appends body to upload at offset. Completed upload is saved to storage.
+func (m *Media) tusPatch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != tusContentType {
+		http.Error(w, fmt.Sprintf("%v: Content-Type", ErrTusHeader), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	unlock, err := m.tus.lock(r.Context(), id)
+	if err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+	defer unlock()
+
+	u, offset, err := m.tus.get(id)
+	if err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+	if v, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64); err != nil || v != offset || u.Result != nil {
+		http.Error(w, fmt.Sprintf("%v: Upload-Offset", ErrTusHeader), http.StatusConflict)
+		return
+	}
+
+	f, err := os.OpenFile(m.tus.dataPath(id), os.O_WRONLY|os.O_APPEND, tusFilePerm)
+	if err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+
+	// partial body is kept, client resumes from new offset
+	n, err := io.Copy(f, io.LimitReader(r.Body, u.Length-offset))
+	if er := f.Close(); err == nil {
+		err = er
+	}
+	offset += n
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	if err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+
+	if offset == u.Length {
+		if err = m.finishTus(r.Context(), u); err != nil {
+			m.tusError(w, r, err)
+			return
+		}
+		setTusResult(w, u.Result)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finishTus saves completed upload as vfs file or hash. Upload is removed if it is rejected by validation,
+// on other errors it is kept and could be finished by empty PATCH with final offset.
+func (m *Media) finishTus(ctx context.Context, u *tusUpload) error {
+	tf, err := openTempFile(m.tus.dataPath(u.ID))
+	if err != nil {
+		return err
+	}
+	defer tf.Close()
+
+	var ur vfs.UploadResponse
+	ns, ext := u.Metadata["ns"], u.Metadata["ext"]
+	if v, ok := u.Metadata["folderID"]; ok {
+		ur, err = m.tusFile(ctx, v, ns, ext, u.Metadata["filename"], tf)
+	} else {
+		ur, err = m.saveHash(ctx, ns, ext, tf)
+	}
+
+	var ue uploadError
+	if errors.As(err, &ue) {
+		m.tus.remove(u.ID)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	u.Result = &ur
+	if err = m.tus.save(u); err != nil {
+		return err
+	}
+	_ = os.Remove(m.tus.dataPath(u.ID))
+
+	return nil
+}
+
+// tusFile saves completed upload to vfs folder.
+func (m *Media) tusFile(ctx context.Context, folderID, ns, ext, filename string, tf *tempFile) (vfs.UploadResponse, error) {
+	if !m.isValidMimeType(ns, tf.mimeType) {
+		return vfs.UploadResponse{}, newUploadError(http.StatusBadRequest, vfs.ErrInvalidMimeType)
+	}
+
+	id, err := strconv.Atoi(folderID)
+	if err != nil {
+		return vfs.UploadResponse{}, newUploadError(http.StatusBadRequest, fmt.Errorf("%w: %w", ErrBadFolder, err))
+	}
+	folder, err := m.repo.VfsFolderByID(ctx, id)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	} else if folder == nil {
+		return vfs.UploadResponse{}, newUploadError(http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+	}
+
+	name := strings.TrimSuffix(filename, path.Ext(filename))
+	fileID, err := m.createFile(ctx, folder, ns, tf, name, ext)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	}
+
+	return vfs.UploadResponse{Code: http.StatusOK, FileID: fileID, Extension: ext, Name: name, Size: tf.size}, nil
+}
+
+func (m *Media) tusDelete(w http.ResponseWriter, r *http.Request, id string) {
+	unlock, err := m.tus.lock(r.Context(), id)
+	if err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+	defer unlock()
+
+	if _, _, err := m.tus.get(id); err != nil {
+		m.tusError(w, r, err)
+		return
+	}
+
+	m.tus.remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CleanupUploads removes expired resumable uploads.
+func (m *Media) CleanupUploads(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(m.tus.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !isHexID(id) || ctx.Err() != nil {
+			continue
+		}
+
+		if _, _, err = m.tus.get(id); errors.Is(err, ErrTusNotFound) {
+			m.tus.remove(id)
+			removed++
+		}
+	}
+
+	return removed, ctx.Err()
+}
+
+// tusError writes error response, upload errors have own status codes.
+func (m *Media) tusError(w http.ResponseWriter, r *http.Request, err error) {
+	var ue uploadError
+	switch {
+	case errors.As(err, &ue):
+		http.Error(w, ue.Error(), ue.code)
+	case errors.Is(err, ErrTusNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrTusLocked):
+		http.Error(w, err.Error(), http.StatusLocked)
+	default:
+		m.Error(r.Context(), "tus upload failed", "err", err, "path", r.URL.Path)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// setTusResult sets headers with result of completed upload.
+func setTusResult(w http.ResponseWriter, ur *vfs.UploadResponse) {
+	if ur == nil {
+		return
+	}
+
+	if ur.FileID != 0 {
+		w.Header().Set("Vfs-File-Id", strconv.Itoa(ur.FileID))
+	}
+	if ur.Hash != "" {
+		w.Header().Set("Vfs-Hash", ur.Hash)
+		w.Header().Set("Vfs-Web-Path", ur.WebPath)
+	}
+}
+
+// parseTusMetadata parses Upload-Metadata header: comma separated keys with base64 encoded values.
+func parseTusMetadata(s string) (map[string]string, error) {
+	meta := make(map[string]string)
+	for pair := range strings.SplitSeq(s, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: Upload-Metadata %s: %w", ErrTusHeader, key, err)
+		}
+		meta[key] = string(b)
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,110 @@
+package media
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"apisrv/pkg/storage"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/vfs"
+)
+
+func TestMedia_Tus(t *testing.T) {
+	Convey("Test resumable uploads", t, func() {
+		m := newTestMedia(t, storage.NewLocal(t.TempDir()), storage.ServeProxy)
+		m.tus = newTusStore(TusConfig{Path: t.TempDir(), MaxSize: 100}, nil)
+		h := m.TusHandler("/v1/vfs/upload/tus/")
+
+		do := func(method, target string, header http.Header, body string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(method, target, strings.NewReader(body))
+			r.Header.Set("Tus-Resumable", "1.0.0")
+			for k := range header {
+				r.Header.Set(k, header.Get(k))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+		create := func(length, metadata string) *httptest.ResponseRecorder {
+			return do(http.MethodPost, "/v1/vfs/upload/tus", http.Header{"Upload-Length": {length}, "Upload-Metadata": {metadata}}, "")
+		}
+		patch := func(location, offset, body string) *httptest.ResponseRecorder {
+			return do(http.MethodPatch, location, http.Header{"Upload-Offset": {offset}, "Content-Type": {"application/offset+octet-stream"}}, body)
+		}
+
+		Convey("Protocol is validated", func() {
+			w := do(http.MethodOptions, "/v1/vfs/upload/tus", nil, "")
+			So(w.Code, ShouldEqual, http.StatusNoContent)
+			So(w.Header().Get("Tus-Extension"), ShouldEqual, "creation,expiration,termination")
+			So(w.Header().Get("Tus-Max-Size"), ShouldEqual, "100")
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/vfs/upload/tus", nil)
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusPreconditionFailed)
+
+			So(create("101", "").Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			So(create("x", "").Code, ShouldEqual, http.StatusBadRequest)
+			So(create("10", "filename YS5leGU=").Code, ShouldEqual, http.StatusBadRequest) // a.exe
+			So(create("10", "ns ZmlsZXM=").Code, ShouldEqual, http.StatusBadRequest)       // files
+
+			// namespace max file size is checked before upload
+			m.policies = map[string]Policy{"docs": {MaxFileSize: 5}}
+			So(create("10", "filename YS50eHQ=,ns ZG9jcw==").Code, ShouldEqual, http.StatusRequestEntityTooLarge) // a.txt, docs
+			So(create("5", "filename YS50eHQ=,ns ZG9jcw==").Code, ShouldEqual, http.StatusCreated)
+			So(do(http.MethodHead, "/v1/vfs/upload/tus/0123", nil, "").Code, ShouldEqual, http.StatusNotFound)
+
+			// sizes are limited by int4 file size columns
+			So(newTusStore(TusConfig{}, nil).cfg.MaxSize, ShouldEqual, defaultTusMaxSize)
+			So(newTusStore(TusConfig{MaxSize: 1 << 40}, nil).cfg.MaxSize, ShouldEqual, maxTusSize)
+		})
+
+		Convey("Upload is resumed by offset and validated when completed", func() {
+			w := create("10", "filename YS50eHQ=,ns ") // a.txt
+			So(w.Code, ShouldEqual, http.StatusCreated)
+			location := w.Header().Get("Location")
+			So(location, ShouldStartWith, "/v1/vfs/upload/tus/")
+
+			So(patch(location, "1", "hello").Code, ShouldEqual, http.StatusConflict)
+			w = patch(location, "0", "hello")
+			So(w.Code, ShouldEqual, http.StatusNoContent)
+			So(w.Header().Get("Upload-Offset"), ShouldEqual, "5")
+
+			w = do(http.MethodHead, location, nil, "")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Upload-Offset"), ShouldEqual, "5")
+			So(w.Header().Get("Upload-Length"), ShouldEqual, "10")
+
+			// text is not allowed by mime types
+			w = patch(location, "5", "world and more")
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(w.Body.String(), ShouldContainSubstring, vfs.ErrInvalidMimeType.Error())
+			So(do(http.MethodHead, location, nil, "").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Uploads are terminated and expired", func() {
+			location := create("10", "").Header().Get("Location")
+			So(do(http.MethodDelete, location, nil, "").Code, ShouldEqual, http.StatusNoContent)
+			So(do(http.MethodHead, location, nil, "").Code, ShouldEqual, http.StatusNotFound)
+
+			location = create("10", "").Header().Get("Location")
+			u, _, err := m.tus.get(path.Base(location))
+			So(err, ShouldBeNil)
+			u.ExpiresAt = time.Now().Add(-time.Second)
+			So(m.tus.save(u), ShouldBeNil)
+
+			n, err := m.CleanupUploads(t.Context())
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			entries, err := os.ReadDir(m.tus.cfg.Path)
+			So(err, ShouldBeNil)
+			So(entries, ShouldBeEmpty)
+		})
+	})
+}
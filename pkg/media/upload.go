@@ -0,0 +1,374 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/storage"
+
+	"github.com/vmkteam/vfs"
+	vfsdb "github.com/vmkteam/vfs/db"
+)
+
+// upload is a file from request body or multipart form.
+type upload struct {
+	io.ReadCloser
+	size int64
+	name string // filename without extension for multipart form
+	ext  string // extension from filename for multipart form
+}
+
+// uploadError is an upload error with http status code.
+type uploadError struct {
+	code int
+	err  error
+}
+
+func (e uploadError) Error() string {
+	return e.err.Error()
+}
+
+func (e uploadError) Unwrap() error {
+	return e.err
+}
+
+func newUploadError(code int, err error) error {
+	return uploadError{code: code, err: err}
+}
+
+// readUpload returns file from PUT body or POST multipart form, file size is validated by maxSize.
+func (m *Media) readUpload(r *http.Request, maxSize int64) (*upload, error) {
+	var u upload
+	switch r.Method {
+	case http.MethodPut:
+		if r.Body == nil {
+			return nil, newUploadError(http.StatusBadRequest, errors.New("empty body"))
+		}
+		u.ReadCloser, u.size = r.Body, r.ContentLength
+	case http.MethodPost:
+		if err := r.ParseMultipartForm(maxSize); err != nil {
+			return nil, newUploadError(http.StatusInternalServerError, err)
+		}
+
+		file, handler, err := r.FormFile(m.cfg.UploadFormName)
+		if err != nil {
+			return nil, newUploadError(http.StatusBadRequest, err)
+		}
+
+		ext := filepath.Ext(handler.Filename)
+		u.ReadCloser, u.size = file, handler.Size
+		u.name, u.ext = strings.TrimSuffix(handler.Filename, ext), strings.TrimPrefix(ext, ".")
+	default:
+		return nil, newUploadError(http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+	}
+
+	if u.size > maxSize {
+		_ = u.Close()
+		return nil, newUploadError(http.StatusRequestEntityTooLarge, fmt.Errorf("file size exceed %v bytes", maxSize))
+	}
+
+	return &u, nil
+}
+
+// HashUploadHandler saves file by its md5 hash, it replaces vfs.HashUploadHandler.
+// Form values: ns (namespace), ext (file extension).
+func (m *Media) HashUploadHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ns, ext := r.FormValue("ns"), strings.ToLower(r.FormValue("ext"))
+		ur, err := m.hashUpload(r, ns, ext)
+		if err != nil {
+			ur = errorResponse(err)
+		}
+
+		m.writeResponse(w, r, ur)
+	}
+}
+
+func (m *Media) hashUpload(r *http.Request, ns, ext string) (vfs.UploadResponse, error) {
+	if !m.vfs.IsValidNamespace(ns) {
+		return vfs.UploadResponse{}, newUploadError(http.StatusBadRequest, vfs.ErrInvalidNamespace)
+	}
+	if !m.isValidExtension(ns, ext) {
+		return vfs.UploadResponse{}, newUploadError(http.StatusBadRequest, vfs.ErrInvalidExtension)
+	}
+
+	u, err := m.readUpload(r, m.maxFileSize(ns))
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	}
+	defer u.Close()
+
+	tf, err := newTempFile(u)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	}
+	defer tf.Remove()
+
+	return m.saveHash(r.Context(), ns, ext, tf)
+}
+
+// saveHash puts file to storage by its hash and registers it in vfsHashes. File is checked by namespace policy, quota and scanner.
+func (m *Media) saveHash(ctx context.Context, ns, ext string, tf *tempFile) (vfs.UploadResponse, error) {
+	if err := m.validateUpload(ns, ext, tf, true); err != nil {
+		return vfs.UploadResponse{}, err
+	}
+
+	// hash is calculated by prepared content
+	pf, _, err := m.prepareImage(ns, tf)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	} else if pf != tf {
+		defer pf.Remove()
+		tf = pf
+	}
+
+	// quota is checked before upload to reject large files early and again with hash saving under namespace lock
+	if err = m.checkNamespaceQuota(ctx, m.noTx(), ns, tf.hash, tf.size); err != nil {
+		return vfs.UploadResponse{}, err
+	}
+
+	key, err := HashKey(ns, tf.hash, ext)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	}
+	status, signature, err := m.putScanned(ctx, key, tf)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	}
+
+	err = m.runInTx(ctx, func(t mediaTx) error {
+		if err := m.checkNamespaceQuota(ctx, t, ns, tf.hash, tf.size); err != nil {
+			return err
+		}
+
+		err := t.repo.SaveVfsHash(ctx, &vfsdb.VfsHash{Hash: tf.hash, Namespace: hashNamespace(ns), Extension: ext, FileSize: int(tf.size), CreatedAt: time.Now()})
+		if err != nil {
+			m.Error(ctx, "hash saved failed", "err", err, "hash", tf.hash)
+			return err
+		}
+		return t.setHashScanStatus(ctx, ns, tf.hash, status)
+	})
+	if errors.Is(err, ErrQuotaExceeded) {
+		// hash is new, otherwise quota is not checked for it
+		if er := m.storage.Delete(ctx, m.scanKey(key, status)); er != nil {
+			m.Error(ctx, "delete uploaded hash failed", "err", er, "key", key)
+		}
+		return vfs.UploadResponse{}, err
+	} else if err != nil {
+		return vfs.UploadResponse{}, err
+	} else if status == db.VfsScanInfected {
+		return vfs.UploadResponse{}, infectedError(signature)
+	}
+
+	fh := vfs.NewFileHash(tf.hash, ext)
+	return vfs.UploadResponse{Code: http.StatusOK, Hash: fh.Hash, Extension: fh.Ext, WebPath: m.vfs.WebHashPath(ns, fh), Size: tf.size}, nil
+}
+
+// UploadHandler saves file to vfs folder, it replaces vfs.UploadHandler.
+// Form values: ns (namespace), folderID (vfs folder).
+func (m *Media) UploadHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ur, err := m.upload(r, r.FormValue("ns"))
+		if err != nil {
+			ur = errorResponse(err)
+		}
+
+		m.writeResponse(w, r, ur)
+	}
+}
+
+func (m *Media) upload(r *http.Request, ns string) (vfs.UploadResponse, error) {
+	folderID, err := strconv.Atoi(r.FormValue("folderID"))
+	if err != nil {
+		return vfs.UploadResponse{}, newUploadError(http.StatusBadRequest, fmt.Errorf("%w: %w", ErrBadFolder, err))
+	}
+
+	folder, err := m.repo.VfsFolderByID(r.Context(), folderID)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	} else if folder == nil {
+		return vfs.UploadResponse{}, newUploadError(http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+	}
+
+	u, err := m.readUpload(r, m.maxFileSize(ns))
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	}
+	defer u.Close()
+
+	if u.ext == "" {
+		u.ext = strings.ToLower(r.FormValue("ext"))
+	}
+
+	tf, err := newTempFile(u)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	}
+	defer tf.Remove()
+
+	id, err := m.createFile(r.Context(), folder, ns, tf, u.name, u.ext)
+	if err != nil {
+		return vfs.UploadResponse{}, err
+	}
+
+	return vfs.UploadResponse{Code: http.StatusOK, FileID: id, Extension: u.ext, Name: u.name, Size: tf.size}, nil
+}
+
+// createFile puts file to storage and adds it to folder. File is checked by namespace policy, folder quota and scanner,
+// image metadata is saved to file params.
+// Physical copy of file with the same content in namespace is reused, see Media.DeleteFiles.
+func (m *Media) createFile(ctx context.Context, folder *vfsdb.VfsFolder, ns string, tf *tempFile, name, ext string) (int, error) {
+	if err := m.validateUpload(ns, ext, tf, false); err != nil {
+		return 0, err
+	}
+
+	pf, params, err := m.prepareImage(ns, tf)
+	if err != nil {
+		return 0, err
+	} else if pf != tf {
+		defer pf.Remove()
+		tf = pf
+	}
+
+	// quota is checked before upload to reject large files early and again with file adding under folders lock
+	if err = m.checkFolderQuota(ctx, m.noTx(), folder.ID, tf.size); err != nil {
+		return 0, err
+	}
+
+	dup, err := m.duplicateFile(ctx, ns, tf)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := m.repo.NextFileID()
+	if err != nil {
+		return 0, err
+	}
+
+	status, signature, err := m.addFile(ctx, folder.ID, id, ns, name, ext, tf, dup, params)
+	if errors.Is(err, errDuplicateDeleted) {
+		// duplicate was deleted after it was found, file content is uploaded again
+		status, signature, err = m.addFile(ctx, folder.ID, id, ns, name, ext, tf, nil, params)
+	}
+	if err != nil {
+		return 0, err
+	} else if status == db.VfsScanInfected {
+		return 0, infectedError(signature)
+	}
+
+	return id, nil
+}
+
+// addFile puts file to storage or reuses path of duplicate and adds file with its hash, scan status and params.
+// It returns scan status and signature of infected file.
+// Duplicate path is locked and checked in transaction, errDuplicateDeleted is returned if it is not referenced anymore.
+func (m *Media) addFile(ctx context.Context, folderID, id int, ns, name, ext string, tf *tempFile, dup *db.VfsFile, params *fileParams) (status, signature string, err error) {
+	var filePath string
+	if dup != nil {
+		filePath, status = dup.Path, scanStatus(*dup)
+	} else if filePath, status, signature, err = m.putFile(ctx, folderID, id, ns, ext, tf); err != nil {
+		return "", "", err
+	}
+
+	// infected file is kept in quarantine for review, it is not visible
+	statusID := vfsdb.StatusEnabled
+	if status == db.VfsScanInfected {
+		statusID = vfsdb.StatusDisabled
+	}
+
+	fileSize := int(tf.size)
+	err = m.runInTx(ctx, func(t mediaTx) error {
+		if err := m.checkFolderQuota(ctx, t, folderID, tf.size); err != nil {
+			return err
+		}
+
+		if dup != nil {
+			if err := t.lock(ctx, pathLocks(filePath)...); err != nil {
+				return err
+			}
+			if refs, err := t.dedup.CountVfsFiles(ctx, &db.VfsFileSearch{Path: &filePath}); err != nil {
+				return err
+			} else if refs == 0 {
+				return errDuplicateDeleted
+			}
+		}
+
+		return t.addFile(ctx, db.VfsFile{
+			ID:         id,
+			FolderID:   folderID,
+			Title:      name,
+			Path:       filePath,
+			MimeType:   tf.mimeType,
+			FileSize:   &fileSize,
+			Hash:       &tf.hash,
+			FileExists: true,
+			ScanStatus: &status,
+			CreatedAt:  time.Now(),
+			StatusID:   statusID,
+		}, params)
+	})
+	if err != nil && dup == nil {
+		key := m.scanKey(path.Join(ns, filePath), status)
+		if er := m.storage.Delete(ctx, key); er != nil {
+			m.Error(ctx, "delete uploaded file failed", "err", er, "key", key)
+		}
+	}
+
+	return status, signature, err
+}
+
+// putFile scans file and puts it to storage. Path is like 202401/1_9.png, as in vfs.
+func (m *Media) putFile(ctx context.Context, folderID, fileID int, ns, ext string, tf *tempFile) (filePath, status, signature string, err error) {
+	salt := ""
+	if m.cfg.SaltedFilenames {
+		salt = "_" + randSeq(8)
+	}
+
+	filePath = filepath.ToSlash(filepath.Join(time.Now().Format("200601"), fmt.Sprintf("%d_%d%s.%s", folderID, fileID, salt, ext)))
+	key, err := storage.Key(ns, filePath)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	status, signature, err = m.putScanned(ctx, key, tf)
+	return filePath, status, signature, err
+}
+
+// errorResponse converts error to upload response, unknown errors are internal.
+func errorResponse(err error) vfs.UploadResponse {
+	var ue uploadError
+	if errors.As(err, &ue) {
+		return vfs.UploadResponse{Code: ue.code, Error: ue.Error()}
+	}
+
+	return vfs.UploadResponse{Code: http.StatusInternalServerError, Error: err.Error()}
+}
+
+func (m *Media) writeResponse(w http.ResponseWriter, r *http.Request, ur vfs.UploadResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(ur.Code)
+	if err := json.NewEncoder(w).Encode(ur); err != nil {
+		m.Error(r.Context(), "write upload response failed", "err", err)
+	}
+}
+
+func randSeq(n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[rand.IntN(len(letters))]
+	}
+	return string(b)
+}
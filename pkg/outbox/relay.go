@@ -0,0 +1,246 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmkteam/embedlog"
+)
+
+const (
+	defaultInterval    = time.Second
+	defaultBatchSize   = 100
+	defaultMaxAttempts = 10
+	defaultRetention   = time.Hour * 24 * 7
+	minRetryDelay      = time.Second * 5
+	maxRetryDelay      = time.Hour
+)
+
+type Config struct {
+	Enabled bool
+	// Sink is a type of events receiver: http, file or log.
+	Sink string
+	// URL is webhook url for http sink.
+	URL string
+	// Timeout is a max duration of one event delivery.
+	Timeout time.Duration
+	// Path is file path for file sink.
+	Path string
+	// Interval is a delay between polls of outbox table.
+	Interval time.Duration
+	// BatchSize is max count of events sent in one transaction.
+	BatchSize int
+	// MaxAttempts is count of delivery attempts before event becomes dead.
+	MaxAttempts int
+	// Retention is a max age of sent and dead events, older events are deleted by outboxCleanup cron task.
+	Retention time.Duration
+}
+
+// Relay delivers events from outbox table to Sink.
+type Relay struct {
+	embedlog.Logger
+	repo db.OutboxRepo
+	sink Sink
+	cfg  Config
+
+	done       chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
+	sendCtx    context.Context
+	cancelSend context.CancelFunc
+
+	statLag     prometheus.Gauge
+	statPending prometheus.Gauge
+	statEvents  *prometheus.CounterVec
+}
+
+// NewRelay returns new relay for outbox table.
+func NewRelay(dbc db.DB, logger embedlog.Logger, sink Sink, cfg Config) *Relay {
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultHTTPTimeout
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = defaultRetention
+	}
+
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	return &Relay{
+		Logger:     logger,
+		repo:       db.NewOutboxRepo(dbc),
+		sink:       sink,
+		cfg:        cfg,
+		done:       make(chan struct{}),
+		sendCtx:    sendCtx,
+		cancelSend: cancelSend,
+
+		statLag: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "app",
+			Subsystem: "outbox",
+			Name:      "lag_seconds",
+			Help:      "Age of the oldest not delivered outbox event.",
+		}),
+		statPending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "app",
+			Subsystem: "outbox",
+			Name:      "pending_events",
+			Help:      "Count of not delivered outbox events.",
+		}),
+		statEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "app",
+			Subsystem: "outbox",
+			Name:      "events_total",
+			Help:      "Processed outbox events by result.",
+		}, []string{"result"}),
+	}
+}
+
+// RegisterMetrics registers relay metrics in prometheus.
+func (r *Relay) RegisterMetrics() {
+	prometheus.MustRegister(r.statLag, r.statPending, r.statEvents)
+}
+
+// Run starts polling of outbox table until ctx is done or Stop is called.
+func (r *Relay) Run(ctx context.Context) {
+	r.wg.Add(1)
+	go r.loop(ctx)
+}
+
+// loop polls outbox table and processes ready events.
+func (r *Relay) loop(ctx context.Context) {
+	defer r.wg.Done()
+
+	// delivery is canceled if Stop deadline is exceeded
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	context.AfterFunc(r.sendCtx, cancel)
+	t := time.NewTicker(r.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.done:
+			return
+		case <-t.C:
+		}
+
+		// process while there are ready events
+		for {
+			n, err := r.ProcessBatch(ctx)
+			if err != nil {
+				r.Error(ctx, "process outbox failed", "err", err)
+			}
+			if err != nil || n == 0 {
+				break
+			}
+		}
+
+		r.updateLag(ctx)
+	}
+}
+
+// Stop stops relay and waits for current batch until ctx is done, then batch is canceled. It is safe to call Stop
+// several times.
+func (r *Relay) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.done) })
+
+	stopped := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		r.cancelSend()
+		return ctx.Err()
+	}
+}
+
+// ProcessBatch sends one batch of pending events and returns count of processed events.
+// Events are claimed for time of sending the whole batch, they are sent without open transaction.
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	lease := time.Duration(r.cfg.BatchSize+1) * r.cfg.Timeout
+	list, err := r.repo.ClaimOutboxEvents(ctx, r.cfg.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range list {
+		r.deliver(ctx, &list[i])
+		if err = r.repo.UpdateOutboxEvent(ctx, &list[i]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(list), nil
+}
+
+// Cleanup deletes sent and dead events older than retention and returns count of deleted events.
+func (r *Relay) Cleanup(ctx context.Context) (int, error) {
+	return r.repo.DeleteOutboxEvents(ctx, time.Now().Add(-r.cfg.Retention))
+}
+
+// deliver sends event to sink with timeout and sets delivery state.
+func (r *Relay) deliver(ctx context.Context, e *db.OutboxEvent) {
+	sctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	now := time.Now()
+	e.Attempts++
+
+	err := r.sink.Send(sctx, *e)
+	if err == nil {
+		e.SentAt, e.Error = &now, nil
+		r.statEvents.WithLabelValues("sent").Inc()
+		return
+	}
+
+	msg := err.Error()
+	e.Error = &msg
+	if e.Attempts >= r.cfg.MaxAttempts {
+		e.DeadAt = &now
+		r.statEvents.WithLabelValues("dead").Inc()
+		r.Error(ctx, "outbox event is dead", "err", err, "id", e.ID, "type", e.Type, "attempts", e.Attempts)
+		return
+	}
+
+	e.NextAttemptAt = now.Add(retryDelay(e.Attempts))
+	r.statEvents.WithLabelValues("retry").Inc()
+}
+
+func (r *Relay) updateLag(ctx context.Context) {
+	lag, pending, err := r.repo.OutboxLag(ctx)
+	if err != nil {
+		r.Error(ctx, "get outbox lag failed", "err", err)
+		return
+	}
+
+	r.statLag.Set(lag.Seconds())
+	r.statPending.Set(float64(pending))
+}
+
+// retryDelay returns exponential delay for next attempt.
+func retryDelay(attempts int) time.Duration {
+	d := minRetryDelay
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"apisrv/pkg/db"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/embedlog"
+)
+
+func TestRetryDelay(t *testing.T) {
+	Convey("Test retry delay", t, func() {
+		So(retryDelay(1), ShouldEqual, minRetryDelay)
+		So(retryDelay(2), ShouldEqual, minRetryDelay*2)
+		So(retryDelay(4), ShouldEqual, minRetryDelay*8)
+		So(retryDelay(100), ShouldEqual, maxRetryDelay)
+	})
+}
+
+func TestSinks(t *testing.T) {
+	e := db.OutboxEvent{ID: 1, Aggregate: "users", AggregateID: 2, Type: "users.update", Payload: json.RawMessage(`{"id":2}`)}
+
+	Convey("Test http sink", t, func() {
+		var got db.OutboxEvent
+		status := http.StatusOK
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(status)
+		}))
+		defer srv.Close()
+
+		s := NewHTTPSink(srv.URL, time.Second)
+		So(s.Send(context.Background(), e), ShouldBeNil)
+		So(got.Type, ShouldEqual, e.Type)
+
+		status = http.StatusInternalServerError
+		So(s.Send(context.Background(), e), ShouldNotBeNil)
+	})
+
+	Convey("Test file sink", t, func() {
+		path := filepath.Join(t.TempDir(), "outbox.jsonl")
+		s := NewFileSink(path)
+		So(s.Send(context.Background(), e), ShouldBeNil)
+		So(s.Send(context.Background(), e), ShouldBeNil)
+
+		b, err := os.ReadFile(path)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldContainSubstring, `"users.update"`)
+		So(b[len(b)-1], ShouldEqual, '\n')
+	})
+
+	Convey("Test unknown sink", t, func() {
+		_, err := NewSink(Config{Sink: "kafka"}, embedlog.Logger{})
+		So(err, ShouldWrap, ErrUnknownSink)
+	})
+}
+
+func TestRelay_Stop(t *testing.T) {
+	Convey("Test relay stop", t, func() {
+		r := NewRelay(db.DB{}, embedlog.Logger{}, NewLogSink(embedlog.Logger{}), Config{Interval: time.Hour})
+		r.Run(t.Context())
+
+		So(r.Stop(t.Context()), ShouldBeNil)
+		So(r.Stop(t.Context()), ShouldBeNil)
+	})
+}
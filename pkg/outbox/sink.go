@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/vmkteam/embedlog"
+)
+
+const (
+	SinkHTTP = "http"
+	SinkFile = "file"
+	SinkLog  = "log"
+)
+
+const defaultHTTPTimeout = time.Second * 10
+
+var ErrUnknownSink = errors.New("unknown outbox sink")
+
+// Sink delivers outbox events to downstream system.
+type Sink interface {
+	Send(ctx context.Context, e db.OutboxEvent) error
+}
+
+// NewSink returns Sink by config.
+func NewSink(cfg Config, logger embedlog.Logger) (Sink, error) {
+	switch cfg.Sink {
+	case SinkHTTP:
+		return NewHTTPSink(cfg.URL, cfg.Timeout), nil
+	case SinkFile:
+		return NewFileSink(cfg.Path), nil
+	case SinkLog, "":
+		return NewLogSink(logger), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSink, cfg.Sink)
+}
+
+// HTTPSink posts events as JSON to webhook url. Any non 2xx response is an error.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPSink(url string, timeout time.Duration) *HTTPSink {
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return &HTTPSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *HTTPSink) Send(ctx context.Context, e db.OutboxEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(e.ID, 10))
+	req.Header.Set("X-Event-Type", e.Type)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// FileSink appends events as JSON lines to file. Useful for local development.
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Send(_ context.Context, e db.OutboxEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// LogSink prints events to log.
+type LogSink struct {
+	embedlog.Logger
+}
+
+func NewLogSink(logger embedlog.Logger) *LogSink {
+	return &LogSink{Logger: logger}
+}
+
+func (s *LogSink) Send(ctx context.Context, e db.OutboxEvent) error {
+	s.Print(ctx, "outbox event", "id", e.ID, "type", e.Type, "aggregateId", e.AggregateID, "payload", string(e.Payload))
+	return nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	localDirPerm  = os.ModePerm
+	localFilePerm = 0644
+)
+
+// Local stores objects as files in root directory.
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// Path returns file path of object.
+func (l *Local) Path(key string) string {
+	return filepath.Join(l.root, filepath.FromSlash(key))
+}
+
+// Put writes object to temp file and renames it, so readers never see partial files.
+func (l *Local) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) (err error) {
+	if key, err = Key(key); err != nil {
+		return err
+	}
+
+	filename := l.Path(key)
+	if err = os.MkdirAll(filepath.Dir(filename), localDirPerm); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), ".upload")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = io.Copy(f, r); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), localFilePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+// Get returns *os.File as reader, so it could be used as io.ReadSeeker.
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	obj, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, obj, err
+	}
+
+	f, err := os.Open(l.Path(obj.Key))
+	if err != nil {
+		return nil, obj, localError(err)
+	}
+
+	return f, obj, nil
+}
+
+func (l *Local) Stat(_ context.Context, key string) (Object, error) {
+	key, err := Key(key)
+	if err != nil {
+		return Object{}, err
+	}
+
+	fi, err := os.Stat(l.Path(key))
+	if err != nil {
+		return Object{}, localError(err)
+	} else if fi.IsDir() {
+		return Object{}, ErrNotFound
+	}
+
+	return Object{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		ModTime:     fi.ModTime(),
+	}, nil
+}
+
+func (l *Local) Move(_ context.Context, key, newKey string) error {
+	key, err := Key(key)
+	if err != nil {
+		return err
+	}
+	if newKey, err = Key(newKey); err != nil {
+		return err
+	}
+
+	newPath := l.Path(newKey)
+	if err = os.MkdirAll(filepath.Dir(newPath), localDirPerm); err != nil {
+		return err
+	}
+
+	return localError(os.Rename(l.Path(key), newPath))
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	key, err := Key(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(l.Path(key)); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Walk walks files in lexical order. Hidden files like temp uploads are skipped, missing prefix is empty.
+func (l *Local) Walk(ctx context.Context, prefix string, fn func(Object) error) error {
+	err := filepath.WalkDir(l.Path(prefix), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		} else if strings.HasPrefix(d.Name(), ".") && name != l.Path(prefix) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if d.IsDir() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return localError(err)
+		}
+		rel, err := filepath.Rel(l.root, name)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		return fn(Object{Key: key, Size: fi.Size(), ContentType: mime.TypeByExtension(path.Ext(key)), ModTime: fi.ModTime()})
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// PresignGet is not supported, local files are served by proxy.
+func (l *Local) PresignGet(context.Context, string, time.Duration) (string, error) {
+	return "", ErrNotSupported
+}
+
+// localError converts not exist errors to ErrNotFound.
+func localError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
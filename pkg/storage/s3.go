@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultS3Timeout = time.Minute
+
+type S3Config struct {
+	// Endpoint is an url of s3 api, e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Prefix is prepended to all object keys, e.g. "media/".
+	Prefix string
+	// PathStyle uses endpoint/bucket/key urls instead of bucket.endpoint/key, most s3-compatible servers need it.
+	PathStyle bool
+	// Timeout is a timeout of one request to s3.
+	Timeout time.Duration
+}
+
+// S3 stores objects in s3-compatible bucket. Requests are signed with AWS Signature V4.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	signer   signer
+	client   *http.Client
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3 endpoint: %w", err)
+	} else if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint: invalid url %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultS3Timeout
+	}
+
+	return &S3{
+		cfg:      cfg,
+		endpoint: u,
+		signer:   signer{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, region: cfg.Region, now: time.Now},
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// objectURL returns url of object with escaped path.
+func (s *S3) objectURL(key string) *url.URL {
+	u := s.bucketURL()
+	u.Path += s.cfg.Prefix + key
+	u.RawPath = uriEncode(u.Path, false)
+	return u
+}
+
+// bucketURL returns url of bucket, path ends with slash.
+func (s *S3) bucketURL() *url.URL {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + "/"
+	if s.cfg.PathStyle {
+		u.Path += s.cfg.Bucket + "/"
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	return &u
+}
+
+func (s *S3) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	key, err := Key(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), body)
+}
+
+// do signs and sends request. Non 2xx responses are converted to errors, 404 is ErrNotFound.
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	s.signer.sign(req)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	return nil, s3Error(req, resp.StatusCode, resp.Body)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	// s3 requires content length
+	if size < 0 {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(b), int64(len(b))
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, Object{}, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, Object{}, err
+	}
+
+	return resp.Body, newS3Object(key, resp), nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Object, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return Object{}, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return Object{}, err
+	}
+	_ = resp.Body.Close()
+
+	return newS3Object(key, resp), nil
+}
+
+// Move copies object to new key and deletes old one, s3 has no rename.
+func (s *S3) Move(ctx context.Context, key, newKey string) error {
+	key, err := Key(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, newKey, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Amz-Copy-Source", uriEncode("/"+s.cfg.Bucket+"/"+s.cfg.Prefix+key, false))
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// copy could fail after 200 OK response was sent
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	} else if bytes.Contains(b, []byte("<Error>")) {
+		return s3Error(req, resp.StatusCode, bytes.NewReader(b))
+	}
+
+	return s.Delete(ctx, key)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// PresignGet returns presigned GET url.
+func (s *S3) PresignGet(_ context.Context, key string, expires time.Duration) (string, error) {
+	key, err := Key(key)
+	if err != nil {
+		return "", err
+	}
+
+	return s.signer.presign(http.MethodGet, s.objectURL(key), expires), nil
+}
+
+// Walk lists objects by ListObjectsV2 requests, keys are returned in lexicographical order.
+func (s *S3) Walk(ctx context.Context, prefix string, fn func(Object) error) error {
+	var token string
+	for {
+		u := s.bucketURL()
+		q := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix + prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = canonicalQuery(q)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return err
+		}
+
+		var list struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&list)
+		_ = resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("s3 list: %w", err)
+		}
+
+		for _, c := range list.Contents {
+			obj := Object{Key: strings.TrimPrefix(c.Key, s.cfg.Prefix), Size: c.Size, ModTime: c.LastModified}
+			if err = fn(obj); err != nil {
+				return err
+			}
+		}
+
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			return nil
+		}
+		token = list.NextContinuationToken
+	}
+}
+
+func newS3Object(key string, resp *http.Response) Object {
+	obj := Object{Key: key, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		obj.Size = size
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.ModTime = t
+	}
+
+	return obj
+}
+
+// s3Error returns error from s3 xml error response.
+func s3Error(req *http.Request, code int, body io.Reader) error {
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	_ = xml.NewDecoder(io.LimitReader(body, 1<<16)).Decode(&e)
+
+	return fmt.Errorf("s3 %s %s: %d %s: %s", req.Method, req.URL.Path, code, e.Code, e.Message)
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+
+	ServeProxy    = "proxy"
+	ServeRedirect = "redirect"
+)
+
+const defaultPresignExpires = time.Minute * 15
+
+var (
+	ErrUnknownType  = errors.New("unknown storage type")
+	ErrNotFound     = errors.New("object not found")
+	ErrNotSupported = errors.New("not supported by storage")
+	ErrInvalidKey   = errors.New("invalid object key")
+)
+
+type Config struct {
+	// Type is a storage type: local (default) or s3.
+	Type string
+	// Serve is a media serving mode: proxy (default) or redirect to presigned url.
+	Serve string
+	// PresignExpires is a lifetime of presigned urls.
+	PresignExpires time.Duration
+	// S3 is a config for s3 storage.
+	S3 S3Config
+}
+
+// Object is a stored file info.
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage stores media files by slash separated keys, e.g. "7/0c/70c565ef460af43688b7ee6251028db9.jpg".
+type Storage interface {
+	// Put saves object from r. Size could be -1 if it is unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns object reader, it must be closed by caller.
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+	// Stat returns object info.
+	Stat(ctx context.Context, key string) (Object, error)
+	// Move renames object.
+	Move(ctx context.Context, key, newKey string) error
+	// Delete removes object, missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// Walk calls fn for objects with key prefix, e.g. "docs/". Empty prefix walks all objects.
+	Walk(ctx context.Context, prefix string, fn func(Object) error) error
+	// PresignGet returns temporary url for direct download or ErrNotSupported.
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// New returns Storage by config, root is a path of local storage.
+func New(cfg Config, root string) (Storage, error) {
+	switch cfg.Type {
+	case TypeLocal, "":
+		return NewLocal(root), nil
+	case TypeS3:
+		return NewS3(cfg.S3)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownType, cfg.Type)
+}
+
+// PresignTTL returns presigned urls lifetime from config or default value.
+func (c Config) PresignTTL() time.Duration {
+	if c.PresignExpires == 0 {
+		return defaultPresignExpires
+	}
+	return c.PresignExpires
+}
+
+// Key joins path elements to object key. It returns ErrInvalidKey for keys outside of storage root.
+func Key(elem ...string) (string, error) {
+	key := path.Join(elem...)
+	if key == "" || key == "." || path.IsAbs(key) || key == ".." || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("%w: %s", ErrInvalidKey, key)
+	}
+	return key, nil
+}
@@ -0,0 +1,113 @@
+package vt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/export"
+
+	"github.com/vmkteam/embedlog"
+)
+
+var errExportParams = errors.New("invalid export params")
+
+// exportFunc parses request params and returns function that writes entity list.
+type exportFunc func(r *http.Request) (func(ctx context.Context, w export.Writer) error, error)
+
+// ExportHandler streams VT lists as CSV or XLSX files. Entity is the last path segment, e.g. /v1/vt/export/user.
+// Query params: format (csv or xlsx), search (JSON search object of entity), sortColumn, sortDesc.
+type ExportHandler struct {
+	embedlog.Logger
+	statuses  *StatusCache
+	exporters map[string]exportFunc
+}
+
+// NewExportHandler returns export handler for VT entities.
+func NewExportHandler(dbo db.DB, logger embedlog.Logger) *ExportHandler {
+	us := NewUserService(dbo, logger)
+
+	return &ExportHandler{
+		Logger:   logger,
+		statuses: NewStatusCache(db.NewStatusRepo(dbo), logger, statusCacheTTL),
+		exporters: map[string]exportFunc{
+			NSUser: exportBy(us.export),
+		},
+	}
+}
+
+func (h *ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	entity := path.Base(r.URL.Path)
+	fn, ok := h.exporters[entity]
+	if !ok {
+		httpError(w, r, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	write, err := fn(r)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = export.FormatCSV
+	} else if format != export.FormatCSV && format != export.FormatXLSX {
+		httpError(w, r, export.ErrUnknownFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", entity, time.Now().Format("20060102-150405"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename=%q`, filename))
+
+	ew, err := export.NewWriter(format, w)
+	if err != nil {
+		h.Error(r.Context(), "export failed", "err", err, "entity", entity)
+		return
+	}
+
+	// response is already started, so errors are only logged
+	ctx := WithLanguages(WithStatuses(r.Context(), h.statuses), r.Header.Get("Accept-Language"))
+	if err = write(ctx, ew); err != nil {
+		h.Error(r.Context(), "export failed", "err", err, "entity", entity)
+		return
+	}
+
+	if err = ew.Close(); err != nil {
+		h.Error(r.Context(), "export close failed", "err", err, "entity", entity)
+	}
+}
+
+// exportBy decodes search S and ViewOps from request for fn.
+func exportBy[S any](fn func(ctx context.Context, w export.Writer, search *S, viewOps *ViewOps) error) exportFunc {
+	return func(r *http.Request) (func(ctx context.Context, w export.Writer) error, error) {
+		var search *S
+		if v := r.FormValue("search"); v != "" {
+			search = new(S)
+			if err := json.Unmarshal([]byte(v), search); err != nil {
+				return nil, fmt.Errorf("%w: search: %w", errExportParams, err)
+			}
+		}
+
+		viewOps := &ViewOps{SortColumn: r.FormValue("sortColumn")}
+		if v := r.FormValue("sortDesc"); v != "" {
+			desc, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: sortDesc: %w", errExportParams, err)
+			}
+			viewOps.SortDesc = desc
+		}
+
+		return func(ctx context.Context, w export.Writer) error {
+			return fn(ctx, w, search, viewOps)
+		}, nil
+	}
+}
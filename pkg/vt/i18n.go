@@ -0,0 +1,289 @@
+package vt
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"apisrv/pkg/export"
+	"apisrv/pkg/media"
+
+	"github.com/vmkteam/vfs"
+	"github.com/vmkteam/zenrpc/v2"
+	"golang.org/x/text/language"
+)
+
+type languageCtx string
+
+const languageKey languageCtx = "vt.languages"
+
+// messageCatalog contains localized messages of one language.
+// Fields are keyed by FieldError.Error and may contain {min} and {max} parameters,
+// Errors are keyed by zenrpc.Error or HTTP error message.
+type messageCatalog struct {
+	Fields map[string]string
+	Errors map[string]string
+}
+
+var messages = map[string]messageCatalog{
+	"ru": {
+		Fields: map[string]string{
+			FieldErrorRequired:  "Обязательное поле",
+			FieldErrorMax:       "Значение должно быть не больше {max}",
+			FieldErrorMin:       "Значение должно быть не меньше {min}",
+			FieldErrorIncorrect: "Некорректное значение",
+			FieldErrorUnique:    "Значение уже используется",
+			FieldErrorFormat:    "Неверный формат",
+			FieldErrorLen:       "Неверная длина",
+		},
+		Errors: map[string]string{
+			validationErrorMessage:                          "Ошибка валидации",
+			errInvalidLoginPassword.Message:                 "Неверный логин или пароль",
+			errJobState.Message:                             "Недопустимое состояние задачи",
+			errVfsInvalidInput.Message:                      "Некорректные данные",
+			errAuthRequired.Error():                         "Требуется авторизация",
+			errUserNotFound.Error():                         "Пользователь не найден",
+			errExportParams.Error():                         "Неверные параметры экспорта",
+			errImportParams.Error():                         "Неверные параметры импорта",
+			errImportTooLarge.Error():                       "Файл импорта слишком большой",
+			errImportCSV.Error():                            "Некорректный CSV",
+			export.ErrUnknownFormat.Error():                 "Неизвестный формат экспорта",
+			export.ErrUnknownColumn.Error():                 "Неизвестная колонка",
+			vfs.ErrInvalidNamespace.Error():                 "Неверное пространство имен",
+			vfs.ErrInvalidExtension.Error():                 "Недопустимое расширение файла",
+			vfs.ErrInvalidMimeType.Error():                  "Недопустимый тип файла",
+			media.ErrFileTooLarge.Error():                   "Размер файла превышает лимит",
+			media.ErrImageTooLarge.Error():                  "Размеры изображения превышают лимит",
+			media.ErrQuotaExceeded.Error():                  "Превышена квота хранилища",
+			media.ErrMetadataNotStripped.Error():            "Метаданные изображения не могут быть удалены",
+			media.ErrBadFolder.Error():                      "Неверная папка",
+			media.ErrTusNotFound.Error():                    "Загрузка не найдена",
+			media.ErrTusLocked.Error():                      "Загрузка уже выполняется",
+			media.ErrTusVersion.Error():                     "Неподдерживаемая версия tus",
+			media.ErrTusHeader.Error():                      "Неверный заголовок tus",
+			http.StatusText(http.StatusUnauthorized):        "Требуется авторизация",
+			http.StatusText(http.StatusForbidden):           "Доступ запрещен",
+			http.StatusText(http.StatusNotFound):            "Не найдено",
+			http.StatusText(http.StatusMethodNotAllowed):    "Метод не поддерживается",
+			http.StatusText(http.StatusConflict):            "Конфликт",
+			http.StatusText(http.StatusInternalServerError): "Внутренняя ошибка сервера",
+			http.StatusText(http.StatusNotImplemented):      "Не реализовано",
+		},
+	},
+	"en": {
+		Fields: map[string]string{
+			FieldErrorRequired:  "Field is required",
+			FieldErrorMax:       "Value must be at most {max}",
+			FieldErrorMin:       "Value must be at least {min}",
+			FieldErrorIncorrect: "Incorrect value",
+			FieldErrorUnique:    "Value is already taken",
+			FieldErrorFormat:    "Invalid format",
+			FieldErrorLen:       "Invalid length",
+		},
+		Errors: map[string]string{
+			validationErrorMessage:               "Validation error",
+			errInvalidLoginPassword.Message:      "Invalid login or password",
+			errJobState.Message:                  "Invalid job state",
+			errVfsInvalidInput.Message:           "Invalid user input",
+			errAuthRequired.Error():              "Authorization required",
+			errUserNotFound.Error():              "User not found",
+			errExportParams.Error():              "Invalid export params",
+			errImportParams.Error():              "Invalid import params",
+			errImportTooLarge.Error():            "Import file is too large",
+			errImportCSV.Error():                 "Invalid CSV",
+			export.ErrUnknownFormat.Error():      "Unknown export format",
+			export.ErrUnknownColumn.Error():      "Unknown column",
+			vfs.ErrInvalidNamespace.Error():      "Invalid namespace",
+			vfs.ErrInvalidExtension.Error():      "Invalid file extension",
+			vfs.ErrInvalidMimeType.Error():       "Invalid file type",
+			media.ErrFileTooLarge.Error():        "File size exceeds limit",
+			media.ErrImageTooLarge.Error():       "Image dimensions exceed limit",
+			media.ErrQuotaExceeded.Error():       "Storage quota exceeded",
+			media.ErrMetadataNotStripped.Error(): "Image metadata can't be stripped",
+			media.ErrBadFolder.Error():           "Invalid folder",
+			media.ErrTusNotFound.Error():         "Upload not found",
+			media.ErrTusLocked.Error():           "Upload is in progress",
+			media.ErrTusVersion.Error():          "Unsupported tus version",
+			media.ErrTusHeader.Error():           "Invalid tus header",
+		},
+	},
+}
+
+// WithLanguages returns context with preferred languages from Accept-Language header value.
+func WithLanguages(ctx context.Context, acceptLanguage string) context.Context {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return ctx
+	}
+
+	langs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if base, c := tag.Base(); c != language.No && !slices.Contains(langs, base.String()) {
+			langs = append(langs, base.String())
+		}
+	}
+
+	return context.WithValue(ctx, languageKey, langs)
+}
+
+// LanguagesFromContext returns preferred languages of request.
+func LanguagesFromContext(ctx context.Context) []string {
+	langs, _ := ctx.Value(languageKey).([]string)
+	return langs
+}
+
+// Language returns the first preferred language of request that has messages,
+// or empty string if there is no such language and original messages are used.
+func Language(ctx context.Context) string {
+	for _, lang := range LanguagesFromContext(ctx) {
+		if _, ok := messages[lang]; ok {
+			return lang
+		}
+	}
+	return ""
+}
+
+// languageMiddleware sets request languages from Accept-Language header and localizes error messages.
+func languageMiddleware() zenrpc.MiddlewareFunc {
+	return func(h zenrpc.InvokeFunc) zenrpc.InvokeFunc {
+		return func(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+			if req, ok := zenrpc.RequestFromContext(ctx); ok {
+				ctx = WithLanguages(ctx, req.Header.Get("Accept-Language"))
+			}
+
+			resp := h(ctx, method, params)
+			if resp.Error != nil {
+				resp.Error = localizeError(ctx, resp.Error)
+			}
+			return resp
+		}
+	}
+}
+
+// localizeError returns copy of error with localized message, validation errors get localized field messages.
+// Errors are shared package variables, so they are never modified. Without supported language error is returned as is.
+func localizeError(ctx context.Context, e *zenrpc.Error) *zenrpc.Error {
+	if Language(ctx) == "" {
+		return e
+	}
+
+	le := *e
+	le.Message = localizeMessage(ctx, e.Message)
+	if fields, ok := e.Data.([]FieldError); ok {
+		le.Data = localizeFields(ctx, fields)
+	}
+	return &le
+}
+
+// localizeMessage returns localized error message. Wrapped error like "invalid import params: chunkSize" is localized
+// by the longest known message before colon. Without supported language or known message it is returned as is.
+func localizeMessage(ctx context.Context, msg string) string {
+	lang := Language(ctx)
+	if lang == "" {
+		return msg
+	}
+
+	catalog := messages[lang].Errors
+	if m, ok := catalog[msg]; ok {
+		return m
+	}
+
+	var prefix string
+	for known := range catalog {
+		if len(known) > len(prefix) && strings.HasPrefix(msg, known+": ") {
+			prefix = known
+		}
+	}
+	if prefix == "" {
+		return msg
+	}
+	return catalog[prefix] + msg[len(prefix):]
+}
+
+// httpError replies with error message localized by Accept-Language header of request.
+func httpError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	ctx := WithLanguages(r.Context(), r.Header.Get("Accept-Language"))
+	http.Error(w, localizeMessage(ctx, msg), code)
+}
+
+// HTTPLocalizeMiddleware localizes plain text error replies of next handler by Accept-Language header,
+// e.g. http.Error messages of handlers outside vt.
+func HTTPLocalizeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithLanguages(r.Context(), r.Header.Get("Accept-Language"))
+		if Language(ctx) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&localizedWriter{ResponseWriter: w, ctx: ctx}, r)
+	})
+}
+
+// localizedWriter localizes body of plain text error reply, it is written by http.Error at once.
+type localizedWriter struct {
+	http.ResponseWriter
+	ctx   context.Context
+	isErr bool
+}
+
+func (w *localizedWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.isErr = true
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *localizedWriter) Write(b []byte) (int, error) {
+	if !w.isErr {
+		return w.ResponseWriter.Write(b)
+	}
+
+	msg := localizeMessage(w.ctx, strings.TrimSuffix(string(b), "\n"))
+	if _, err := io.WriteString(w.ResponseWriter, msg+"\n"); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Unwrap returns original writer for http.ResponseController.
+func (w *localizedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// localizeFields returns copy of field errors with localized messages, without supported language fields are returned as is.
+func localizeFields(ctx context.Context, fields []FieldError) []FieldError {
+	lang := Language(ctx)
+	if fields == nil || lang == "" {
+		return fields
+	}
+
+	catalog := messages[lang]
+	r := make([]FieldError, len(fields))
+	for i, fe := range fields {
+		fe.Message = fieldMessage(catalog, fe)
+		r[i] = fe
+	}
+	return r
+}
+
+// fieldMessage returns message of field error with constraint parameters, unknown errors are returned as is.
+func fieldMessage(catalog messageCatalog, fe FieldError) string {
+	msg, ok := catalog.Fields[fe.Error]
+	if !ok {
+		return fe.Error
+	}
+
+	if fe.Constraint != nil {
+		msg = strings.NewReplacer(
+			"{max}", strconv.Itoa(fe.Constraint.Max),
+			"{min}", strconv.Itoa(fe.Constraint.Min),
+		).Replace(msg)
+	}
+	return msg
+}
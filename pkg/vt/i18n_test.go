@@ -0,0 +1,117 @@
+package vt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"apisrv/pkg/db/test"
+	"apisrv/pkg/media"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/zenrpc/v2"
+)
+
+func TestWithLanguages(t *testing.T) {
+	Convey("Test Accept-Language parsing", t, func() {
+		So(LanguagesFromContext(WithLanguages(t.Context(), "ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7")), ShouldResemble, []string{"ru", "en"})
+		So(LanguagesFromContext(WithLanguages(t.Context(), "en;q=0.5,de")), ShouldResemble, []string{"de", "en"})
+		So(LanguagesFromContext(WithLanguages(t.Context(), "")), ShouldBeNil)
+
+		So(Language(WithLanguages(t.Context(), "de,en;q=0.5")), ShouldEqual, "en")
+		So(Language(WithLanguages(t.Context(), "de")), ShouldBeEmpty)
+		So(Language(t.Context()), ShouldBeEmpty)
+	})
+}
+
+func TestLanguageMiddleware(t *testing.T) {
+	Convey("Test localized errors", t, func() {
+		call := func(acceptLanguage string, rpcErr *zenrpc.Error) *zenrpc.Error {
+			req := httptest.NewRequest(http.MethodPost, "/v1/rpc/", nil)
+			req.Header.Set("Accept-Language", acceptLanguage)
+
+			h := languageMiddleware()(func(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+				return zenrpc.NewResponseError(nil, rpcErr.Code, rpcErr.Message, rpcErr.Data)
+			})
+			return h(zenrpc.NewRequestContext(t.Context(), req), "", nil).Error
+		}
+
+		Convey("Validation error has localized field messages with parameters", func() {
+			var v Validator
+			v.CheckBasic(t.Context(), Circus{Name: circusNameBad})
+			v.Append("login", FieldErrorUnique)
+
+			e := call("en-US,en;q=0.9", ValidationError(v.Fields()))
+			So(e.Code, ShouldEqual, http.StatusBadRequest)
+			So(e.Message, ShouldEqual, "Validation error")
+			So(e.Data, ShouldResemble, []FieldError{
+				{Field: "name", Error: FieldErrorMax, Constraint: &FieldErrorConstraint{Max: 16}, Message: "Value must be at most 16"},
+				{Field: "animals", Error: FieldErrorRequired, Message: "Field is required"},
+				{Field: "login", Error: FieldErrorUnique, Message: "Value is already taken"},
+			})
+
+			e = call("ru", ValidationError(v.Fields()))
+			So(e.Message, ShouldEqual, "Ошибка валидации")
+			So(e.Data.([]FieldError)[0].Message, ShouldEqual, "Значение должно быть не больше 16")
+
+			e = call("", ValidationError(v.Fields()))
+			So(e.Message, ShouldEqual, validationErrorMessage)
+			So(e.Data, ShouldResemble, v.Fields())
+
+			// source errors are not changed
+			So(v.Fields()[0].Message, ShouldBeEmpty)
+		})
+
+		Convey("Error messages are localized, unknown messages are kept", func() {
+			So(call("ru", errInvalidLoginPassword).Message, ShouldEqual, "Неверный логин или пароль")
+			So(call("en", errInvalidLoginPassword).Message, ShouldEqual, "Invalid login or password")
+			So(call("ru", ErrUnauthorized).Message, ShouldEqual, "Требуется авторизация")
+			So(call("en", ErrUnauthorized).Message, ShouldEqual, http.StatusText(http.StatusUnauthorized))
+			So(call("ru", InternalError(context.Canceled)).Message, ShouldEqual, context.Canceled.Error())
+			So(call("", errInvalidLoginPassword).Message, ShouldEqual, errInvalidLoginPassword.Message)
+
+			So(errInvalidLoginPassword.Message, ShouldEqual, "invalid login or password")
+		})
+	})
+}
+
+func TestHTTPLocalizeMiddleware(t *testing.T) {
+	Convey("Test localized http errors", t, func() {
+		serve := func(h http.Handler, acceptLanguage string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Language", acceptLanguage)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+
+		Convey("Wrapped errors are localized by known prefix", func() {
+			ctx := WithLanguages(t.Context(), "ru")
+			So(localizeMessage(ctx, errImportParams.Error()+": chunkSize"), ShouldEqual, "Неверные параметры импорта: chunkSize")
+			So(localizeMessage(ctx, media.ErrFileTooLarge.Error()+": 5 bytes"), ShouldEqual, "Размер файла превышает лимит: 5 bytes")
+			So(localizeMessage(ctx, "unknown error: x"), ShouldEqual, "unknown error: x")
+			So(localizeMessage(t.Context(), errUserNotFound.Error()), ShouldEqual, errUserNotFound.Error())
+		})
+
+		Convey("Plain text errors of handler are localized", func() {
+			h := HTTPLocalizeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, media.ErrTusLocked.Error(), http.StatusLocked)
+			}))
+
+			w := serve(h, "ru")
+			So(w.Code, ShouldEqual, http.StatusLocked)
+			So(w.Body.String(), ShouldEqual, "Загрузка уже выполняется\n")
+			So(serve(h, "").Body.String(), ShouldEqual, media.ErrTusLocked.Error()+"\n")
+		})
+
+		Convey("Auth errors are localized", func() {
+			h := HTTPAuthMiddleware(test.NewUserRepo(), http.NotFoundHandler())
+			w := serve(h, "ru")
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			So(w.Body.String(), ShouldEqual, "Требуется авторизация\n")
+			So(serve(h, "en").Body.String(), ShouldEqual, "Authorization required\n")
+		})
+	})
+}
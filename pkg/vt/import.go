@@ -0,0 +1,293 @@
+package vt
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/export"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/vmkteam/embedlog"
+)
+
+const (
+	maxImportSize          = 10 << 20 // 10MB
+	defaultImportChunkSize = 100
+)
+
+var (
+	errImportParams   = errors.New("invalid import params")
+	errImportTooLarge = errors.New("import file is too large")
+	errImportCSV      = errors.New("invalid csv")
+)
+
+type ImportOptions struct {
+	// DryRun only validates rows.
+	DryRun bool
+	// Chunked imports valid rows by chunks in separate transactions and skips invalid rows.
+	// Otherwise all rows are imported in one transaction and only if every row is valid.
+	Chunked bool
+	// ChunkSize is a count of rows in one transaction for chunked import.
+	ChunkSize int
+}
+
+type ImportRowError struct {
+	Line   int          `json:"line"`
+	Errors []FieldError `json:"errors"`
+}
+
+// ImportFailure is an error that stopped import. Rows of chunks before it are imported and counted in report.
+type ImportFailure struct {
+	Line  int    `json:"line,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun   bool             `json:"dryRun"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+	Failure  *ImportFailure   `json:"failure,omitempty"`
+}
+
+// importLineError is an error of row that stopped import.
+type importLineError struct {
+	line int
+	err  error
+}
+
+func (e importLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (e importLineError) Unwrap() error {
+	return e.err
+}
+
+// importFunc imports entity list from CSV. Report of rows processed before error is returned with it.
+type importFunc func(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error)
+
+// ImportHandler imports VT entities from CSV file. Entity is the last path segment, e.g. /v1/vt/import/user.
+// File is sent as multipart form field "file" or as request body.
+// Query params: dryRun, chunked, chunkSize, see ImportOptions. Response is ImportReport.
+type ImportHandler struct {
+	embedlog.Logger
+	statuses  *StatusCache
+	importers map[string]importFunc
+}
+
+// NewImportHandler returns import handler for VT entities.
+func NewImportHandler(dbo db.DB, logger embedlog.Logger, dbOpts ...db.RepoOption) *ImportHandler {
+	us := NewUserService(dbo, logger, dbOpts...)
+
+	return &ImportHandler{
+		Logger:   logger,
+		statuses: NewStatusCache(db.NewStatusRepo(dbo), logger, statusCacheTTL),
+		importers: map[string]importFunc{
+			NSUser: func(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+				return us.importCSV(WithValidationDB(ctx, db.NewExistsRepo(dbo)), dbo, r, opts)
+			},
+		},
+	}
+}
+
+func (h *ImportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	entity := path.Base(r.URL.Path)
+	fn, ok := h.importers[entity]
+	if !ok {
+		httpError(w, r, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	opts, err := importOptions(r)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		f, _, er := r.FormFile("file")
+		if er != nil {
+			httpError(w, r, er.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		file = f
+	}
+
+	ctx := WithLanguages(WithStatuses(r.Context(), h.statuses), r.Header.Get("Accept-Language"))
+	report, err := fn(ctx, file, opts)
+	status := http.StatusOK
+	if err != nil {
+		var failure *ImportFailure
+		if status, failure = h.importFailure(ctx, entity, err); report == nil {
+			httpError(w, r, failure.Error, status)
+			return
+		}
+		failure.Error = localizeMessage(ctx, failure.Error)
+		report.Failure = failure
+	}
+
+	for i := range report.Errors {
+		report.Errors[i].Errors = localizeFields(ctx, report.Errors[i].Errors)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err = json.NewEncoder(w).Encode(report); err != nil {
+		h.Error(r.Context(), "import response failed", "err", err, "entity", entity)
+	}
+}
+
+// importFailure returns http status and failure of import error, internal errors are logged and not shown.
+func (h *ImportHandler) importFailure(ctx context.Context, entity string, err error) (int, *ImportFailure) {
+	var maxErr *http.MaxBytesError
+	var csvErr *csv.ParseError
+	var lineErr importLineError
+
+	failure := &ImportFailure{Error: err.Error()}
+	if errors.As(err, &csvErr) {
+		failure.Line = csvErr.Line
+	} else if errors.As(err, &lineErr) {
+		failure.Line = lineErr.line
+	}
+
+	switch {
+	case errors.As(err, &maxErr):
+		failure.Error = fmt.Sprintf("%v: %v bytes", errImportTooLarge, maxErr.Limit)
+		return http.StatusBadRequest, failure
+	case csvErr != nil:
+		failure.Error = fmt.Sprintf("%v: %v", errImportCSV, csvErr.Err)
+		return http.StatusBadRequest, failure
+	case errors.Is(err, io.EOF):
+		failure.Error = fmt.Sprintf("%v: %v", errImportCSV, err)
+		return http.StatusBadRequest, failure
+	case errors.Is(err, export.ErrUnknownColumn):
+		return http.StatusBadRequest, failure
+	default:
+		h.Error(ctx, "import failed", "err", err, "entity", entity)
+		failure.Error = http.StatusText(http.StatusInternalServerError)
+		return http.StatusInternalServerError, failure
+	}
+}
+
+// importOptions parses ImportOptions from query params.
+func importOptions(r *http.Request) (opts ImportOptions, err error) {
+	q := r.URL.Query()
+	for name, v := range map[string]*bool{"dryRun": &opts.DryRun, "chunked": &opts.Chunked} {
+		if s := q.Get(name); s != "" {
+			if *v, err = strconv.ParseBool(s); err != nil {
+				return opts, fmt.Errorf("%w: %s", errImportParams, name)
+			}
+		}
+	}
+
+	opts.ChunkSize = defaultImportChunkSize
+	if s := q.Get("chunkSize"); s != "" {
+		if opts.ChunkSize, err = strconv.Atoi(s); err != nil || opts.ChunkSize < 1 {
+			return opts, fmt.Errorf("%w: chunkSize", errImportParams)
+		}
+	}
+
+	return opts, nil
+}
+
+// txRunner runs function in transaction, it is implemented by db.DB.
+type txRunner interface {
+	RunInTransaction(ctx context.Context, fn func(*pg.Tx) error) error
+}
+
+// importer validates and adds rows of T read from CSV.
+type importer[T any] struct {
+	dbo    txRunner
+	titles map[string]string
+	// validate returns validation result of row.
+	validate func(ctx context.Context, obj *T) Validator
+	// add adds valid row in transaction.
+	add func(ctx context.Context, tx *pg.Tx, obj *T) error
+}
+
+type importRow[T any] struct {
+	line int
+	obj  *T
+}
+
+// run reads and validates all rows, then imports valid rows according to opts. On error report contains rows
+// processed before it.
+func (im importer[T]) run(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	report := &ImportReport{DryRun: opts.DryRun, Errors: []ImportRowError{}}
+	var rows []importRow[T]
+
+	err := export.ReadCSV(r, im.titles, func(line int, obj *T, errs map[string]error) error {
+		report.Total++
+
+		var fields []FieldError
+		for name := range errs {
+			fields = append(fields, FieldError{Field: name, Error: FieldErrorFormat})
+		}
+
+		v := im.validate(ctx, obj)
+		if v.HasInternalError() {
+			return importLineError{line: line, err: v.err}
+		}
+
+		// parse errors are more precise than validation errors of zero values
+		for _, fe := range v.Fields() {
+			if _, ok := errs[fe.Field]; !ok {
+				fields = append(fields, fe)
+			}
+		}
+
+		if len(fields) > 0 {
+			slices.SortFunc(fields, func(a, b FieldError) int { return strings.Compare(a.Field, b.Field) })
+			report.Errors = append(report.Errors, ImportRowError{Line: line, Errors: fields})
+			return nil
+		}
+
+		rows = append(rows, importRow[T]{line: line, obj: obj})
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.Valid = len(rows)
+	if opts.DryRun || (!opts.Chunked && len(report.Errors) > 0) {
+		return report, nil
+	}
+
+	size := len(rows)
+	if opts.Chunked {
+		size = opts.ChunkSize
+	}
+
+	for chunk := range slices.Chunk(rows, max(size, 1)) {
+		err = im.dbo.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			for _, row := range chunk {
+				if er := im.add(ctx, tx, row.obj); er != nil {
+					return importLineError{line: row.line, err: er}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+		report.Imported += len(chunk)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,100 @@
+package vt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apisrv/pkg/db/test"
+
+	"github.com/go-pg/pg/v10"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestImporter_run(t *testing.T) {
+	Convey("Test import dry run", t, func() {
+		im := importer[User]{
+			titles: userImportTitles,
+			validate: func(ctx context.Context, user *User) Validator {
+				var v Validator
+				v.CheckBasic(ctx, *user)
+				return v
+			},
+		}
+
+		ctx := WithValidationDB(t.Context(), test.NewExistsRepo(test.NewUserRepo(), test.NewStatusRepo(defaultStatuses...)))
+		data := "Логин,Пароль,ID статуса\nadmin,secret,1\n,secret,1\nuser,secret,x\n"
+		report, err := im.run(ctx, strings.NewReader(data), ImportOptions{DryRun: true})
+		So(err, ShouldBeNil)
+		So(report.Total, ShouldEqual, 3)
+		So(report.Valid, ShouldEqual, 1)
+		So(report.Imported, ShouldEqual, 0)
+		So(report.Errors, ShouldResemble, []ImportRowError{
+			{Line: 3, Errors: []FieldError{{Field: "login", Error: FieldErrorRequired}}},
+			{Line: 4, Errors: []FieldError{{Field: "statusId", Error: FieldErrorFormat}}},
+		})
+
+		Convey("Atomic import with errors doesn't add rows", func() {
+			report, err = im.run(ctx, strings.NewReader(data), ImportOptions{})
+			So(err, ShouldBeNil)
+			So(report.Imported, ShouldEqual, 0)
+		})
+
+		Convey("Failed chunk returns report with imported rows and failed line", func() {
+			errAdd := errors.New("add failed")
+			im.dbo = fakeTxRunner{}
+			im.add = func(_ context.Context, _ *pg.Tx, user *User) error {
+				if user.Login == "user3" {
+					return errAdd
+				}
+				return nil
+			}
+
+			data := "Логин,Пароль,ID статуса\nuser1,secret,1\nuser2,secret,1\nuser3,secret,1\n"
+			report, err = im.run(ctx, strings.NewReader(data), ImportOptions{Chunked: true, ChunkSize: 2})
+			So(err, ShouldWrap, errAdd)
+			So(report.Imported, ShouldEqual, 2)
+
+			h := &ImportHandler{}
+			status, failure := h.importFailure(ctx, NSUser, err)
+			So(status, ShouldEqual, http.StatusInternalServerError)
+			So(failure, ShouldResemble, &ImportFailure{Line: 4, Error: http.StatusText(http.StatusInternalServerError)})
+		})
+
+		Convey("CSV error returns report with read rows and failed line", func() {
+			report, err = im.run(ctx, strings.NewReader("Логин,Пароль,ID статуса\nuser1,secret,1\nuser2,\"secret,1\n"), ImportOptions{})
+			So(err, ShouldNotBeNil)
+			So(report.Total, ShouldEqual, 1)
+
+			h := &ImportHandler{}
+			status, failure := h.importFailure(ctx, NSUser, err)
+			So(status, ShouldEqual, http.StatusBadRequest)
+			So(failure.Line, ShouldEqual, 3)
+		})
+	})
+}
+
+// fakeTxRunner runs function without transaction.
+type fakeTxRunner struct{}
+
+func (fakeTxRunner) RunInTransaction(_ context.Context, fn func(*pg.Tx) error) error {
+	return fn(nil)
+}
+
+func TestImportOptions(t *testing.T) {
+	Convey("Test import options", t, func() {
+		opts, err := importOptions(httptest.NewRequest(http.MethodPost, "/v1/vt/import/user?dryRun=true&chunked=1&chunkSize=10", nil))
+		So(err, ShouldBeNil)
+		So(opts, ShouldResemble, ImportOptions{DryRun: true, Chunked: true, ChunkSize: 10})
+
+		opts, err = importOptions(httptest.NewRequest(http.MethodPost, "/v1/vt/import/user", nil))
+		So(err, ShouldBeNil)
+		So(opts.ChunkSize, ShouldEqual, defaultImportChunkSize)
+
+		_, err = importOptions(httptest.NewRequest(http.MethodPost, "/v1/vt/import/user?chunkSize=0", nil))
+		So(err, ShouldWrap, errImportParams)
+	})
+}
@@ -0,0 +1,202 @@
+package vt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/vmkteam/embedlog"
+	"github.com/vmkteam/zenrpc/v2"
+)
+
+var errJobState = zenrpc.NewStringError(http.StatusBadRequest, "invalid job state")
+
+type Job struct {
+	ID          int             `json:"id"`
+	Kind        string          `json:"kind"`
+	Args        json.RawMessage `json:"args"`
+	State       string          `json:"state"`
+	UniqueKey   *string         `json:"uniqueKey"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	RunAt       time.Time       `json:"runAt"`
+	LockedAt    *time.Time      `json:"lockedAt"`
+	FinishedAt  *time.Time      `json:"finishedAt"`
+	Error       *string         `json:"error"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+type JobSearch struct {
+	ID        *int       `json:"id"`
+	Kind      *string    `json:"kind"`
+	State     *string    `json:"state"`
+	UniqueKey *string    `json:"uniqueKey"`
+	RunAtFrom *time.Time `json:"runAtFrom"`
+	RunAtTo   *time.Time `json:"runAtTo"`
+	IDs       []int      `json:"ids"`
+}
+
+func (js *JobSearch) ToDB() *db.JobSearch {
+	if js == nil {
+		return nil
+	}
+
+	return &db.JobSearch{
+		ID:        js.ID,
+		Kind:      js.Kind,
+		State:     js.State,
+		UniqueKey: js.UniqueKey,
+		RunAtFrom: js.RunAtFrom,
+		RunAtTo:   js.RunAtTo,
+		IDs:       js.IDs,
+	}
+}
+
+func NewJob(in *db.Job) *Job {
+	if in == nil {
+		return nil
+	}
+
+	return &Job{
+		ID:          in.ID,
+		Kind:        in.Kind,
+		Args:        in.Args,
+		State:       in.State,
+		UniqueKey:   in.UniqueKey,
+		Attempts:    in.Attempts,
+		MaxAttempts: in.MaxAttempts,
+		RunAt:       in.RunAt,
+		LockedAt:    in.LockedAt,
+		FinishedAt:  in.FinishedAt,
+		Error:       in.Error,
+		CreatedAt:   in.CreatedAt,
+	}
+}
+
+type JobService struct {
+	zenrpc.Service
+	embedlog.Logger
+
+	jobRepo db.JobRepository
+}
+
+func NewJobService(dbo db.DB, logger embedlog.Logger) *JobService {
+	return &JobService{
+		jobRepo: db.NewJobRepo(dbo),
+		Logger:  logger,
+	}
+}
+
+func (s JobService) dbSort(ops *ViewOps) db.OpFunc {
+	v := s.jobRepo.DefaultJobSort()
+	if ops == nil {
+		return v
+	}
+
+	switch ops.SortColumn {
+	case db.Jobs.Columns.ID, db.Jobs.Columns.Kind, db.Jobs.Columns.State, db.Jobs.Columns.RunAt, db.Jobs.Columns.CreatedAt:
+		v = db.WithSort(db.NewSortField(ops.SortColumn, ops.SortDesc))
+	}
+
+	return v
+}
+
+// Count Jobs according to conditions in search params
+//
+//zenrpc:search JobSearch
+//zenrpc:return int
+//zenrpc:500 Internal Error
+func (s JobService) Count(ctx context.Context, search *JobSearch) (int, error) {
+	count, err := s.jobRepo.CountJobs(ctx, search.ToDB())
+	if err != nil {
+		return 0, InternalError(err)
+	}
+	return count, nil
+}
+
+// Get а list of Jobs according to conditions in search params
+//
+//zenrpc:search JobSearch
+//zenrpc:viewOps ViewOps
+//zenrpc:return []Job
+//zenrpc:500 Internal Error
+func (s JobService) Get(ctx context.Context, search *JobSearch, viewOps *ViewOps) ([]Job, error) {
+	list, err := s.jobRepo.JobsByFilters(ctx, search.ToDB(), viewOps.Pager(), s.dbSort(viewOps))
+	if err != nil {
+		return nil, InternalError(err)
+	}
+	jobs := make([]Job, 0, len(list))
+	for i := range list {
+		jobs = append(jobs, *NewJob(&list[i]))
+	}
+	return jobs, nil
+}
+
+// GetByID returns a Job by its ID.
+//
+//zenrpc:id int
+//zenrpc:return Job
+//zenrpc:500 Internal Error
+//zenrpc:404 Not Found
+func (s JobService) GetByID(ctx context.Context, id int) (*Job, error) {
+	job, err := s.byID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return NewJob(job), nil
+}
+
+func (s JobService) byID(ctx context.Context, id int) (*db.Job, error) {
+	job, err := s.jobRepo.JobByID(ctx, id)
+	if err != nil {
+		return nil, InternalError(err)
+	} else if job == nil {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+// Retry moves failed or canceled Job back to queue.
+//
+//zenrpc:id int
+//zenrpc:return isRetried
+//zenrpc:500 Internal Error
+//zenrpc:400 Invalid job state
+//zenrpc:404 Not Found
+func (s JobService) Retry(ctx context.Context, id int) (bool, error) {
+	if _, err := s.byID(ctx, id); err != nil {
+		return false, err
+	}
+
+	ok, err := s.jobRepo.RetryJob(ctx, id)
+	if err != nil {
+		return false, InternalError(err)
+	} else if !ok {
+		return false, errJobState
+	}
+	return true, nil
+}
+
+// Cancel cancels pending or running Job. Running handler is not interrupted, but its result is discarded.
+//
+//zenrpc:id int
+//zenrpc:return isCanceled
+//zenrpc:500 Internal Error
+//zenrpc:400 Invalid job state
+//zenrpc:404 Not Found
+func (s JobService) Cancel(ctx context.Context, id int) (bool, error) {
+	if _, err := s.byID(ctx, id); err != nil {
+		return false, err
+	}
+
+	ok, err := s.jobRepo.CancelJob(ctx, id)
+	if err != nil {
+		return false, InternalError(err)
+	} else if !ok {
+		return false, errJobState
+	}
+	return true, nil
+}
@@ -0,0 +1,52 @@
+package vt
+
+import (
+	"testing"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/db/test"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJobService(t *testing.T) {
+	Convey("Test JobService with in-memory repository", t, func() {
+		ctx := t.Context()
+		srv := &JobService{jobRepo: test.NewJobRepo(
+			db.Job{Kind: "email", State: db.JobStatePending},
+			db.Job{Kind: "email", State: db.JobStateFailed},
+			db.Job{Kind: "report", State: db.JobStateDone},
+		)}
+
+		Convey("Count by kind", func() {
+			count, err := srv.Count(ctx, &JobSearch{Kind: test.Ptr("email")})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 2)
+		})
+
+		Convey("Retry failed job", func() {
+			ok, err := srv.Retry(ctx, 2)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			job, err := srv.GetByID(ctx, 2)
+			So(err, ShouldBeNil)
+			So(job.State, ShouldEqual, db.JobStatePending)
+
+			_, err = srv.Retry(ctx, 1)
+			So(err, ShouldEqual, errJobState)
+		})
+
+		Convey("Cancel pending job only", func() {
+			ok, err := srv.Cancel(ctx, 1)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			_, err = srv.Cancel(ctx, 3)
+			So(err, ShouldEqual, errJobState)
+
+			_, err = srv.Cancel(ctx, 100)
+			So(err, ShouldEqual, ErrNotFound)
+		})
+	})
+}
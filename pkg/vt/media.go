@@ -0,0 +1,388 @@
+package vt
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/media"
+
+	"github.com/vmkteam/zenrpc/v2"
+)
+
+// Media interfaces below are implemented by media.Media.
+
+// MediaSigner mints signed urls of private media files and changes visibility of folders.
+type MediaSigner interface {
+	FileURL(ctx context.Context, fileID int, ttl time.Duration) (string, error)
+	HashURL(ctx context.Context, ns, hash, preset string, ttl time.Duration) (string, error)
+	SetFolderPrivate(ctx context.Context, folderID int, private bool) (bool, error)
+}
+
+// MediaQuota reports storage usage and manages folder quotas.
+type MediaQuota interface {
+	NamespacesUsage(ctx context.Context) (map[string]media.Usage, error)
+	FolderUsage(ctx context.Context, folderID int) (media.Usage, error)
+	SetFolderQuota(ctx context.Context, folderID int, quota *int64) (bool, error)
+}
+
+// MediaDeduplicator finds files with the same content.
+type MediaDeduplicator interface {
+	Duplicates(ctx context.Context, limit int) ([]media.Duplicate, error)
+	FileDuplicates(ctx context.Context, fileID int) ([]db.VfsFile, error)
+}
+
+// StorageUsage is a count and size of files in namespace or folder. Quota is nil if it is unlimited.
+type StorageUsage struct {
+	Namespace string `json:"namespace,omitempty"`
+	Files     int    `json:"files"`
+	Size      int64  `json:"size"`
+	Quota     *int64 `json:"quota"`
+}
+
+// VfsDuplicate is a group of files with the same content, they share one physical copy.
+type VfsDuplicate struct {
+	Hash  string           `json:"hash"`
+	Size  int64            `json:"size"`
+	Files []VfsFileSummary `json:"files"`
+}
+
+func NewVfsDuplicate(in media.Duplicate) VfsDuplicate {
+	return VfsDuplicate{Hash: in.Hash, Size: in.Size, Files: newVfsFileSummaries(in.Files)}
+}
+
+// newVfsFileSummaries converts files to summaries.
+func newVfsFileSummaries(in []db.VfsFile) []VfsFileSummary {
+	r := make([]VfsFileSummary, 0, len(in))
+	for i := range in {
+		r = append(r, *NewVfsFileSummary(&in[i]))
+	}
+	return r
+}
+
+func NewStorageUsage(ns string, in media.Usage) StorageUsage {
+	r := StorageUsage{Namespace: ns, Files: in.Files, Size: in.Size}
+	if in.Quota > 0 {
+		r.Quota = &in.Quota
+	}
+	return r
+}
+
+type MediaService struct {
+	zenrpc.Service
+	signer MediaSigner
+	quota  MediaQuota
+}
+
+func NewMediaService(signer MediaSigner, quota MediaQuota) *MediaService {
+	return &MediaService{signer: signer, quota: quota}
+}
+
+// expiresTTL converts seconds to duration, nil means default expiration.
+func expiresTTL(seconds *int) time.Duration {
+	if seconds == nil || *seconds <= 0 {
+		return 0
+	}
+	return time.Duration(*seconds) * time.Second
+}
+
+// FileURL returns signed url of vfs file.
+//
+//zenrpc:fileID file id
+//zenrpc:expiresIn lifetime of url in seconds, default from config
+//zenrpc:return string
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+func (s MediaService) FileURL(ctx context.Context, fileID int, expiresIn *int) (string, error) {
+	return s.signer.FileURL(ctx, fileID, expiresTTL(expiresIn))
+}
+
+// HashURL returns signed url of hash file or its preset image.
+//
+//zenrpc:ns hash namespace
+//zenrpc:hash file hash
+//zenrpc:preset image preset, e.g. 256 or small, original file if empty
+//zenrpc:expiresIn lifetime of url in seconds, default from config
+//zenrpc:return string
+//zenrpc:400 Invalid Input
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+func (s MediaService) HashURL(ctx context.Context, ns, hash string, preset *string, expiresIn *int) (string, error) {
+	p := ""
+	if preset != nil {
+		p = *preset
+	}
+	return s.signer.HashURL(ctx, ns, hash, p, expiresTTL(expiresIn))
+}
+
+// SetFolderPrivate changes visibility of folder, files of private folders and their subfolders are served by signed urls only.
+//
+//zenrpc:folderID folder id
+//zenrpc:isPrivate folder visibility
+//zenrpc:return bool
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+func (s MediaService) SetFolderPrivate(ctx context.Context, folderID int, isPrivate bool) (bool, error) {
+	return s.signer.SetFolderPrivate(ctx, folderID, isPrivate)
+}
+
+// Usage returns storage usage of hash namespaces, public namespace is reported as default.
+//
+//zenrpc:return []StorageUsage
+//zenrpc:500 Internal Error
+//zenrpc:501 Not Implemented
+func (s MediaService) Usage(ctx context.Context) ([]StorageUsage, error) {
+	list, err := s.quota.NamespacesUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]StorageUsage, 0, len(list))
+	for ns, u := range list {
+		r = append(r, NewStorageUsage(ns, u))
+	}
+	slices.SortFunc(r, func(a, b StorageUsage) int { return strings.Compare(a.Namespace, b.Namespace) })
+
+	return r, nil
+}
+
+// FolderUsage returns storage usage of folder with subfolders and the nearest quota of folder or its parents.
+//
+//zenrpc:folderID folder id
+//zenrpc:return StorageUsage
+//zenrpc:500 Internal Error
+//zenrpc:501 Not Implemented
+func (s MediaService) FolderUsage(ctx context.Context, folderID int) (*StorageUsage, error) {
+	u, err := s.quota.FolderUsage(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewStorageUsage("", u)
+	return &r, nil
+}
+
+// SetFolderQuota sets storage quota of folder and its subfolders in bytes, empty quota removes limit.
+//
+//zenrpc:folderID folder id
+//zenrpc:quota quota in bytes
+//zenrpc:return bool
+//zenrpc:400 Invalid Input
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+func (s MediaService) SetFolderQuota(ctx context.Context, folderID int, quota *int64) (bool, error) {
+	return s.quota.SetFolderQuota(ctx, folderID, quota)
+}
+
+// vfsRootFolderID is an id of root vfs folder, it can't be changed.
+const vfsRootFolderID = 1
+
+var (
+	errVfsInvalidInput = zenrpc.NewStringError(http.StatusBadRequest, "invalid user input")
+	errVfsConflict     = httpAsRPCError(http.StatusConflict)
+)
+
+// VfsService extends vfs service with methods of apisrv media, it is registered in vfs namespace together with vfs.Service.
+// Folder methods replace methods of vfs.Service, they write folders by repository with change events.
+type VfsService struct {
+	zenrpc.Service
+	dedup   MediaDeduplicator
+	folders db.VfsFolderRepository
+}
+
+func NewVfsService(dedup MediaDeduplicator, folders db.VfsFolderRepository) *VfsService {
+	return &VfsService{dedup: dedup, folders: folders}
+}
+
+// Duplicates returns groups of files with the same content, groups which waste more space are first.
+//
+//zenrpc:limit max count of groups, default 100
+//zenrpc:return []VfsDuplicate
+//zenrpc:500 Internal Error
+//zenrpc:501 Not Implemented
+func (s VfsService) Duplicates(ctx context.Context, limit *int) ([]VfsDuplicate, error) {
+	l := 0
+	if limit != nil {
+		l = *limit
+	}
+
+	list, err := s.dedup.Duplicates(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]VfsDuplicate, 0, len(list))
+	for _, d := range list {
+		r = append(r, NewVfsDuplicate(d))
+	}
+
+	return r, nil
+}
+
+// FileDuplicates returns other files with the same content as file.
+//
+//zenrpc:fileID file id
+//zenrpc:return []VfsFileSummary
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+//zenrpc:501 Not Implemented
+func (s VfsService) FileDuplicates(ctx context.Context, fileID int) ([]VfsFileSummary, error) {
+	list, err := s.dedup.FileDuplicates(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newVfsFileSummaries(list), nil
+}
+
+// folderByID returns not deleted folder or ErrNotFound.
+func (s VfsService) folderByID(ctx context.Context, id int) (*db.VfsFolder, error) {
+	f, err := s.folders.VfsFolderByID(ctx, id)
+	if err != nil {
+		return nil, InternalError(err)
+	} else if f == nil {
+		return nil, ErrNotFound
+	}
+	return f, nil
+}
+
+// CreateFolder creates virtual folder.
+//
+//zenrpc:rootFolderId parent folder id
+//zenrpc:name folder name
+//zenrpc:return bool
+//zenrpc:400 Invalid Input
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+func (s VfsService) CreateFolder(ctx context.Context, rootFolderId int, name string) (bool, error) {
+	if name == "" {
+		return false, errVfsInvalidInput
+	}
+
+	f, err := s.folderByID(ctx, rootFolderId)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.folders.AddVfsFolder(ctx, &db.VfsFolder{ParentFolderID: &f.ID, Title: name, StatusID: db.StatusEnabled})
+	if err != nil {
+		return false, InternalError(err)
+	}
+
+	return true, nil
+}
+
+// DeleteFolder removes Folder.
+//
+//zenrpc:folderId folder id
+//zenrpc:return bool
+//zenrpc:400 Invalid Input
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+func (s VfsService) DeleteFolder(ctx context.Context, folderId int) (bool, error) {
+	if folderId == vfsRootFolderID {
+		return false, errVfsInvalidInput
+	}
+
+	f, err := s.folderByID(ctx, folderId)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.folders.DeleteVfsFolder(ctx, f.ID)
+	if err != nil {
+		return false, InternalError(err)
+	}
+	return ok, nil
+}
+
+// MoveFolder moves Folder to destination folder.
+//
+//zenrpc:folderId folder id
+//zenrpc:destinationFolderId new parent folder id
+//zenrpc:return bool
+//zenrpc:400 Invalid Input
+//zenrpc:404 Not Found
+//zenrpc:409 Conflict
+//zenrpc:500 Internal Error
+func (s VfsService) MoveFolder(ctx context.Context, folderId, destinationFolderId int) (bool, error) {
+	if folderId == vfsRootFolderID || folderId == 0 || destinationFolderId == 0 || folderId == destinationFolderId {
+		return false, errVfsInvalidInput
+	}
+
+	f, err := s.folderByID(ctx, folderId)
+	if err != nil {
+		return false, err
+	}
+	dst, err := s.folderByID(ctx, destinationFolderId)
+	if err != nil {
+		return false, err
+	}
+
+	// folder can't be moved to its subfolder
+	branch, err := s.folders.VfsFolderBranch(ctx, dst.ID)
+	if err != nil {
+		return false, InternalError(err)
+	} else if slices.ContainsFunc(branch, func(b db.VfsFolder) bool { return b.ID == f.ID }) {
+		return false, errVfsConflict
+	}
+
+	f.ParentFolderID = &dst.ID
+	return s.updateFolder(ctx, f, db.Columns.VfsFolder.ParentFolderID)
+}
+
+// RenameFolder changes Folder name.
+//
+//zenrpc:folderId folder id
+//zenrpc:name new folder name
+//zenrpc:return bool
+//zenrpc:400 Invalid Input
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+func (s VfsService) RenameFolder(ctx context.Context, folderId int, name string) (bool, error) {
+	if folderId == vfsRootFolderID || folderId == 0 || name == "" {
+		return false, errVfsInvalidInput
+	}
+
+	f, err := s.folderByID(ctx, folderId)
+	if err != nil {
+		return false, err
+	}
+
+	f.Title = name
+	return s.updateFolder(ctx, f, db.Columns.VfsFolder.Title)
+}
+
+// ManageFavorites adds Folder to favorites or removes it.
+//
+//zenrpc:folderId folder id
+//zenrpc:isInFavorites is folder favorite
+//zenrpc:return bool
+//zenrpc:400 Invalid Input
+//zenrpc:404 Not Found
+//zenrpc:500 Internal Error
+func (s VfsService) ManageFavorites(ctx context.Context, folderId int, isInFavorites bool) (bool, error) {
+	if folderId == vfsRootFolderID || folderId == 0 {
+		return false, errVfsInvalidInput
+	}
+
+	f, err := s.folderByID(ctx, folderId)
+	if err != nil {
+		return false, err
+	}
+
+	f.IsFavorite = &isInFavorites
+	return s.updateFolder(ctx, f, db.Columns.VfsFolder.IsFavorite)
+}
+
+// updateFolder updates columns of folder.
+func (s VfsService) updateFolder(ctx context.Context, f *db.VfsFolder, columns ...string) (bool, error) {
+	ok, err := s.folders.UpdateVfsFolder(ctx, f, db.WithColumns(columns...))
+	if err != nil {
+		return false, InternalError(err)
+	}
+	return ok, nil
+}
@@ -0,0 +1,72 @@
+package vt
+
+import (
+	"testing"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/db/test"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVfsService_Folders(t *testing.T) {
+	Convey("Test vfs folders with in-memory repository", t, func() {
+		ctx := t.Context()
+		repo := test.NewVfsFolderRepo(
+			db.VfsFolder{ID: 1, Title: "root", StatusID: db.StatusEnabled},
+			db.VfsFolder{ID: 2, ParentFolderID: test.Ptr(1), Title: "photos", StatusID: db.StatusEnabled},
+			db.VfsFolder{ID: 3, ParentFolderID: test.Ptr(2), Title: "2024", StatusID: db.StatusEnabled},
+		)
+		srv := NewVfsService(nil, repo)
+
+		Convey("Folder is created in parent", func() {
+			ok, err := srv.CreateFolder(ctx, 2, "2025")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			f, err := repo.VfsFolderByID(ctx, 4)
+			So(err, ShouldBeNil)
+			So(f.Title, ShouldEqual, "2025")
+			So(*f.ParentFolderID, ShouldEqual, 2)
+
+			_, err = srv.CreateFolder(ctx, 10, "new")
+			So(err, ShouldEqual, ErrNotFound)
+			_, err = srv.CreateFolder(ctx, 2, "")
+			So(err, ShouldEqual, errVfsInvalidInput)
+		})
+
+		Convey("Folder is not moved to its subfolder", func() {
+			_, err := srv.MoveFolder(ctx, 2, 3)
+			So(err, ShouldEqual, errVfsConflict)
+
+			ok, err := srv.MoveFolder(ctx, 3, 1)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			f, err := repo.VfsFolderByID(ctx, 3)
+			So(err, ShouldBeNil)
+			So(*f.ParentFolderID, ShouldEqual, 1)
+		})
+
+		Convey("Folder is renamed, marked as favorite and deleted", func() {
+			_, err := srv.RenameFolder(ctx, 2, "images")
+			So(err, ShouldBeNil)
+			_, err = srv.ManageFavorites(ctx, 2, true)
+			So(err, ShouldBeNil)
+
+			f, err := repo.VfsFolderByID(ctx, 2)
+			So(err, ShouldBeNil)
+			So(f.Title, ShouldEqual, "images")
+			So(*f.IsFavorite, ShouldBeTrue)
+
+			ok, err := srv.DeleteFolder(ctx, 2)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			_, err = srv.RenameFolder(ctx, 2, "photos")
+			So(err, ShouldEqual, ErrNotFound)
+			_, err = srv.DeleteFolder(ctx, 1)
+			So(err, ShouldEqual, errVfsInvalidInput)
+		})
+	})
+}
@@ -3,22 +3,29 @@ package vt
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"apisrv/pkg/db"
 
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/vmkteam/embedlog"
 	"github.com/vmkteam/zenrpc/v2"
 )
 
 type userCtx string
 
+var (
+	errAuthRequired = errors.New("authorization required")
+	errUserNotFound = errors.New("user not found")
+)
+
 const (
 	userKey userCtx = "vt.user"
 )
 
-func authMiddleware(commonRepo *db.CommonRepo, logger embedlog.Logger) zenrpc.MiddlewareFunc {
+func authMiddleware(commonRepo db.UserRepository, logger embedlog.Logger) zenrpc.MiddlewareFunc {
 	return func(h zenrpc.InvokeFunc) zenrpc.InvokeFunc {
 		return func(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
 			req, ok := zenrpc.RequestFromContext(ctx)
@@ -52,7 +59,17 @@ func authMiddleware(commonRepo *db.CommonRepo, logger embedlog.Logger) zenrpc.Mi
 				}
 			}
 
-			return h(context.WithValue(ctx, userKey, dbu), method, params)
+			ctx = db.WithActor(context.WithValue(ctx, userKey, dbu), dbu.ID)
+			return h(ctx, method, params)
+		}
+	}
+}
+
+// validationMiddleware adds DB for unique and exists validation tags to context.
+func validationMiddleware(dbo orm.DB) zenrpc.MiddlewareFunc {
+	return func(h zenrpc.InvokeFunc) zenrpc.InvokeFunc {
+		return func(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+			return h(WithValidationDB(ctx, db.NewExistsRepo(dbo)), method, params)
 		}
 	}
 }
@@ -65,24 +82,24 @@ func UserFromContext(ctx context.Context) *db.User {
 }
 
 // HTTPAuthMiddleware checks user from authKey header
-func HTTPAuthMiddleware(commonRepo db.CommonRepo, next http.Handler) http.Handler {
+func HTTPAuthMiddleware(commonRepo db.UserRepository, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		errCode := http.StatusUnauthorized
 
 		// return error if header is not set
 		authHeader := r.Header.Get(AuthKey)
 		if authHeader == "" {
-			http.Error(w, "authorization required", errCode)
+			httpError(w, r, errAuthRequired.Error(), errCode)
 			return
 		}
 
 		// return error if user not found
 		dbu, err := commonRepo.EnabledUserByAuthKey(r.Context(), authHeader)
 		if err != nil || dbu == nil {
-			http.Error(w, "user not found", errCode)
+			httpError(w, r, errUserNotFound.Error(), errCode)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(db.WithActor(r.Context(), dbu.ID)))
 	})
 }
@@ -0,0 +1,385 @@
+package vt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmkteam/embedlog"
+)
+
+const (
+	pushWriteWait      = time.Second * 10
+	pushPongWait       = time.Minute
+	pushPingPeriod     = pushPongWait * 9 / 10
+	pushMaxMessageSize = 4096
+	pushQueueSize      = 64
+
+	// pushAuthProtocol is a WebSocket subprotocol for auth key, browsers can't set headers for WebSocket,
+	// so client sends protocols ["vt-auth", authKey], e.g. new WebSocket(url, ["vt-auth", authKey]).
+	pushAuthProtocol = "vt-auth"
+)
+
+const (
+	// TopicSession is a topic of session events, it is delivered to user connections without subscription.
+	TopicSession = "session"
+
+	// EventLogout is pushed when auth key of connection is not valid anymore, e.g. after logout or password change.
+	// Connection is closed after this event.
+	EventLogout = "logout"
+	// EventSubscriptions is pushed after subscribe and unsubscribe actions with current topics as data.
+	EventSubscriptions = "subscriptions"
+)
+
+const (
+	PushActionSubscribe   = "subscribe"
+	PushActionUnsubscribe = "unsubscribe"
+)
+
+// PushCommand is a command sent by client. Topics are entity names (e.g. "users"), "jobs" and other push topics.
+type PushCommand struct {
+	Action string   `json:"action"`
+	Topics []string `json:"topics"`
+}
+
+// PushConfig is a config of push WebSocket connections.
+type PushConfig struct {
+	// Origins are allowed origins of browser connections in addition to the same origin, e.g. "https://admin.example.com".
+	Origins []string
+}
+
+// PushHub keeps WebSocket connections of VT users and pushes entity changes, job progress and session events.
+// Messages are received from postgres notifications, so changes made on every replica are delivered.
+type PushHub struct {
+	embedlog.Logger
+	cfg        PushConfig
+	commonRepo db.UserRepository
+	upgrader   websocket.Upgrader
+
+	mu      sync.RWMutex
+	clients map[*pushClient]struct{}
+
+	statConnections prometheus.Gauge
+	statMessages    *prometheus.CounterVec
+}
+
+// NewPushHub returns new hub. Use Listen to receive messages and ServeHTTP for client connections.
+func NewPushHub(commonRepo db.UserRepository, cfg PushConfig, logger embedlog.Logger) *PushHub {
+	h := &PushHub{
+		Logger:     logger,
+		cfg:        cfg,
+		commonRepo: commonRepo,
+		clients:    make(map[*pushClient]struct{}),
+
+		statConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "app",
+			Subsystem: "push",
+			Name:      "connections",
+			Help:      "Current count of push connections.",
+		}),
+		statMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "app",
+			Subsystem: "push",
+			Name:      "messages_total",
+			Help:      "Pushed messages by topic.",
+		}, []string{"topic"}),
+	}
+	h.upgrader = websocket.Upgrader{
+		Subprotocols: []string{pushAuthProtocol},
+		CheckOrigin:  h.checkOrigin,
+	}
+	return h
+}
+
+// RegisterMetrics registers hub metrics in prometheus.
+func (h *PushHub) RegisterMetrics() {
+	prometheus.MustRegister(h.statConnections, h.statMessages)
+}
+
+// Listen subscribes hub to entity change events and push messages. It must be called before listener Run.
+func (h *PushHub) Listen(events *db.EventListener) {
+	events.Subscribe(db.AllEntities, h.change)
+	events.Listen(db.PushChannel, h.notification)
+}
+
+// Broadcast sends message to clients subscribed to message topic.
+func (h *PushHub) Broadcast(ctx context.Context, m db.PushMessage) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		h.Error(ctx, "marshal push message failed", "err", err, "topic", m.Topic)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if c.subscribed(m.Topic) && c.send(b) {
+			h.statMessages.WithLabelValues(m.Topic).Inc()
+		}
+	}
+}
+
+// Close closes all connections.
+func (h *PushHub) Close() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		c.close()
+	}
+}
+
+// ServeHTTP authorizes user and upgrades connection to WebSocket. Auth key is read from header or from
+// pushAuthProtocol subprotocol. It is never read from url, because urls are written to access logs.
+func (h *PushHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	authKey := r.Header.Get(AuthKey)
+	if authKey == "" {
+		authKey = protocolAuthKey(websocket.Subprotocols(r))
+	}
+	if authKey == "" {
+		httpError(w, r, errAuthRequired.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	dbu, err := h.commonRepo.EnabledUserByAuthKey(r.Context(), authKey)
+	if err != nil || dbu == nil {
+		httpError(w, r, errUserNotFound.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// upgrader replies with error itself
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &pushClient{conn: conn, userID: dbu.ID, authKey: authKey, queue: make(chan []byte, pushQueueSize)}
+	h.register(c)
+	go h.write(c)
+	h.read(r.Context(), c)
+}
+
+// checkOrigin allows requests without Origin header (not browsers), from the same origin and from configured origins.
+func (h *PushHub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || slices.Contains(h.cfg.Origins, origin)
+}
+
+// protocolAuthKey returns auth key that follows pushAuthProtocol in subprotocols.
+func protocolAuthKey(protocols []string) string {
+	i := slices.Index(protocols, pushAuthProtocol)
+	if i < 0 || i+1 >= len(protocols) {
+		return ""
+	}
+	return protocols[i+1]
+}
+
+// change pushes entity change event to entity topic and checks sessions of changed user.
+func (h *PushHub) change(ctx context.Context, e db.ChangeEvent) {
+	if e.Entity == db.Tables.User.Name {
+		h.checkSessions(ctx, e.ID)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		h.Error(ctx, "marshal change event failed", "err", err)
+		return
+	}
+	h.Broadcast(ctx, db.PushMessage{Topic: e.Entity, Event: string(e.Operation), Data: b})
+}
+
+// notification pushes message from db.PushChannel.
+func (h *PushHub) notification(ctx context.Context, payload string) {
+	var m db.PushMessage
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		h.Error(ctx, "invalid push message", "err", err, "payload", payload)
+		return
+	}
+	h.Broadcast(ctx, m)
+}
+
+// checkSessions sends logout event and closes connections of user with auth keys that are not valid anymore.
+func (h *PushHub) checkSessions(ctx context.Context, userID int) {
+	h.mu.RLock()
+	var list []*pushClient
+	for c := range h.clients {
+		if c.userID == userID {
+			list = append(list, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range list {
+		dbu, err := h.commonRepo.EnabledUserByAuthKey(ctx, c.authKey)
+		if err != nil {
+			h.Error(ctx, "check push session failed", "err", err, "userId", userID)
+			continue
+		} else if dbu != nil {
+			continue
+		}
+
+		b, _ := json.Marshal(db.PushMessage{Topic: TopicSession, Event: EventLogout})
+		c.send(b)
+		c.close()
+	}
+}
+
+func (h *PushHub) register(c *pushClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+	h.statConnections.Inc()
+}
+
+func (h *PushHub) unregister(c *pushClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		h.statConnections.Dec()
+	}
+	c.close()
+}
+
+// read processes client commands until connection is closed or keepalive pong is not received.
+func (h *PushHub) read(ctx context.Context, c *pushClient) {
+	defer h.unregister(c)
+
+	c.conn.SetReadLimit(pushMaxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pushPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pushPongWait))
+	})
+
+	for {
+		_, b, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var cmd PushCommand
+		if err = json.Unmarshal(b, &cmd); err != nil {
+			h.Error(ctx, "invalid push command", "err", err, "userId", c.userID)
+			continue
+		}
+
+		if topics, ok := c.apply(cmd); ok {
+			data, _ := json.Marshal(topics)
+			b, _ = json.Marshal(db.PushMessage{Topic: TopicSession, Event: EventSubscriptions, Data: data})
+			c.send(b)
+		}
+	}
+}
+
+// write sends queued messages and keepalive pings until queue is closed or write fails.
+func (h *PushHub) write(c *pushClient) {
+	t := time.NewTicker(pushPingPeriod)
+	defer func() {
+		t.Stop()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case b, ok := <-c.queue:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(pushWriteWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, b); err != nil {
+				return
+			}
+		case <-t.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(pushWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// pushClient is a WebSocket connection of user.
+type pushClient struct {
+	conn    *websocket.Conn
+	userID  int
+	authKey string
+
+	mu     sync.Mutex
+	topics []string
+	queue  chan []byte
+	closed bool
+}
+
+// subscribed checks that client is subscribed to topic. Session topic is always delivered.
+func (c *pushClient) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return topic == TopicSession || slices.Contains(c.topics, topic)
+}
+
+// apply applies command and returns sorted topics. Unknown actions are ignored.
+func (c *pushClient) apply(cmd PushCommand) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch cmd.Action {
+	case PushActionSubscribe:
+		for _, topic := range cmd.Topics {
+			if !slices.Contains(c.topics, topic) {
+				c.topics = append(c.topics, topic)
+			}
+		}
+	case PushActionUnsubscribe:
+		c.topics = slices.DeleteFunc(c.topics, func(topic string) bool { return slices.Contains(cmd.Topics, topic) })
+	default:
+		return nil, false
+	}
+
+	slices.Sort(c.topics)
+	return slices.Clone(c.topics), true
+}
+
+// send queues message. Slow client that doesn't read messages is closed.
+func (c *pushClient) send(b []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+
+	select {
+	case c.queue <- b:
+		return true
+	default:
+		c.closed = true
+		close(c.queue)
+		return false
+	}
+}
+
+// close closes queue, so writer sends close message after queued messages.
+func (c *pushClient) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+}
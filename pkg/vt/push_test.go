@@ -0,0 +1,129 @@
+package vt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/db/test"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/embedlog"
+)
+
+func TestPushHub(t *testing.T) {
+	Convey("Test push hub", t, func() {
+		ctx := t.Context()
+		repo := test.NewUserRepo(db.User{Login: "admin", AuthKey: "key", StatusID: db.StatusEnabled})
+		hub := NewPushHub(repo, PushConfig{Origins: []string{"https://admin.example.com"}}, embedlog.Logger{})
+
+		srv := httptest.NewServer(hub)
+		t.Cleanup(srv.Close)
+		url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+		dial := func(header http.Header) *websocket.Conn {
+			conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+			So(err, ShouldBeNil)
+			_ = resp.Body.Close()
+			Reset(func() { _ = conn.Close() })
+			return conn
+		}
+
+		receive := func(conn *websocket.Conn) db.PushMessage {
+			var m db.PushMessage
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			So(conn.ReadJSON(&m), ShouldBeNil)
+			return m
+		}
+
+		// connections are registered and unregistered by server asynchronously
+		connections := func(n int) bool {
+			for range 100 {
+				hub.mu.RLock()
+				l := len(hub.clients)
+				hub.mu.RUnlock()
+				if l == n {
+					return true
+				}
+				time.Sleep(time.Millisecond * 10)
+			}
+			return false
+		}
+
+		Convey("Connection requires valid auth key", func() {
+			_, resp, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+			So(err, ShouldEqual, websocket.ErrBadHandshake)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			_ = resp.Body.Close()
+
+			_, resp, err = websocket.DefaultDialer.DialContext(ctx, url+"?"+AuthKey+"=key", nil)
+			So(err, ShouldEqual, websocket.ErrBadHandshake)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			_ = resp.Body.Close()
+
+			_, resp, err = websocket.DefaultDialer.DialContext(ctx, url, http.Header{"Sec-Websocket-Protocol": {pushAuthProtocol + ", invalid"}})
+			So(err, ShouldEqual, websocket.ErrBadHandshake)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			_ = resp.Body.Close()
+		})
+
+		Convey("Auth key is read from subprotocol", func() {
+			conn := dial(http.Header{"Sec-Websocket-Protocol": {pushAuthProtocol + ", key"}})
+			So(conn.Subprotocol(), ShouldEqual, pushAuthProtocol)
+			So(connections(1), ShouldBeTrue)
+		})
+
+		Convey("Only same and configured origins are allowed", func() {
+			dial(http.Header{AuthKey: {"key"}, "Origin": {"https://admin.example.com"}})
+			dial(http.Header{AuthKey: {"key"}, "Origin": {srv.URL}})
+
+			_, resp, err := websocket.DefaultDialer.DialContext(ctx, url, http.Header{AuthKey: {"key"}, "Origin": {"https://evil.example.com"}})
+			So(err, ShouldEqual, websocket.ErrBadHandshake)
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			_ = resp.Body.Close()
+		})
+
+		Convey("Messages are delivered to subscribed topics", func() {
+			conn := dial(http.Header{AuthKey: {"key"}})
+			So(conn.WriteJSON(PushCommand{Action: PushActionSubscribe, Topics: []string{"jobs", "users"}}), ShouldBeNil)
+			So(receive(conn), ShouldResemble, db.PushMessage{Topic: TopicSession, Event: EventSubscriptions, Data: json.RawMessage(`["jobs","users"]`)})
+
+			So(conn.WriteJSON(PushCommand{Action: PushActionUnsubscribe, Topics: []string{"jobs"}}), ShouldBeNil)
+			So(receive(conn).Data, ShouldResemble, json.RawMessage(`["users"]`))
+
+			hub.notification(ctx, `{"topic":"jobs","event":"progress","data":{"id":1}}`)
+			hub.change(ctx, db.ChangeEvent{Entity: db.Tables.VfsFolder.Name, ID: 1, Operation: db.OperationUpdate})
+			hub.change(ctx, db.ChangeEvent{Entity: db.Tables.User.Name, ID: 1, Operation: db.OperationUpdate})
+
+			m := receive(conn)
+			So(m.Topic, ShouldEqual, db.Tables.User.Name)
+			So(m.Event, ShouldEqual, string(db.OperationUpdate))
+			So(string(m.Data), ShouldEqual, `{"entity":"users","id":1,"op":"update"}`)
+		})
+
+		Convey("Logout closes connections with invalid auth key", func() {
+			conn := dial(http.Header{AuthKey: {"key"}})
+			other := dial(http.Header{AuthKey: {"key"}})
+			So(connections(2), ShouldBeTrue)
+
+			dbu, err := repo.UserByID(ctx, 1)
+			So(err, ShouldBeNil)
+			_, err = repo.AuthenticateUser(ctx, dbu, "")
+			So(err, ShouldBeNil)
+			hub.change(ctx, db.ChangeEvent{Entity: db.Tables.User.Name, ID: 1, Operation: db.OperationUpdate})
+
+			for _, c := range []*websocket.Conn{conn, other} {
+				So(receive(c), ShouldResemble, db.PushMessage{Topic: TopicSession, Event: EventLogout})
+				_, _, err = c.ReadMessage()
+				So(websocket.IsCloseError(err, websocket.CloseNormalClosure), ShouldBeTrue)
+			}
+
+			So(connections(0), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,89 @@
+package vt
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+
+	"apisrv/pkg/db"
+)
+
+type UserRevision struct {
+	Revision  int       `json:"revision"`
+	ActorID   *int      `json:"actorId"`
+	CreatedAt time.Time `json:"createdAt"`
+	User      *User     `json:"user"`
+}
+
+// FieldDiff is a change of one field between two revisions.
+type FieldDiff struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// userFromRevision decodes User from revision snapshot.
+func userFromRevision(ctx context.Context, in *db.Revision) (*User, error) {
+	var dbu db.User
+	if err := json.Unmarshal(in.Data, &dbu); err != nil {
+		return nil, err
+	}
+	return NewUser(ctx, &dbu), nil
+}
+
+func newUserRevision(ctx context.Context, in *db.Revision) (*UserRevision, error) {
+	user, err := userFromRevision(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserRevision{
+		Revision:  in.Revision,
+		ActorID:   in.ActorID,
+		CreatedAt: in.CreatedAt,
+		User:      user,
+	}, nil
+}
+
+// diffFields returns changed JSON fields of a and b sorted by name. Skipped fields are not compared.
+func diffFields(a, b any, skip ...string) ([]FieldDiff, error) {
+	va, err := jsonFields(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := jsonFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := slices.Sorted(maps.Keys(va))
+	for k := range vb {
+		if _, ok := va[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	diff := []FieldDiff{}
+	for _, k := range keys {
+		if !slices.Contains(skip, k) && !reflect.DeepEqual(va[k], vb[k]) {
+			diff = append(diff, FieldDiff{Field: k, Old: va[k], New: vb[k]})
+		}
+	}
+
+	return diff, nil
+}
+
+func jsonFields(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]any
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
@@ -17,8 +17,11 @@ const (
 )
 
 const (
-	NSAuth = "auth"
-	NSUser = "user"
+	NSAuth   = "auth"
+	NSUser   = "user"
+	NSJobs   = "jobs"
+	NSStatus = "status"
+	NSMedia  = "media"
 )
 
 var (
@@ -40,13 +43,14 @@ func httpAsRPCError(code int) *zenrpc.Error {
 }
 
 // New returns new zenrpc Server.
-func New(dbo db.DB, logger embedlog.Logger, isDevel bool) *zenrpc.Server {
+func New(dbo db.DB, logger embedlog.Logger, isDevel bool, dbOpts ...db.RepoOption) *zenrpc.Server {
 	rpc := zenrpc.NewServer(zenrpc.Options{
 		ExposeSMD: true,
 		AllowCORS: true,
 	})
 
-	commonRepo := db.NewCommonRepo(dbo)
+	commonRepo := db.NewCommonRepo(dbo, dbOpts...)
+	statuses := NewStatusCache(db.NewStatusRepo(dbo), logger, statusCacheTTL)
 
 	// middleware
 	rpc.Use(
@@ -59,13 +63,18 @@ func New(dbo db.DB, logger embedlog.Logger, isDevel bool) *zenrpc.Server {
 		zm.WithSQLLogger(dbo.DB, isDevel, allowDebugFn(), allowDebugFn()),
 		zm.WithTiming(isDevel, allowDebugFn()),
 		zm.WithSentry(zm.DefaultServerName),
-		authMiddleware(&commonRepo, logger),
+		languageMiddleware(),
+		statusMiddleware(statuses),
+		validationMiddleware(dbo),
+		authMiddleware(commonRepo, logger),
 	)
 
 	// services
 	rpc.RegisterAll(map[string]zenrpc.Invoker{
-		NSAuth: NewAuthService(dbo, logger),
-		NSUser: NewUserService(dbo, logger),
+		NSAuth:   NewAuthService(dbo, logger, dbOpts...),
+		NSUser:   NewUserService(dbo, logger, dbOpts...),
+		NSJobs:   NewJobService(dbo, logger),
+		NSStatus: NewStatusService(statuses),
 	})
 
 	return rpc
@@ -0,0 +1,159 @@
+package vt
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"apisrv/pkg/db"
+
+	"github.com/vmkteam/embedlog"
+	"github.com/vmkteam/zenrpc/v2"
+)
+
+const statusCacheTTL = time.Minute
+
+// defaultStatuses are used until statuses are loaded from DB.
+var defaultStatuses = []db.Status{
+	{ID: db.StatusEnabled, Alias: "enabled", Title: "Опубликован", Titles: map[string]string{"en": "Published"}},
+	{ID: db.StatusDisabled, Alias: "disabled", Title: "Не опубликован", Titles: map[string]string{"en": "Unpublished"}},
+	{ID: db.StatusDeleted, Alias: "deleted", Title: "Удален", Titles: map[string]string{"en": "Deleted"}},
+}
+
+// fallbackStatuses is used by NewStatus and status validator when context has no cache.
+var fallbackStatuses = NewStatusCache(nil, embedlog.Logger{}, 0)
+
+type statusCacheCtx string
+
+const statusCacheKey statusCacheCtx = "vt.statuses"
+
+// WithStatuses returns context with status cache for NewStatus and status validator.
+func WithStatuses(ctx context.Context, cache *StatusCache) context.Context {
+	return context.WithValue(ctx, statusCacheKey, cache)
+}
+
+// statusesFromContext returns status cache from context or cache with default statuses.
+func statusesFromContext(ctx context.Context) *StatusCache {
+	if c, ok := ctx.Value(statusCacheKey).(*StatusCache); ok && c != nil {
+		return c
+	}
+	return fallbackStatuses
+}
+
+// statusMiddleware puts status cache to context.
+func statusMiddleware(cache *StatusCache) zenrpc.MiddlewareFunc {
+	return func(h zenrpc.InvokeFunc) zenrpc.InvokeFunc {
+		return func(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+			return h(WithStatuses(ctx, cache), method, params)
+		}
+	}
+}
+
+// StatusCache keeps statuses loaded from DB. Statuses are reloaded on access after ttl,
+// on load error previous statuses are used.
+type StatusCache struct {
+	embedlog.Logger
+	repo db.StatusRepository
+	ttl  time.Duration
+
+	mu       sync.RWMutex
+	list     []db.Status
+	loadedAt time.Time
+}
+
+// NewStatusCache returns cache with default statuses. If repo is nil, default statuses are never reloaded.
+func NewStatusCache(repo db.StatusRepository, logger embedlog.Logger, ttl time.Duration) *StatusCache {
+	return &StatusCache{
+		Logger: logger,
+		repo:   repo,
+		ttl:    ttl,
+		list:   defaultStatuses,
+	}
+}
+
+// List returns all statuses ordered by id.
+func (c *StatusCache) List(ctx context.Context) []db.Status {
+	c.load(ctx)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list
+}
+
+// ByID returns status by id or nil.
+func (c *StatusCache) ByID(ctx context.Context, id int) *db.Status {
+	list := c.List(ctx)
+	if i := slices.IndexFunc(list, func(s db.Status) bool { return s.ID == id }); i >= 0 {
+		return &list[i]
+	}
+	return nil
+}
+
+// load reloads statuses if they are expired. Only one caller loads statuses,
+// others use previous statuses until load is done. Lock is not held during the query.
+func (c *StatusCache) load(ctx context.Context) {
+	if c.repo == nil || !c.expired() {
+		return
+	}
+
+	c.mu.Lock()
+	if time.Since(c.loadedAt) < c.ttl {
+		c.mu.Unlock()
+		return
+	}
+	c.loadedAt = time.Now()
+	c.mu.Unlock()
+
+	// statuses are shared by requests, so load is not canceled with current one
+	list, err := c.repo.Statuses(context.WithoutCancel(ctx))
+	if err != nil {
+		c.Error(ctx, "load statuses failed", "err", err)
+		return
+	}
+
+	c.mu.Lock()
+	c.list = list
+	c.mu.Unlock()
+}
+
+func (c *StatusCache) expired() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Since(c.loadedAt) >= c.ttl
+}
+
+// NewStatus returns status by id with title localized by request languages.
+func NewStatus(ctx context.Context, id int) *Status {
+	s := statusesFromContext(ctx).ByID(ctx, id)
+	if s == nil {
+		return nil
+	}
+
+	return &Status{ID: s.ID, Alias: s.Alias, Title: s.LocalizedTitle(LanguagesFromContext(ctx)...)}
+}
+
+type StatusService struct {
+	zenrpc.Service
+
+	statuses *StatusCache
+}
+
+func NewStatusService(cache *StatusCache) *StatusService {
+	return &StatusService{statuses: cache}
+}
+
+// Get returns all statuses with titles localized by Accept-Language.
+//
+//zenrpc:return []Status
+func (s StatusService) Get(ctx context.Context) ([]Status, error) {
+	list := s.statuses.List(ctx)
+	langs := LanguagesFromContext(ctx)
+
+	r := make([]Status, 0, len(list))
+	for _, st := range list {
+		r = append(r, Status{ID: st.ID, Alias: st.Alias, Title: st.LocalizedTitle(langs...)})
+	}
+	return r, nil
+}
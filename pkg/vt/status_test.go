@@ -0,0 +1,48 @@
+package vt
+
+import (
+	"testing"
+	"time"
+
+	"apisrv/pkg/db"
+	"apisrv/pkg/db/test"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmkteam/embedlog"
+)
+
+func TestStatusCache(t *testing.T) {
+	Convey("Test statuses from repository", t, func() {
+		repo := test.NewStatusRepo(append(defaultStatuses, db.Status{ID: 10, Alias: "review", Title: "На проверке", Titles: map[string]string{"en": "In review"}})...)
+		cache := NewStatusCache(repo, embedlog.Logger{}, time.Hour)
+		ctx := WithLanguages(WithStatuses(t.Context(), cache), "en-US,en;q=0.9,ru;q=0.8")
+
+		Convey("Custom status is loaded and localized", func() {
+			list, err := NewStatusService(cache).Get(ctx)
+			So(err, ShouldBeNil)
+			So(list, ShouldHaveLength, 4)
+			So(list[3], ShouldResemble, Status{ID: 10, Alias: "review", Title: "In review"})
+
+			So(NewStatus(WithStatuses(t.Context(), cache), 10).Title, ShouldEqual, "На проверке")
+			So(NewStatus(t.Context(), 10), ShouldBeNil)
+			So(NewStatus(ctx, 100), ShouldBeNil)
+		})
+
+		Convey("Status validator uses loaded statuses", func() {
+			var v Validator
+			v.CheckBasic(ctx, StatusUpdate{StatusID: 10, ObjectIDs: []int{1}})
+			So(v.Fields(), ShouldBeEmpty)
+
+			v = Validator{}
+			v.CheckBasic(ctx, StatusUpdate{StatusID: 11, ObjectIDs: []int{1}})
+			So(v.Fields(), ShouldResemble, []FieldError{{Field: "statusId", Error: FieldErrorIncorrect}})
+		})
+
+		Convey("Statuses are cached until ttl", func() {
+			So(cache.List(ctx), ShouldHaveLength, 4)
+			_, err := repo.Repo().Delete(ctx, 10)
+			So(err, ShouldBeNil)
+			So(cache.List(ctx), ShouldHaveLength, 4)
+		})
+	})
+}
@@ -3,11 +3,15 @@ package vt
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+
+	"apisrv/pkg/db"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/vmkteam/zenrpc/v2"
@@ -26,8 +30,12 @@ const (
 const (
 	CustomStatusTag = "status"
 	CustomAliasTag  = "alias"
+	CustomUniqueTag = "unique"
+	CustomExistsTag = "exists"
 
 	fieldPathSeparator = "."
+
+	validationErrorMessage = "Validation err"
 )
 
 var errorMap = map[string]string{
@@ -38,6 +46,8 @@ var errorMap = map[string]string{
 	"len":           FieldErrorLen,
 	CustomStatusTag: FieldErrorIncorrect,
 	CustomAliasTag:  FieldErrorFormat,
+	CustomUniqueTag: FieldErrorUnique,
+	CustomExistsTag: FieldErrorIncorrect,
 }
 
 var validate = newPlaygroundValidator()
@@ -53,12 +63,15 @@ func newPlaygroundValidator() *validator.Validate {
 	})
 	_ = vl.RegisterValidationCtx(CustomStatusTag, validateStatus)
 	_ = vl.RegisterValidationCtx(CustomAliasTag, validateAlias)
+	_ = vl.RegisterValidationCtx(CustomUniqueTag, validateUnique)
+	_ = vl.RegisterValidationCtx(CustomExistsTag, validateExists)
 	return vl
 }
 
-func validateStatus(_ context.Context, fl validator.FieldLevel) bool {
+// validateStatus checks that status exists, statuses are loaded from DB.
+func validateStatus(ctx context.Context, fl validator.FieldLevel) bool {
 	id := int(fl.Field().Int())
-	return NewStatus(id) != nil
+	return statusesFromContext(ctx).ByID(ctx, id) != nil
 }
 
 var aliasRegex = regexp.MustCompile(`^([0-9a-z-])+$`)
@@ -67,10 +80,84 @@ func validateAlias(_ context.Context, fl validator.FieldLevel) bool {
 	return aliasRegex.MatchString(fl.Field().String())
 }
 
+var errNoValidationDB = errors.New("no validation db in context")
+
+type validationDBCtx string
+
+const validationDBKey validationDBCtx = "vt.validationDB"
+
+// validationDB is a DB for unique and exists tags. Validation functions can't return errors,
+// so the first query error is kept for Validator.
+type validationDB struct {
+	rows db.ExistsRepository
+
+	mu  sync.Mutex
+	err error
+}
+
+func (vdb *validationDB) setError(err error) {
+	vdb.mu.Lock()
+	defer vdb.mu.Unlock()
+	if vdb.err == nil {
+		vdb.err = err
+	}
+}
+
+// WithValidationDB returns context with DB that is used by unique and exists tags. Without DB these tags fail with internal error.
+func WithValidationDB(ctx context.Context, rows db.ExistsRepository) context.Context {
+	return context.WithValue(ctx, validationDBKey, &validationDB{rows: rows})
+}
+
+// validateUnique checks that there is no other row with field value, param is "table.column", e.g. unique=users.login.
+// On update current row is excluded by ID field of struct.
+func validateUnique(ctx context.Context, fl validator.FieldLevel) bool {
+	var notID int
+	if parent := reflect.Indirect(fl.Parent()); parent.Kind() == reflect.Struct {
+		if id := parent.FieldByName("ID"); id.IsValid() && id.CanInt() {
+			notID = int(id.Int())
+		}
+	}
+
+	exists, checked := rowExists(ctx, fl, notID)
+	return !checked || !exists
+}
+
+// validateExists checks that row with field value exists, param is "table.column", e.g. exists=vfsFolders.folderId.
+func validateExists(ctx context.Context, fl validator.FieldLevel) bool {
+	exists, checked := rowExists(ctx, fl, 0)
+	return !checked || exists
+}
+
+// rowExists checks row by tag param. Zero values are not checked.
+func rowExists(ctx context.Context, fl validator.FieldLevel, notID int) (exists, checked bool) {
+	vdb, ok := ctx.Value(validationDBKey).(*validationDB)
+	if !ok || fl.Field().IsZero() {
+		return false, false
+	} else if vdb.rows == nil {
+		vdb.setError(fmt.Errorf("%w: %s=%s", errNoValidationDB, fl.GetTag(), fl.Param()))
+		return false, false
+	}
+
+	table, column, ok := strings.Cut(fl.Param(), ".")
+	if !ok {
+		vdb.setError(fmt.Errorf("invalid %s tag param: %s", fl.GetTag(), fl.Param()))
+		return false, false
+	}
+
+	exists, err := vdb.rows.RowExists(ctx, table, column, fl.Field().Interface(), notID)
+	if err != nil {
+		vdb.setError(err)
+		return false, false
+	}
+
+	return exists, true
+}
+
 type FieldError struct {
 	Field      string                `json:"field"`
 	Error      string                `json:"error"`
 	Constraint *FieldErrorConstraint `json:"constraint,omitempty"` // Help with generating an error message.
+	Message    string                `json:"message,omitempty"`    // Localized error message.
 }
 
 type FieldErrorConstraint struct {
@@ -159,8 +246,19 @@ func (v *Validator) Error() error {
 
 func (v *Validator) CheckBasic(ctx context.Context, item interface{}) {
 	v.SetInternalError(nil)
+
+	// every check has own query error, unique and exists tags fail without DB
+	vdb := &validationDB{}
+	if cur, ok := ctx.Value(validationDBKey).(*validationDB); ok {
+		vdb.rows = cur.rows
+	}
+	ctx = context.WithValue(ctx, validationDBKey, vdb)
+
 	err := validate.StructCtx(ctx, item)
-	if err == nil {
+	if vdb.err != nil {
+		v.SetInternalError(vdb.err)
+		return
+	} else if err == nil {
 		return
 	}
 
@@ -180,5 +278,5 @@ func InternalError(err error) *zenrpc.Error {
 }
 
 func ValidationError(fieldErrors []FieldError) *zenrpc.Error {
-	return &zenrpc.Error{Code: http.StatusBadRequest, Data: fieldErrors, Message: "Validation err"}
+	return &zenrpc.Error{Code: http.StatusBadRequest, Data: fieldErrors, Message: validationErrorMessage}
 }
@@ -173,6 +173,14 @@ func TestValidator_CheckBasic(t *testing.T) {
 				So(v.Fields(), ShouldHaveLength, 1)
 				So(v.Fields(), ShouldContain, FieldError{Field: "animals[0].weight", Error: "lt"})
 			})
+
+			Convey("unique tag without validation db", func() {
+				v.CheckBasic(ctx, &struct {
+					Login string `json:"login" validate:"unique=users.login"`
+				}{Login: "admin"})
+				So(v.HasInternalError(), ShouldBeTrue)
+				So(v.Error().Error(), ShouldContainSubstring, errNoValidationDB.Error())
+			})
 		})
 	})
 }
@@ -6,6 +6,22 @@ import (
 
 const maxPageSize = 500
 
+// userExportTitles are column headers of User export by JSON name.
+var userExportTitles = map[string]string{
+	"id":             "ID",
+	"createdAt":      "Дата создания",
+	"login":          "Логин",
+	"lastActivityAt": "Последняя активность",
+	"status":         "Статус",
+}
+
+// userImportTitles are column headers of User import by JSON name.
+var userImportTitles = map[string]string{
+	"login":    "Логин",
+	"password": "Пароль",
+	"statusId": "ID статуса",
+}
+
 type ViewOps struct {
 	// page number, default - 1
 	Page int `json:"page"`
@@ -37,16 +53,9 @@ type Status struct {
 	Title string `json:"title" validate:"required,max=255"`
 }
 
-func NewStatus(id int) *Status {
-	switch id {
-	case db.StatusEnabled:
-		return &Status{ID: db.StatusEnabled, Alias: "enabled", Title: "Опубликован"}
-	case db.StatusDisabled:
-		return &Status{ID: db.StatusDisabled, Alias: "disabled", Title: "Не опубликован"}
-	case db.StatusDeleted:
-		return &Status{ID: db.StatusDeleted, Alias: "deleted", Title: "Удален"}
-	}
-	return nil
+// String returns status title, it is used in exports.
+func (s Status) String() string {
+	return s.Title
 }
 
 type StatusUpdate struct {
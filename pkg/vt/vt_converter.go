@@ -1,10 +1,12 @@
 package vt
 
 import (
+	"context"
+
 	"apisrv/pkg/db"
 )
 
-func NewUser(in *db.User) *User {
+func NewUser(ctx context.Context, in *db.User) *User {
 	if in == nil {
 		return nil
 	}
@@ -15,13 +17,13 @@ func NewUser(in *db.User) *User {
 		Login:          in.Login,
 		LastActivityAt: in.LastActivityAt,
 		StatusID:       in.StatusID,
-		Status:         NewStatus(in.StatusID),
+		Status:         NewStatus(ctx, in.StatusID),
 	}
 
 	return user
 }
 
-func NewUserSummary(in *db.User) *UserSummary {
+func NewUserSummary(ctx context.Context, in *db.User) *UserSummary {
 	if in == nil {
 		return nil
 	}
@@ -31,7 +33,7 @@ func NewUserSummary(in *db.User) *UserSummary {
 		CreatedAt:      in.CreatedAt,
 		Login:          in.Login,
 		LastActivityAt: in.LastActivityAt,
-		Status:         NewStatus(in.StatusID),
+		Status:         NewStatus(ctx, in.StatusID),
 	}
 }
 
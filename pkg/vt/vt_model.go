@@ -10,10 +10,10 @@ import (
 type User struct {
 	ID             int        `json:"id"`
 	CreatedAt      time.Time  `json:"createdAt"`
-	Login          string     `json:"login" validate:"required,max=64"`
+	Login          string     `json:"login" validate:"required,max=64,unique=users.login"`
 	Password       string     `json:"password" validate:"max=64"`
 	LastActivityAt *time.Time `json:"lastActivityAt"`
-	StatusID       int        `json:"statusId" validate:"required,status"`
+	StatusID       int        `json:"statusId" validate:"required,exists=statuses.statusId"`
 
 	Status *Status `json:"status"`
 }
@@ -4,12 +4,15 @@ import (
 	"context"
 	"crypto/md5"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"time"
 
 	"apisrv/pkg/db"
+	"apisrv/pkg/export"
 
+	"github.com/go-pg/pg/v10"
 	"github.com/vmkteam/embedlog"
 	"github.com/vmkteam/zenrpc/v2"
 	"golang.org/x/crypto/bcrypt"
@@ -19,16 +22,16 @@ type AuthService struct {
 	zenrpc.Service
 	embedlog.Logger
 
-	commonRepo db.CommonRepo
+	commonRepo db.UserRepository
 }
 
 var (
 	errInvalidLoginPassword = zenrpc.NewStringError(http.StatusBadRequest, "invalid login or password")
 )
 
-func NewAuthService(dbo db.DB, logger embedlog.Logger) *AuthService {
+func NewAuthService(dbo db.DB, logger embedlog.Logger, dbOpts ...db.RepoOption) *AuthService {
 	return &AuthService{
-		commonRepo: db.NewCommonRepo(dbo),
+		commonRepo: db.NewCommonRepo(dbo, dbOpts...),
 		Logger:     logger,
 	}
 }
@@ -157,13 +160,17 @@ type UserService struct {
 	zenrpc.Service
 	embedlog.Logger
 
-	commonRepo db.CommonRepo
+	commonRepo   db.UserRepository
+	revisionRepo db.RevisionRepository
+	dbOpts       []db.RepoOption
 }
 
-func NewUserService(dbo db.DB, logger embedlog.Logger) *UserService {
+func NewUserService(dbo db.DB, logger embedlog.Logger, dbOpts ...db.RepoOption) *UserService {
 	return &UserService{
-		commonRepo: db.NewCommonRepo(dbo),
-		Logger:     logger,
+		commonRepo:   db.NewCommonRepo(dbo, dbOpts...),
+		revisionRepo: db.NewRevisionRepo(dbo),
+		dbOpts:       dbOpts,
+		Logger:       logger,
 	}
 }
 
@@ -207,13 +214,59 @@ func (s UserService) Get(ctx context.Context, search *UserSearch, viewOps *ViewO
 	}
 	users := make([]UserSummary, 0, len(list))
 	for i := range list {
-		if user := NewUserSummary(&list[i]); user != nil {
-			users = append(users, *NewUserSummary(&list[i]))
+		if user := NewUserSummary(ctx, &list[i]); user != nil {
+			users = append(users, *user)
 		}
 	}
 	return users, nil
 }
 
+// export writes list of Users according to conditions in search params without paging.
+func (s UserService) export(ctx context.Context, w export.Writer, search *UserSearch, viewOps *ViewOps) error {
+	cols := export.Columns[UserSummary](userExportTitles)
+	return export.Write(w, cols, func(row func(*UserSummary) error) error {
+		return s.commonRepo.ForEachUser(ctx, search.ToDB(), func(u *db.User) error {
+			return row(NewUserSummary(ctx, u))
+		}, s.dbSort(viewOps), s.commonRepo.FullUser())
+	})
+}
+
+// importCSV imports Users from CSV file. Logins must be unique in file too, status is enabled if not set.
+func (s UserService) importCSV(ctx context.Context, dbo db.DB, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	logins := make(map[string]struct{})
+	im := importer[User]{
+		dbo:    dbo,
+		titles: userImportTitles,
+		validate: func(ctx context.Context, user *User) Validator {
+			user.ID = 0
+			if user.StatusID == 0 {
+				user.StatusID = db.StatusEnabled
+			}
+
+			v := s.isValid(ctx, *user, false)
+			if _, ok := logins[user.Login]; ok && user.Login != "" {
+				v.Append("login", FieldErrorUnique)
+			}
+			logins[user.Login] = struct{}{}
+
+			return v
+		},
+		add: func(ctx context.Context, tx *pg.Tx, user *User) error {
+			p, err := passwordHash(user.Password)
+			if err != nil {
+				return err
+			}
+
+			u := user.ToDB()
+			u.Password = p
+			_, err = db.NewCommonRepo(tx, s.dbOpts...).AddUser(ctx, u)
+			return err
+		},
+	}
+
+	return im.run(ctx, r, opts)
+}
+
 // GetByID returns a User by its ID.
 //
 //zenrpc:id int
@@ -225,7 +278,7 @@ func (s UserService) GetByID(ctx context.Context, id int) (*User, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewUser(db), nil
+	return NewUser(ctx, db), nil
 }
 
 func (s UserService) byID(ctx context.Context, id int) (*db.User, error) {
@@ -261,7 +314,7 @@ func (s UserService) Add(ctx context.Context, user User) (*User, error) {
 	if err != nil {
 		return nil, InternalError(err)
 	}
-	return NewUser(dbc), nil
+	return NewUser(ctx, dbc), nil
 }
 
 // Update updates the User data identified by id from the query
@@ -320,6 +373,98 @@ func (s UserService) Delete(ctx context.Context, id int) (bool, error) {
 	return ok, err
 }
 
+// History returns revisions of the User, the latest first.
+//
+//zenrpc:id int
+//zenrpc:return []UserRevision
+//zenrpc:500 Internal Error
+//zenrpc:404 Not Found
+func (s UserService) History(ctx context.Context, id int) ([]UserRevision, error) {
+	if _, err := s.byID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	list, err := s.revisionRepo.RevisionsByEntity(ctx, db.Tables.User.Name, id, db.PagerNoLimit)
+	if err != nil {
+		return nil, InternalError(err)
+	}
+
+	revisions := make([]UserRevision, 0, len(list))
+	for i := range list {
+		rev, er := newUserRevision(ctx, &list[i])
+		if er != nil {
+			return nil, InternalError(er)
+		}
+		revisions = append(revisions, *rev)
+	}
+	return revisions, nil
+}
+
+// Diff returns changed fields of the User between revisions revA and revB.
+//
+//zenrpc:id int
+//zenrpc:revA int
+//zenrpc:revB int
+//zenrpc:return []FieldDiff
+//zenrpc:500 Internal Error
+//zenrpc:404 Not Found
+func (s UserService) Diff(ctx context.Context, id, revA, revB int) ([]FieldDiff, error) {
+	a, err := s.revision(ctx, id, revA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.revision(ctx, id, revB)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := diffFields(a, b, "status", "password")
+	if err != nil {
+		return nil, InternalError(err)
+	}
+	return diff, nil
+}
+
+// Revert restores the User from revision rev. Restored data is validated like in Update, password is not changed.
+//
+//zenrpc:id int
+//zenrpc:rev int
+//zenrpc:return isReverted
+//zenrpc:500 Internal Error
+//zenrpc:400 Validation Error
+//zenrpc:404 Not Found
+func (s UserService) Revert(ctx context.Context, id, rev int) (bool, error) {
+	cur, err := s.byID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	user, err := s.revision(ctx, id, rev)
+	if err != nil {
+		return false, err
+	}
+
+	// activity and secrets are not stored in revisions
+	user.ID, user.Password, user.LastActivityAt = id, "", cur.LastActivityAt
+	return s.Update(ctx, *user)
+}
+
+// revision returns User from revision rev.
+func (s UserService) revision(ctx context.Context, id, rev int) (*User, error) {
+	dbr, err := s.revisionRepo.RevisionByNumber(ctx, db.Tables.User.Name, id, rev)
+	if err != nil {
+		return nil, InternalError(err)
+	} else if dbr == nil {
+		return nil, ErrNotFound
+	}
+
+	user, err := userFromRevision(ctx, dbr)
+	if err != nil {
+		return nil, InternalError(err)
+	}
+	return user, nil
+}
+
 // Validate Verifies that User data is valid.
 //
 //zenrpc:user User
@@ -339,7 +484,7 @@ func (s UserService) Validate(ctx context.Context, user User) ([]FieldError, err
 		return nil, ve.Error()
 	}
 
-	return ve.Fields(), nil
+	return localizeFields(ctx, ve.Fields()), nil
 }
 
 func (s UserService) isValid(ctx context.Context, user User, isUpdate bool) Validator {
@@ -349,14 +494,6 @@ func (s UserService) isValid(ctx context.Context, user User, isUpdate bool) Vali
 		return v
 	}
 
-	// check login unique
-	item, err := s.commonRepo.OneUser(ctx, &db.UserSearch{Login: &user.Login, NotID: &user.ID})
-	if err != nil {
-		v.SetInternalError(err)
-	} else if item != nil {
-		v.Append("login", FieldErrorUnique)
-	}
-
 	// check empty password for add
 	if !isUpdate && user.Password == "" {
 		v.Append("password", FieldErrorRequired)
@@ -2,6 +2,7 @@ package vt
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -13,22 +14,29 @@ import (
 )
 
 func TestDB_AuthService(t *testing.T) {
+	t.Parallel()
+
 	Convey("Test AuthService", t, func() {
 		ctx := t.Context()
-		srv := NewAuthService(test.Setup(t))
-		So(srv, ShouldNotBeNil)
+		tx, l := test.SetupTx(t)
+		srv := &AuthService{commonRepo: db.NewCommonRepo(tx), Logger: l}
+
+		hash, err := passwordHash("12345")
+		So(err, ShouldBeNil)
+		user, _ := test.User(t, tx, &db.User{Password: hash}, test.WithFakeUser)
+		login := user.Login
 
 		Convey("Positive testing", func() {
 			Convey("Login method with remember password", func() {
-				authKey, err := srv.Login(ctx, "admin", "12345", true)
+				authKey, err := srv.Login(ctx, login, "12345", true)
 				So(err, ShouldBeNil)
-				authKey2, err := srv.Login(ctx, "admin", "12345", true)
+				authKey2, err := srv.Login(ctx, login, "12345", true)
 				So(err, ShouldBeNil)
 				So(authKey, ShouldEqual, authKey2)
 			})
 
 			Convey("Login without remember password", func() {
-				authKey, err := srv.Login(ctx, "admin", "12345", false)
+				authKey, err := srv.Login(ctx, login, "12345", false)
 				So(err, ShouldBeNil)
 				So(authKey, ShouldHaveLength, 32)
 
@@ -57,7 +65,7 @@ func TestDB_AuthService(t *testing.T) {
 			})
 
 			Convey("Wrong password", func() {
-				_, err := srv.Login(ctx, "admin", "admin", false)
+				_, err := srv.Login(ctx, login, "admin", false)
 				So(err, ShouldBeError)
 			})
 
@@ -82,10 +90,12 @@ func TestDB_AuthService(t *testing.T) {
 }
 
 func TestDB_UserService(t *testing.T) {
+	t.Parallel()
+
 	Convey("Test UserService", t, func() {
-		ctx := t.Context()
-		srv := NewUserService(test.Setup(t))
-		So(srv, ShouldNotBeNil)
+		tx, l := test.SetupTx(t)
+		ctx := WithValidationDB(t.Context(), db.NewExistsRepo(tx))
+		srv := &UserService{commonRepo: db.NewCommonRepo(tx), Logger: l}
 
 		Convey("Positive testing", func() {
 			Convey("Test CRUD", func() {
@@ -118,7 +128,7 @@ func TestDB_UserService(t *testing.T) {
 				So(u.Password, ShouldEqual, outUser.Password)
 
 				// Update
-				u.Login = "test"
+				u.Login = login + "_updated"
 
 				ok, err := srv.Update(ctx, *u)
 				So(err, ShouldBeNil)
@@ -159,20 +169,217 @@ func TestDB_UserService(t *testing.T) {
 			})
 
 			Convey("Create user with duplicate login", func() {
+				existing, _ := test.User(t, tx, nil, test.WithFakeUser)
 				user := User{
-					Login:     "unique",
+					Login:     existing.Login,
 					Password:  "unique2",
 					StatusID:  db.StatusEnabled,
 					CreatedAt: time.Now(),
 				}
 				u, err := srv.Add(ctx, user)
+				So(err, ShouldNotBeNil)
+				So(u, ShouldBeNil)
+
+				fields, err := srv.Validate(ctx, user)
 				So(err, ShouldBeNil)
-				So(u, ShouldNotBeNil)
+				So(fields, ShouldHaveLength, 1)
+				So(fields[0].Error, ShouldEqual, FieldErrorUnique)
+			})
 
-				u2, err := srv.Add(ctx, user)
-				So(err, ShouldNotBeNil)
-				So(u2, ShouldBeNil)
+			Convey("Login is unique except for current and deleted users", func() {
+				existing, _ := test.User(t, tx, nil, test.WithFakeUser)
+				user := User{ID: existing.ID, Login: existing.Login, StatusID: db.StatusEnabled}
+				fields, err := srv.Validate(ctx, user)
+				So(err, ShouldBeNil)
+				So(fields, ShouldBeEmpty)
+
+				ok, err := srv.Delete(ctx, existing.ID)
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+
+				user.ID, user.Password = 0, "password"
+				fields, err = srv.Validate(ctx, user)
+				So(err, ShouldBeNil)
+				So(fields, ShouldBeEmpty)
 			})
 		})
 	})
 }
+
+func TestAuthService(t *testing.T) {
+	hash, err := passwordHash("12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Test AuthService with in-memory repository", t, func() {
+		ctx := t.Context()
+		repo := test.NewUserRepo(
+			db.User{Login: "admin", Password: hash, StatusID: db.StatusEnabled},
+			db.User{Login: "disabled", Password: hash, StatusID: db.StatusDisabled},
+		)
+		srv := &AuthService{commonRepo: repo}
+
+		Convey("Login sets auth key", func() {
+			authKey, err := srv.Login(ctx, "admin", "12345", false)
+			So(err, ShouldBeNil)
+			So(authKey, ShouldHaveLength, 32)
+
+			u, err := repo.EnabledUserByAuthKey(ctx, authKey)
+			So(err, ShouldBeNil)
+			So(u.Login, ShouldEqual, "admin")
+			So(u.LastActivityAt, ShouldNotBeNil)
+
+			Convey("Logout resets auth key", func() {
+				ok, err := srv.Logout(context.WithValue(ctx, userKey, u))
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+
+				u, err = repo.EnabledUserByAuthKey(ctx, authKey)
+				So(err, ShouldBeNil)
+				So(u, ShouldBeNil)
+			})
+		})
+
+		Convey("Disabled user can't login", func() {
+			_, err := srv.Login(ctx, "disabled", "12345", false)
+			So(err, ShouldEqual, errInvalidLoginPassword)
+		})
+
+		Convey("Wrong password", func() {
+			_, err := srv.Login(ctx, "admin", "admin", false)
+			So(err, ShouldEqual, errInvalidLoginPassword)
+		})
+	})
+}
+
+func TestUserService(t *testing.T) {
+	Convey("Test UserService with in-memory repository", t, func() {
+		repo := test.NewUserRepo(
+			db.User{Login: "admin", Password: "hash", StatusID: db.StatusEnabled},
+			db.User{Login: "manager", Password: "hash", StatusID: db.StatusDisabled},
+			db.User{Login: "deleted", Password: "hash", StatusID: db.StatusDeleted},
+		)
+		ctx := WithValidationDB(t.Context(), test.NewExistsRepo(repo, test.NewStatusRepo(defaultStatuses...)))
+		srv := &UserService{commonRepo: repo}
+
+		Convey("Get honours search and pager, deleted users are hidden", func() {
+			count, err := srv.Count(ctx, nil)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 2)
+
+			list, err := srv.Get(ctx, &UserSearch{Login: test.Ptr("MAN")}, nil)
+			So(err, ShouldBeNil)
+			So(list, ShouldHaveLength, 1)
+			So(list[0].Login, ShouldEqual, "manager")
+
+			list, err = srv.Get(ctx, nil, &ViewOps{Page: 2, PageSize: 1})
+			So(err, ShouldBeNil)
+			So(list, ShouldHaveLength, 1)
+			So(list[0].Login, ShouldEqual, "manager")
+		})
+
+		Convey("Add validates login uniqueness and status", func() {
+			u, err := srv.Add(ctx, User{Login: "admin", Password: "secret", StatusID: db.StatusEnabled})
+			So(err, ShouldNotBeNil)
+			So(u, ShouldBeNil)
+
+			fields, err := srv.Validate(ctx, User{Login: "deleted", Password: "secret", StatusID: db.StatusEnabled})
+			So(err, ShouldBeNil)
+			So(fields, ShouldBeEmpty)
+
+			fields, err = srv.Validate(ctx, User{ID: 2, Login: "manager", StatusID: 100})
+			So(err, ShouldBeNil)
+			So(fields, ShouldResemble, []FieldError{{Field: "statusId", Error: FieldErrorIncorrect}})
+
+			_, err = srv.Validate(t.Context(), User{Login: "user", Password: "secret", StatusID: db.StatusEnabled})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Update keeps password and Delete hides user", func() {
+			u, err := srv.GetByID(ctx, 1)
+			So(err, ShouldBeNil)
+
+			u.Login = "root"
+			ok, err := srv.Update(ctx, *u)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			dbu, err := repo.UserByID(ctx, 1)
+			So(err, ShouldBeNil)
+			So(dbu.Login, ShouldEqual, "root")
+			So(dbu.Password, ShouldEqual, "hash")
+
+			ok, err = srv.Delete(ctx, 1)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			_, err = srv.GetByID(ctx, 1)
+			So(err, ShouldEqual, ErrNotFound)
+		})
+	})
+}
+
+func TestUserService_Revisions(t *testing.T) {
+	Convey("Test User revisions with in-memory repository", t, func() {
+		repo := test.NewUserRepo(
+			db.User{Login: "admin2", Password: "hash", StatusID: db.StatusDisabled},
+			db.User{Login: "manager", Password: "hash", StatusID: db.StatusEnabled},
+		)
+		ctx := WithValidationDB(t.Context(), test.NewExistsRepo(repo, test.NewStatusRepo(defaultStatuses...)))
+		revision := func(rev int, login string, statusID int) db.Revision {
+			b, err := json.Marshal(db.User{ID: 1, Login: login, StatusID: statusID})
+			So(err, ShouldBeNil)
+			return db.Revision{Entity: db.Tables.User.Name, EntityID: 1, Revision: rev, Data: b}
+		}
+		srv := &UserService{commonRepo: repo, revisionRepo: test.NewRevisionRepo(
+			revision(1, "admin", db.StatusEnabled),
+			revision(2, "admin2", db.StatusDisabled),
+			revision(3, "manager", db.StatusEnabled),
+		)}
+
+		Convey("History is the latest first", func() {
+			list, err := srv.History(ctx, 1)
+			So(err, ShouldBeNil)
+			So(list, ShouldHaveLength, 3)
+			So(list[0].Revision, ShouldEqual, 3)
+			So(list[2].User.Login, ShouldEqual, "admin")
+
+			_, err = srv.History(ctx, 100)
+			So(err, ShouldEqual, ErrNotFound)
+		})
+
+		Convey("Diff returns changed fields only", func() {
+			diff, err := srv.Diff(ctx, 1, 1, 2)
+			So(err, ShouldBeNil)
+			So(diff, ShouldResemble, []FieldDiff{
+				{Field: "login", Old: "admin", New: "admin2"},
+				{Field: "statusId", Old: float64(db.StatusEnabled), New: float64(db.StatusDisabled)},
+			})
+
+			_, err = srv.Diff(ctx, 1, 1, 10)
+			So(err, ShouldEqual, ErrNotFound)
+		})
+
+		Convey("Revert restores data and keeps password", func() {
+			ok, err := srv.Revert(ctx, 1, 1)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			u, err := repo.UserByID(ctx, 1)
+			So(err, ShouldBeNil)
+			So(u.Login, ShouldEqual, "admin")
+			So(u.StatusID, ShouldEqual, db.StatusEnabled)
+			So(u.Password, ShouldEqual, "hash")
+		})
+
+		Convey("Revert is validated", func() {
+			_, err := srv.Revert(ctx, 1, 3)
+			So(err, ShouldNotBeNil)
+
+			u, err := repo.UserByID(ctx, 1)
+			So(err, ShouldBeNil)
+			So(u.Login, ShouldEqual, "admin2")
+		})
+	})
+}
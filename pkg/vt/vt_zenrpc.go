@@ -11,9 +11,40 @@ import (
 )
 
 var RPC = struct {
-	AuthService struct{ Login, Logout, Profile, ChangePassword, VfsAuthToken string }
-	UserService struct{ Count, Get, GetByID, Add, Update, Delete, Validate string }
+	JobService    struct{ Count, Get, GetByID, Retry, Cancel string }
+	MediaService  struct{ FileURL, HashURL, SetFolderPrivate, Usage, FolderUsage, SetFolderQuota string }
+	VfsService    struct{ Duplicates, FileDuplicates, CreateFolder, DeleteFolder, MoveFolder, RenameFolder, ManageFavorites string }
+	StatusService struct{ Get string }
+	AuthService   struct{ Login, Logout, Profile, ChangePassword, VfsAuthToken string }
+	UserService   struct{ Count, Get, GetByID, Add, Update, Delete, History, Diff, Revert, Validate string }
 }{
+	JobService: struct{ Count, Get, GetByID, Retry, Cancel string }{
+		Count:   "count",
+		Get:     "get",
+		GetByID: "getbyid",
+		Retry:   "retry",
+		Cancel:  "cancel",
+	},
+	MediaService: struct{ FileURL, HashURL, SetFolderPrivate, Usage, FolderUsage, SetFolderQuota string }{
+		FileURL:          "fileurl",
+		HashURL:          "hashurl",
+		SetFolderPrivate: "setfolderprivate",
+		Usage:            "usage",
+		FolderUsage:      "folderusage",
+		SetFolderQuota:   "setfolderquota",
+	},
+	VfsService: struct{ Duplicates, FileDuplicates, CreateFolder, DeleteFolder, MoveFolder, RenameFolder, ManageFavorites string }{
+		Duplicates:      "duplicates",
+		FileDuplicates:  "fileduplicates",
+		CreateFolder:    "createfolder",
+		DeleteFolder:    "deletefolder",
+		MoveFolder:      "movefolder",
+		RenameFolder:    "renamefolder",
+		ManageFavorites: "managefavorites",
+	},
+	StatusService: struct{ Get string }{
+		Get: "get",
+	},
 	AuthService: struct{ Login, Logout, Profile, ChangePassword, VfsAuthToken string }{
 		Login:          "login",
 		Logout:         "logout",
@@ -21,17 +52,1209 @@ var RPC = struct {
 		ChangePassword: "changepassword",
 		VfsAuthToken:   "vfsauthtoken",
 	},
-	UserService: struct{ Count, Get, GetByID, Add, Update, Delete, Validate string }{
+	UserService: struct{ Count, Get, GetByID, Add, Update, Delete, History, Diff, Revert, Validate string }{
 		Count:    "count",
 		Get:      "get",
 		GetByID:  "getbyid",
 		Add:      "add",
 		Update:   "update",
 		Delete:   "delete",
+		History:  "history",
+		Diff:     "diff",
+		Revert:   "revert",
 		Validate: "validate",
 	},
 }
 
+func (JobService) SMD() smd.ServiceInfo {
+	return smd.ServiceInfo{
+		Methods: map[string]smd.Service{
+			"Count": {
+				Description: `Count Jobs according to conditions in search params`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "search",
+						Optional:    true,
+						Description: `JobSearch`,
+						Type:        smd.Object,
+						TypeName:    "JobSearch",
+						Properties: smd.PropertyList{
+							{
+								Name:     "id",
+								Optional: true,
+								Type:     smd.Integer,
+							},
+							{
+								Name:     "kind",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name:     "state",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name:     "uniqueKey",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name:     "runAtFrom",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name:     "runAtTo",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name: "ids",
+								Type: smd.Array,
+								Items: map[string]string{
+									"type": smd.Integer,
+								},
+							},
+						},
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `int`,
+					Type:        smd.Integer,
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+				},
+			},
+			"Get": {
+				Description: `Get а list of Jobs according to conditions in search params`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "search",
+						Optional:    true,
+						Description: `JobSearch`,
+						Type:        smd.Object,
+						TypeName:    "JobSearch",
+						Properties: smd.PropertyList{
+							{
+								Name:     "id",
+								Optional: true,
+								Type:     smd.Integer,
+							},
+							{
+								Name:     "kind",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name:     "state",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name:     "uniqueKey",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name:     "runAtFrom",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name:     "runAtTo",
+								Optional: true,
+								Type:     smd.String,
+							},
+							{
+								Name: "ids",
+								Type: smd.Array,
+								Items: map[string]string{
+									"type": smd.Integer,
+								},
+							},
+						},
+					},
+					{
+						Name:        "viewOps",
+						Optional:    true,
+						Description: `ViewOps`,
+						Type:        smd.Object,
+						TypeName:    "ViewOps",
+						Properties: smd.PropertyList{
+							{
+								Name:        "page",
+								Description: `page number, default - 1`,
+								Type:        smd.Integer,
+							},
+							{
+								Name:        "pageSize",
+								Description: `items count per page, max - 500`,
+								Type:        smd.Integer,
+							},
+							{
+								Name:        "sortColumn",
+								Description: `sort by column name`,
+								Type:        smd.String,
+							},
+							{
+								Name:        "sortDesc",
+								Description: `descending sort`,
+								Type:        smd.Boolean,
+							},
+						},
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `[]Job`,
+					Type:        smd.Array,
+					TypeName:    "[]Job",
+					Items: map[string]string{
+						"$ref": "#/definitions/Job",
+					},
+					Definitions: map[string]smd.Definition{
+						"Job": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "id",
+									Type: smd.Integer,
+								},
+								{
+									Name: "kind",
+									Type: smd.String,
+								},
+								{
+									Name: "args",
+									Ref:  "#/definitions/json.RawMessage",
+									Type: smd.Object,
+								},
+								{
+									Name: "state",
+									Type: smd.String,
+								},
+								{
+									Name:     "uniqueKey",
+									Optional: true,
+									Type:     smd.String,
+								},
+								{
+									Name: "attempts",
+									Type: smd.Integer,
+								},
+								{
+									Name: "maxAttempts",
+									Type: smd.Integer,
+								},
+								{
+									Name: "runAt",
+									Type: smd.String,
+								},
+								{
+									Name:     "lockedAt",
+									Optional: true,
+									Type:     smd.String,
+								},
+								{
+									Name:     "finishedAt",
+									Optional: true,
+									Type:     smd.String,
+								},
+								{
+									Name:     "error",
+									Optional: true,
+									Type:     smd.String,
+								},
+								{
+									Name: "createdAt",
+									Type: smd.String,
+								},
+							},
+						},
+						"json.RawMessage": {
+							Type:       "object",
+							Properties: smd.PropertyList{},
+						},
+					},
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+				},
+			},
+			"GetByID": {
+				Description: `GetByID returns a Job by its ID.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "id",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `Job`,
+					Optional:    true,
+					Type:        smd.Object,
+					TypeName:    "Job",
+					Properties: smd.PropertyList{
+						{
+							Name: "id",
+							Type: smd.Integer,
+						},
+						{
+							Name: "kind",
+							Type: smd.String,
+						},
+						{
+							Name: "args",
+							Ref:  "#/definitions/json.RawMessage",
+							Type: smd.Object,
+						},
+						{
+							Name: "state",
+							Type: smd.String,
+						},
+						{
+							Name:     "uniqueKey",
+							Optional: true,
+							Type:     smd.String,
+						},
+						{
+							Name: "attempts",
+							Type: smd.Integer,
+						},
+						{
+							Name: "maxAttempts",
+							Type: smd.Integer,
+						},
+						{
+							Name: "runAt",
+							Type: smd.String,
+						},
+						{
+							Name:     "lockedAt",
+							Optional: true,
+							Type:     smd.String,
+						},
+						{
+							Name:     "finishedAt",
+							Optional: true,
+							Type:     smd.String,
+						},
+						{
+							Name:     "error",
+							Optional: true,
+							Type:     smd.String,
+						},
+						{
+							Name: "createdAt",
+							Type: smd.String,
+						},
+					},
+					Definitions: map[string]smd.Definition{
+						"json.RawMessage": {
+							Type:       "object",
+							Properties: smd.PropertyList{},
+						},
+					},
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					404: "Not Found",
+				},
+			},
+			"Retry": {
+				Description: `Retry moves failed or canceled Job back to queue.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "id",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `isRetried`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					400: "Invalid job state",
+					404: "Not Found",
+				},
+			},
+			"Cancel": {
+				Description: `Cancel cancels pending or running Job. Running handler is not interrupted, but its result is discarded.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "id",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `isCanceled`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					400: "Invalid job state",
+					404: "Not Found",
+				},
+			},
+		},
+	}
+}
+
+// Invoke is as generated code from zenrpc cmd
+func (s JobService) Invoke(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+	resp := zenrpc.Response{}
+	var err error
+
+	switch method {
+	case RPC.JobService.Count:
+		var args = struct {
+			Search *JobSearch `json:"search"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"search"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.Count(ctx, args.Search))
+
+	case RPC.JobService.Get:
+		var args = struct {
+			Search  *JobSearch `json:"search"`
+			ViewOps *ViewOps   `json:"viewOps"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"search", "viewOps"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.Get(ctx, args.Search, args.ViewOps))
+
+	case RPC.JobService.GetByID:
+		var args = struct {
+			Id int `json:"id"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"id"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.GetByID(ctx, args.Id))
+
+	case RPC.JobService.Retry:
+		var args = struct {
+			Id int `json:"id"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"id"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.Retry(ctx, args.Id))
+
+	case RPC.JobService.Cancel:
+		var args = struct {
+			Id int `json:"id"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"id"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.Cancel(ctx, args.Id))
+
+	default:
+		resp = zenrpc.NewResponseError(nil, zenrpc.MethodNotFound, "", nil)
+	}
+
+	return resp
+}
+
+func (MediaService) SMD() smd.ServiceInfo {
+	return smd.ServiceInfo{
+		Methods: map[string]smd.Service{
+			"FileURL": {
+				Description: `FileURL returns signed url of vfs file.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "fileID",
+						Description: `file id`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "expiresIn",
+						Optional:    true,
+						Description: `lifetime of url in seconds, default from config`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `string`,
+					Type:        smd.String,
+				},
+				Errors: map[int]string{
+					404: "Not Found",
+					500: "Internal Error",
+				},
+			},
+			"HashURL": {
+				Description: `HashURL returns signed url of hash file or its preset image.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "ns",
+						Description: `hash namespace`,
+						Type:        smd.String,
+					},
+					{
+						Name:        "hash",
+						Description: `file hash`,
+						Type:        smd.String,
+					},
+					{
+						Name:        "preset",
+						Optional:    true,
+						Description: `image preset, e.g. 256 or small, original file if empty`,
+						Type:        smd.String,
+					},
+					{
+						Name:        "expiresIn",
+						Optional:    true,
+						Description: `lifetime of url in seconds, default from config`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `string`,
+					Type:        smd.String,
+				},
+				Errors: map[int]string{
+					400: "Invalid Input",
+					404: "Not Found",
+					500: "Internal Error",
+				},
+			},
+			"SetFolderPrivate": {
+				Description: `SetFolderPrivate changes visibility of folder, files of private folders and their subfolders are served by signed urls only.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "folderID",
+						Description: `folder id`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "isPrivate",
+						Description: `folder visibility`,
+						Type:        smd.Boolean,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `bool`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					404: "Not Found",
+					500: "Internal Error",
+				},
+			},
+			"Usage": {
+				Description: `Usage returns storage usage of hash namespaces, public namespace is reported as default.`,
+				Parameters:  []smd.JSONSchema{},
+				Returns: smd.JSONSchema{
+					Description: `[]StorageUsage`,
+					Type:        smd.Array,
+					TypeName:    "[]StorageUsage",
+					Items: map[string]string{
+						"$ref": "#/definitions/StorageUsage",
+					},
+					Definitions: map[string]smd.Definition{
+						"StorageUsage": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "namespace",
+									Type: smd.String,
+								},
+								{
+									Name: "files",
+									Type: smd.Integer,
+								},
+								{
+									Name: "size",
+									Type: smd.Integer,
+								},
+								{
+									Name:     "quota",
+									Optional: true,
+									Type:     smd.Integer,
+								},
+							},
+						},
+					},
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					501: "Not Implemented",
+				},
+			},
+			"FolderUsage": {
+				Description: `FolderUsage returns storage usage of folder with subfolders and the nearest quota of folder or its parents.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "folderID",
+						Description: `folder id`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `StorageUsage`,
+					Optional:    true,
+					Type:        smd.Object,
+					TypeName:    "StorageUsage",
+					Properties: smd.PropertyList{
+						{
+							Name: "namespace",
+							Type: smd.String,
+						},
+						{
+							Name: "files",
+							Type: smd.Integer,
+						},
+						{
+							Name: "size",
+							Type: smd.Integer,
+						},
+						{
+							Name:     "quota",
+							Optional: true,
+							Type:     smd.Integer,
+						},
+					},
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					501: "Not Implemented",
+				},
+			},
+			"SetFolderQuota": {
+				Description: `SetFolderQuota sets storage quota of folder and its subfolders in bytes, empty quota removes limit.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "folderID",
+						Description: `folder id`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "quota",
+						Optional:    true,
+						Description: `quota in bytes`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `bool`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					400: "Invalid Input",
+					404: "Not Found",
+					500: "Internal Error",
+				},
+			},
+		},
+	}
+}
+
+// Invoke is as generated code from zenrpc cmd
+func (s MediaService) Invoke(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+	resp := zenrpc.Response{}
+	var err error
+
+	switch method {
+	case RPC.MediaService.FileURL:
+		var args = struct {
+			FileID    int  `json:"fileID"`
+			ExpiresIn *int `json:"expiresIn"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"fileID", "expiresIn"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.FileURL(ctx, args.FileID, args.ExpiresIn))
+
+	case RPC.MediaService.HashURL:
+		var args = struct {
+			Ns        string  `json:"ns"`
+			Hash      string  `json:"hash"`
+			Preset    *string `json:"preset"`
+			ExpiresIn *int    `json:"expiresIn"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"ns", "hash", "preset", "expiresIn"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.HashURL(ctx, args.Ns, args.Hash, args.Preset, args.ExpiresIn))
+
+	case RPC.MediaService.SetFolderPrivate:
+		var args = struct {
+			FolderID  int  `json:"folderID"`
+			IsPrivate bool `json:"isPrivate"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"folderID", "isPrivate"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.SetFolderPrivate(ctx, args.FolderID, args.IsPrivate))
+
+	case RPC.MediaService.Usage:
+		resp.Set(s.Usage(ctx))
+
+	case RPC.MediaService.FolderUsage:
+		var args = struct {
+			FolderID int `json:"folderID"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"folderID"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.FolderUsage(ctx, args.FolderID))
+
+	case RPC.MediaService.SetFolderQuota:
+		var args = struct {
+			FolderID int    `json:"folderID"`
+			Quota    *int64 `json:"quota"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"folderID", "quota"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.SetFolderQuota(ctx, args.FolderID, args.Quota))
+
+	default:
+		resp = zenrpc.NewResponseError(nil, zenrpc.MethodNotFound, "", nil)
+	}
+
+	return resp
+}
+
+func (VfsService) SMD() smd.ServiceInfo {
+	return smd.ServiceInfo{
+		Methods: map[string]smd.Service{
+			"Duplicates": {
+				Description: `Duplicates returns groups of files with the same content, groups which waste more space are first.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "limit",
+						Optional:    true,
+						Description: `max count of groups, default 100`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `[]VfsDuplicate`,
+					Type:        smd.Array,
+					TypeName:    "[]VfsDuplicate",
+					Items: map[string]string{
+						"$ref": "#/definitions/VfsDuplicate",
+					},
+					Definitions: map[string]smd.Definition{
+						"VfsDuplicate": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "hash",
+									Type: smd.String,
+								},
+								{
+									Name: "size",
+									Type: smd.Integer,
+								},
+								{
+									Name: "files",
+									Type: smd.Array,
+									Items: map[string]string{
+										"$ref": "#/definitions/VfsFileSummary",
+									},
+								},
+							},
+						},
+						"VfsFileSummary": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "id",
+									Type: smd.Integer,
+								},
+								{
+									Name: "name",
+									Type: smd.String,
+								},
+								{
+									Name: "path",
+									Type: smd.String,
+								},
+							},
+						},
+					},
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					501: "Not Implemented",
+				},
+			},
+			"FileDuplicates": {
+				Description: `FileDuplicates returns other files with the same content as file.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "fileID",
+						Description: `file id`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `[]VfsFileSummary`,
+					Type:        smd.Array,
+					TypeName:    "[]VfsFileSummary",
+					Items: map[string]string{
+						"$ref": "#/definitions/VfsFileSummary",
+					},
+					Definitions: map[string]smd.Definition{
+						"VfsFileSummary": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "id",
+									Type: smd.Integer,
+								},
+								{
+									Name: "name",
+									Type: smd.String,
+								},
+								{
+									Name: "path",
+									Type: smd.String,
+								},
+							},
+						},
+					},
+				},
+				Errors: map[int]string{
+					404: "Not Found",
+					500: "Internal Error",
+					501: "Not Implemented",
+				},
+			},
+			"CreateFolder": {
+				Description: `CreateFolder creates virtual folder.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "rootFolderId",
+						Description: `parent folder id`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "name",
+						Description: `folder name`,
+						Type:        smd.String,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `bool`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					400: "Invalid Input",
+					404: "Not Found",
+					500: "Internal Error",
+				},
+			},
+			"DeleteFolder": {
+				Description: `DeleteFolder removes Folder.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "folderId",
+						Description: `folder id`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `bool`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					400: "Invalid Input",
+					404: "Not Found",
+					500: "Internal Error",
+				},
+			},
+			"MoveFolder": {
+				Description: `MoveFolder moves Folder to destination folder.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "folderId",
+						Description: `folder id`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "destinationFolderId",
+						Description: `new parent folder id`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `bool`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					400: "Invalid Input",
+					404: "Not Found",
+					409: "Conflict",
+					500: "Internal Error",
+				},
+			},
+			"RenameFolder": {
+				Description: `RenameFolder changes Folder name.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "folderId",
+						Description: `folder id`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "name",
+						Description: `new folder name`,
+						Type:        smd.String,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `bool`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					400: "Invalid Input",
+					404: "Not Found",
+					500: "Internal Error",
+				},
+			},
+			"ManageFavorites": {
+				Description: `ManageFavorites adds Folder to favorites or removes it.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "folderId",
+						Description: `folder id`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "isInFavorites",
+						Description: `is folder favorite`,
+						Type:        smd.Boolean,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `bool`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					400: "Invalid Input",
+					404: "Not Found",
+					500: "Internal Error",
+				},
+			},
+		},
+	}
+}
+
+// Invoke is as generated code from zenrpc cmd
+func (s VfsService) Invoke(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+	resp := zenrpc.Response{}
+	var err error
+
+	switch method {
+	case RPC.VfsService.Duplicates:
+		var args = struct {
+			Limit *int `json:"limit"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"limit"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.Duplicates(ctx, args.Limit))
+
+	case RPC.VfsService.FileDuplicates:
+		var args = struct {
+			FileID int `json:"fileID"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"fileID"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.FileDuplicates(ctx, args.FileID))
+
+	case RPC.VfsService.CreateFolder:
+		var args = struct {
+			RootFolderId int    `json:"rootFolderId"`
+			Name         string `json:"name"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"rootFolderId", "name"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.CreateFolder(ctx, args.RootFolderId, args.Name))
+
+	case RPC.VfsService.DeleteFolder:
+		var args = struct {
+			FolderId int `json:"folderId"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"folderId"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.DeleteFolder(ctx, args.FolderId))
+
+	case RPC.VfsService.MoveFolder:
+		var args = struct {
+			FolderId            int `json:"folderId"`
+			DestinationFolderId int `json:"destinationFolderId"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"folderId", "destinationFolderId"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.MoveFolder(ctx, args.FolderId, args.DestinationFolderId))
+
+	case RPC.VfsService.RenameFolder:
+		var args = struct {
+			FolderId int    `json:"folderId"`
+			Name     string `json:"name"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"folderId", "name"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.RenameFolder(ctx, args.FolderId, args.Name))
+
+	case RPC.VfsService.ManageFavorites:
+		var args = struct {
+			FolderId      int  `json:"folderId"`
+			IsInFavorites bool `json:"isInFavorites"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"folderId", "isInFavorites"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.ManageFavorites(ctx, args.FolderId, args.IsInFavorites))
+
+	default:
+		resp = zenrpc.NewResponseError(nil, zenrpc.MethodNotFound, "", nil)
+	}
+
+	return resp
+}
+
+func (StatusService) SMD() smd.ServiceInfo {
+	return smd.ServiceInfo{
+		Methods: map[string]smd.Service{
+			"Get": {
+				Description: `Get returns all statuses with titles localized by Accept-Language.`,
+				Parameters:  []smd.JSONSchema{},
+				Returns: smd.JSONSchema{
+					Description: `[]Status`,
+					Type:        smd.Array,
+					TypeName:    "[]Status",
+					Items: map[string]string{
+						"$ref": "#/definitions/Status",
+					},
+					Definitions: map[string]smd.Definition{
+						"Status": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "id",
+									Type: smd.Integer,
+								},
+								{
+									Name: "alias",
+									Type: smd.String,
+								},
+								{
+									Name: "title",
+									Type: smd.String,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Invoke is as generated code from zenrpc cmd
+func (s StatusService) Invoke(ctx context.Context, method string, params json.RawMessage) zenrpc.Response {
+	resp := zenrpc.Response{}
+
+	switch method {
+	case RPC.StatusService.Get:
+		resp.Set(s.Get(ctx))
+
+	default:
+		resp = zenrpc.NewResponseError(nil, zenrpc.MethodNotFound, "", nil)
+	}
+
+	return resp
+}
+
 func (AuthService) SMD() smd.ServiceInfo {
 	return smd.ServiceInfo{
 		Methods: map[string]smd.Service{
@@ -689,6 +1912,188 @@ func (UserService) SMD() smd.ServiceInfo {
 					404: "Not Found",
 				},
 			},
+			"History": {
+				Description: `History returns revisions of the User, the latest first.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "id",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `[]UserRevision`,
+					Type:        smd.Array,
+					TypeName:    "[]UserRevision",
+					Items: map[string]string{
+						"$ref": "#/definitions/UserRevision",
+					},
+					Definitions: map[string]smd.Definition{
+						"UserRevision": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "revision",
+									Type: smd.Integer,
+								},
+								{
+									Name:     "actorId",
+									Optional: true,
+									Type:     smd.Integer,
+								},
+								{
+									Name: "createdAt",
+									Type: smd.String,
+								},
+								{
+									Name:     "user",
+									Optional: true,
+									Ref:      "#/definitions/User",
+									Type:     smd.Object,
+								},
+							},
+						},
+						"User": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "id",
+									Type: smd.Integer,
+								},
+								{
+									Name: "createdAt",
+									Type: smd.String,
+								},
+								{
+									Name: "login",
+									Type: smd.String,
+								},
+								{
+									Name: "password",
+									Type: smd.String,
+								},
+								{
+									Name:     "lastActivityAt",
+									Optional: true,
+									Type:     smd.String,
+								},
+								{
+									Name: "statusId",
+									Type: smd.Integer,
+								},
+								{
+									Name:     "status",
+									Optional: true,
+									Ref:      "#/definitions/Status",
+									Type:     smd.Object,
+								},
+							},
+						},
+						"Status": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "id",
+									Type: smd.Integer,
+								},
+								{
+									Name: "alias",
+									Type: smd.String,
+								},
+								{
+									Name: "title",
+									Type: smd.String,
+								},
+							},
+						},
+					},
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					404: "Not Found",
+				},
+			},
+			"Diff": {
+				Description: `Diff returns changed fields of the User between revisions revA and revB.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "id",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "revA",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "revB",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `[]FieldDiff`,
+					Type:        smd.Array,
+					TypeName:    "[]FieldDiff",
+					Items: map[string]string{
+						"$ref": "#/definitions/FieldDiff",
+					},
+					Definitions: map[string]smd.Definition{
+						"FieldDiff": {
+							Type: "object",
+							Properties: smd.PropertyList{
+								{
+									Name: "field",
+									Type: smd.String,
+								},
+								{
+									Name: "old",
+									Ref:  "#/definitions/any",
+									Type: smd.Object,
+								},
+								{
+									Name: "new",
+									Ref:  "#/definitions/any",
+									Type: smd.Object,
+								},
+							},
+						},
+						"any": {
+							Type:       "object",
+							Properties: smd.PropertyList{},
+						},
+					},
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					404: "Not Found",
+				},
+			},
+			"Revert": {
+				Description: `Revert restores the User from revision rev. Restored data is validated like in Update, password is not changed.`,
+				Parameters: []smd.JSONSchema{
+					{
+						Name:        "id",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+					{
+						Name:        "rev",
+						Description: `int`,
+						Type:        smd.Integer,
+					},
+				},
+				Returns: smd.JSONSchema{
+					Description: `isReverted`,
+					Type:        smd.Boolean,
+				},
+				Errors: map[int]string{
+					500: "Internal Error",
+					400: "Validation Error",
+					404: "Not Found",
+				},
+			},
 			"Validate": {
 				Description: `Validate Verifies that User data is valid.`,
 				Parameters: []smd.JSONSchema{
@@ -777,6 +2182,11 @@ func (UserService) SMD() smd.ServiceInfo {
 									Ref:         "#/definitions/FieldErrorConstraint",
 									Type:        smd.Object,
 								},
+								{
+									Name:        "message",
+									Description: `Localized error message.`,
+									Type:        smd.String,
+								},
 							},
 						},
 						"FieldErrorConstraint": {
@@ -925,6 +2335,66 @@ func (s UserService) Invoke(ctx context.Context, method string, params json.RawM
 
 		resp.Set(s.Delete(ctx, args.Id))
 
+	case RPC.UserService.History:
+		var args = struct {
+			Id int `json:"id"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"id"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.History(ctx, args.Id))
+
+	case RPC.UserService.Diff:
+		var args = struct {
+			Id   int `json:"id"`
+			RevA int `json:"revA"`
+			RevB int `json:"revB"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"id", "revA", "revB"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.Diff(ctx, args.Id, args.RevA, args.RevB))
+
+	case RPC.UserService.Revert:
+		var args = struct {
+			Id  int `json:"id"`
+			Rev int `json:"rev"`
+		}{}
+
+		if zenrpc.IsArray(params) {
+			if params, err = zenrpc.ConvertToObject([]string{"id", "rev"}, params); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &args); err != nil {
+				return zenrpc.NewResponseError(nil, zenrpc.InvalidParams, "", err.Error())
+			}
+		}
+
+		resp.Set(s.Revert(ctx, args.Id, args.Rev))
+
 	case RPC.UserService.Validate:
 		var args = struct {
 			User User `json:"user"`